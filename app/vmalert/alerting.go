@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"hash/fnv"
+	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,16 +21,29 @@ import (
 
 // AlertingRule is basic alert entity
 type AlertingRule struct {
-	Type         datasource.Type
-	RuleID       uint64
-	Name         string
-	Expr         string
-	For          time.Duration
-	Labels       map[string]string
-	Annotations  map[string]string
-	GroupID      uint64
-	GroupName    string
-	EvalInterval time.Duration
+	Type   datasource.Type
+	RuleID uint64
+	Name   string
+	Expr   string
+	For    time.Duration
+	// KeepFiringFor defines for how long alert keeps firing
+	// after its expression stopped returning results
+	KeepFiringFor time.Duration
+	Labels        map[string]string
+	Annotations   map[string]string
+	GroupID       uint64
+	GroupName     string
+	EvalInterval  time.Duration
+	// Timeout limits the duration of the rule's query
+	Timeout time.Duration
+	// NoData defines the behavior on empty query results
+	// and is one of config.NoData* values
+	NoData string
+	// HealthErrorsThreshold is the number of consecutive failed
+	// evaluations after which the rule is marked unhealthy
+	HealthErrorsThreshold int
+	// Debug enables logging for the rule
+	Debug bool
 
 	q datasource.Querier
 
@@ -45,6 +60,13 @@ type AlertingRule struct {
 	// stores the number of samples returned during
 	// the last evaluation
 	lastExecSamples int
+	// stores the duration of the last evaluation
+	lastExecDuration time.Duration
+	// stores the number of consecutive failed evaluations
+	// resets on every successful Exec
+	lastExecFailures int
+	// stores the history of the last evaluations
+	state *ruleState
 
 	metrics *alertingRuleMetrics
 }
@@ -58,23 +80,25 @@ type alertingRuleMetrics struct {
 
 func newAlertingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rule) *AlertingRule {
 	ar := &AlertingRule{
-		Type:         cfg.Type,
-		RuleID:       cfg.ID,
-		Name:         cfg.Alert,
-		Expr:         cfg.Expr,
-		For:          cfg.For.Duration(),
-		Labels:       cfg.Labels,
-		Annotations:  cfg.Annotations,
-		GroupID:      group.ID(),
-		GroupName:    group.Name,
-		EvalInterval: group.Interval,
-		q: qb.BuildWithParams(datasource.QuerierParams{
-			DataSourceType:     &cfg.Type,
-			EvaluationInterval: group.Interval,
-			ExtraLabels:        group.ExtraFilterLabels,
-		}),
-		alerts:  make(map[uint64]*notifier.Alert),
-		metrics: &alertingRuleMetrics{},
+		Type:                  cfg.Type,
+		RuleID:                ruleID(group.ID(), cfg),
+		Name:                  cfg.Alert,
+		Expr:                  cfg.Expr,
+		For:                   cfg.For.Duration(),
+		KeepFiringFor:         cfg.KeepFiringFor.Duration(),
+		Labels:                cfg.Labels,
+		Annotations:           cfg.Annotations,
+		GroupID:               group.ID(),
+		GroupName:             group.Name,
+		EvalInterval:          group.Interval,
+		Timeout:               getRuleTimeout(cfg),
+		NoData:                cfg.NoData,
+		Debug:                 cfg.Debug,
+		HealthErrorsThreshold: getHealthErrorsThreshold(cfg),
+		q:                     qb.BuildWithParams(ruleQuerierParams(group, cfg)),
+		alerts:                make(map[uint64]*notifier.Alert),
+		state:                 newRuleStateFromConfig(cfg),
+		metrics:               &alertingRuleMetrics{},
 	}
 
 	labels := fmt.Sprintf(`alertname=%q, group=%q, id="%d"`, ar.Name, group.Name, ar.ID())
@@ -106,7 +130,7 @@ func newAlertingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rule
 		func() float64 {
 			ar.mu.RLock()
 			defer ar.mu.RUnlock()
-			if ar.lastExecError == nil {
+			if ar.health() == ruleHealthOK {
 				return 0
 			}
 			return 1
@@ -126,6 +150,7 @@ func (ar *AlertingRule) Close() {
 	metrics.UnregisterMetric(ar.metrics.pending.name)
 	metrics.UnregisterMetric(ar.metrics.errors.name)
 	metrics.UnregisterMetric(ar.metrics.samples.name)
+	activeAlerts.remove(ar)
 }
 
 // String implements Stringer interface
@@ -198,33 +223,79 @@ func (ar *AlertingRule) ExecRange(ctx context.Context, start, end time.Time) ([]
 
 // Exec executes AlertingRule expression via the given Querier.
 // Based on the Querier results AlertingRule maintains notifier.Alerts
-func (ar *AlertingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, error) {
-	qMetrics, err := ar.q.Query(ctx, ar.Expr)
+func (ar *AlertingRule) Exec(ctx context.Context, ts time.Time, limit int) ([]prompbmarshal.TimeSeries, error) {
+	start := time.Now()
+	var qt datasource.QueryTrace
+	qMetrics, err := queryWithTimeout(datasource.WithQueryTrace(ctx, &qt), ar.q, ar.Expr, ts, ar.Timeout)
 	ar.mu.Lock()
 	defer ar.mu.Unlock()
+	defer func() {
+		if ar.lastExecError != nil {
+			ar.lastExecFailures++
+		} else {
+			ar.lastExecFailures = 0
+		}
+		ar.lastExecDuration = time.Since(start)
+		ar.state.add(ruleStateEntry{
+			time:     ts,
+			duration: ar.lastExecDuration,
+			samples:  ar.lastExecSamples,
+			err:      ar.lastExecError,
+			traceID:  datasource.TraceIDFromContext(ctx),
+			trace:    qt.Data,
+		})
+	}()
 
 	ar.lastExecError = err
-	ar.lastExecTime = time.Now()
+	ar.lastExecTime = ts
 	ar.lastExecSamples = len(qMetrics)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query %q: %w", ar.Expr, err)
 	}
+	ar.logDebugf(ar.lastExecTime, nil, "query returned %d series", len(qMetrics))
+	if limit > 0 && len(qMetrics) > limit {
+		// results are discarded and alerts state
+		// remains unchanged until the next evaluation
+		ar.lastExecError = fmt.Errorf("%w of %d with %d series", errLimitExceeded, limit, len(qMetrics))
+		return nil, ar.lastExecError
+	}
+	if len(qMetrics) == 0 {
+		switch ar.NoData {
+		case config.NoDataError:
+			// alerts state remains unchanged
+			// until the next evaluation
+			ar.lastExecError = errNoData
+			return nil, ar.lastExecError
+		case config.NoDataAlert:
+			// the synthetic series goes through
+			// the usual alert states, including `for`
+			qMetrics = []datasource.Metric{{
+				Labels:     []datasource.Label{{Name: noDataLabel, Value: "true"}},
+				Values:     []float64{0},
+				Timestamps: []int64{ts.Unix()},
+			}}
+		}
+	}
 
 	for h, a := range ar.alerts {
-		// cleanup inactive alerts from previous Exec
-		if a.State == notifier.StateInactive {
+		// cleanup inactive alerts from previous Execs
+		// which don't need to be sent anymore
+		if a.State == notifier.StateInactive && !ar.needsResolveSend(a, ts) {
 			delete(ar.alerts, h)
 		}
 	}
+	activeAlerts.set(ar, ar.activeAlertsCount())
 
-	qFn := func(query string) ([]datasource.Metric, error) { return ar.q.Query(ctx, query) }
+	qFn := func(query string) ([]datasource.Metric, error) { return ar.q.Query(ctx, query, ts) }
 	updated := make(map[uint64]struct{})
+	var suppressed int
 	// update list of active alerts
 	for _, m := range qMetrics {
 		// extra labels could contain templates, so we expand them first
 		labels, err := expandLabels(m, qFn, ar)
 		if err != nil {
-			return nil, fmt.Errorf("failed to expand labels: %s", err)
+			ar.lastExecError = fmt.Errorf("failed to expand labels: %s", err)
+			return nil, ar.lastExecError
 		}
 		for k, v := range labels {
 			// apply extra labels to datasource
@@ -232,65 +303,169 @@ func (ar *AlertingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, e
 			m.SetLabel(k, v)
 		}
 		h := hash(m)
+		if ar.Debug {
+			// labelsToString is expensive, so it is called only if debug is enabled
+			ar.logDebugf(ar.lastExecTime, nil, "series %s returned value %v", labelsToString(m.Labels), m.Values)
+		}
 		if _, ok := updated[h]; ok {
 			// duplicate may be caused by extra labels
 			// conflicting with the metric labels
-			return nil, fmt.Errorf("labels %v: %w", m.Labels, errDuplicate)
+			ar.lastExecError = fmt.Errorf("labels %v: %w", m.Labels, errDuplicate)
+			return nil, ar.lastExecError
 		}
 		updated[h] = struct{}{}
-		if a, ok := ar.alerts[h]; ok {
+		if a, ok := ar.alerts[h]; ok && a.State != notifier.StateInactive {
+			// series is back, so alert doesn't need to be kept firing
+			a.KeepFiringSince = time.Time{}
 			if a.Value != m.Values[0] {
 				// update Value field with latest value
 				a.Value = m.Values[0]
 				// and re-exec template since Value can be used
 				// in annotations
-				a.Annotations, err = a.ExecTemplate(qFn, ar.Annotations)
+				annotations, err := a.ExecTemplate(qFn, ar.Annotations)
 				if err != nil {
+					ar.lastExecError = err
 					return nil, err
 				}
+				if !reflect.DeepEqual(a.Annotations, annotations) {
+					// changed alert must be re-sent
+					// regardless of -rule.resendDelay
+					a.LastSent = time.Time{}
+				}
+				a.Annotations = annotations
 			}
 			continue
 		}
+		if !activeAlerts.tryInc(ar) {
+			// already active alerts are kept,
+			// only creation of new ones is suppressed
+			suppressed++
+			continue
+		}
 		a, err := ar.newAlert(m, ar.lastExecTime, qFn)
 		if err != nil {
 			ar.lastExecError = err
 			return nil, fmt.Errorf("failed to create alert: %w", err)
 		}
-		a.ID = h
 		a.State = notifier.StatePending
+		// resolved alert with the same labels, if any,
+		// is replaced by the new one
 		ar.alerts[h] = a
+		ar.logDebugf(ar.lastExecTime, a, "INACTIVE => PENDING")
+	}
+	if suppressed > 0 {
+		activeAlerts.suppressed(ar, suppressed)
+		ar.logDebugf(ar.lastExecTime, nil, "suppressed %d new alerts because of -rule.maxActiveAlerts", suppressed)
 	}
 
 	for h, a := range ar.alerts {
 		// if alert wasn't updated in this iteration
 		// means it is resolved already
 		if _, ok := updated[h]; !ok {
+			if a.State == notifier.StateInactive {
+				// alert was resolved during previous Execs
+				continue
+			}
 			if a.State == notifier.StatePending {
 				// alert was in Pending state - it is not
 				// active anymore
 				delete(ar.alerts, h)
+				ar.logDebugf(ar.lastExecTime, a, "PENDING => DELETED: is absent in current evaluation round")
 				continue
 			}
+			if a.State == notifier.StateFiring && ar.KeepFiringFor > 0 {
+				if a.KeepFiringSince.IsZero() {
+					a.KeepFiringSince = ar.lastExecTime
+				}
+				// alert keeps firing with its latest labels and
+				// ActiveAt until KeepFiringFor is elapsed
+				if ar.lastExecTime.Sub(a.KeepFiringSince) < ar.KeepFiringFor {
+					ar.logDebugf(ar.lastExecTime, a, "is absent in current evaluation round, but keeps FIRING because of keep_firing_for=%v", ar.KeepFiringFor)
+					continue
+				}
+			}
 			a.State = notifier.StateInactive
+			// notify that alert was just resolved
+			a.End = ar.lastExecTime
+			a.LastSent = time.Time{}
+			ar.logDebugf(ar.lastExecTime, a, "FIRING => INACTIVE: is absent in current evaluation round")
 			continue
 		}
-		if a.State == notifier.StatePending && time.Since(a.Start) >= ar.For {
+		if a.State == notifier.StatePending && ts.Sub(a.Start) >= ar.For {
 			a.State = notifier.StateFiring
 			alertsFired.Inc()
+			ar.logDebugf(ar.lastExecTime, a, "PENDING => FIRING: %s since becoming active at %v", ts.Sub(a.Start), a.Start)
 		}
 	}
+	activeAlerts.set(ar, ar.activeAlertsCount())
 	return ar.toTimeSeries(ar.lastExecTime.Unix()), nil
 }
 
+// activeAlertsCount returns the number of pending and firing alerts.
+// It must be called with ar.mu held.
+func (ar *AlertingRule) activeAlertsCount() int {
+	var n int
+	for _, a := range ar.alerts {
+		if a.State != notifier.StateInactive {
+			n++
+		}
+	}
+	return n
+}
+
+// needsResolveSend returns true if the resolved alert a must be kept
+// for sending to notifiers at ts. Resolved alert is sent
+// -rule.resendResolvedCount times within the resolve duration.
+func (ar *AlertingRule) needsResolveSend(a *notifier.Alert, ts time.Time) bool {
+	if a.ResolvedSent >= *resendResolvedCount {
+		return false
+	}
+	return ts.Sub(a.End) <= getResolveDuration(ar.EvalInterval, *resendDelay, *maxResolveDuration)
+}
+
+// logDebugf logs the given message with rule and alert
+// details if Debug is enabled for the rule
+func (ar *AlertingRule) logDebugf(at time.Time, a *notifier.Alert, format string, args ...interface{}) {
+	if !ar.Debug {
+		return
+	}
+	prefix := fmt.Sprintf("DEBUG rule %q:%q (%d) at %v: ",
+		ar.GroupName, ar.Name, ar.RuleID, at.Format(time.RFC3339))
+	if a != nil {
+		labelKeys := make([]string, 0, len(a.Labels))
+		for k := range a.Labels {
+			labelKeys = append(labelKeys, k)
+		}
+		sort.Strings(labelKeys)
+		labels := make([]string, len(labelKeys))
+		for i, k := range labelKeys {
+			labels[i] = fmt.Sprintf("%s=%q", k, a.Labels[k])
+		}
+		prefix += fmt.Sprintf("alert %d {%s} ", a.ID, strings.Join(labels, ","))
+	}
+	msg := fmt.Sprintf(format, args...)
+	logger.Infof("%s", prefix+msg)
+}
+
+// labelsToString returns string representation of the given labels
+func labelsToString(labels []datasource.Label) string {
+	ls := make([]string, len(labels))
+	for i, l := range labels {
+		ls[i] = fmt.Sprintf("%s=%q", l.Name, l.Value)
+	}
+	return "{" + strings.Join(ls, ",") + "}"
+}
+
 func expandLabels(m datasource.Metric, q notifier.QueryFn, ar *AlertingRule) (map[string]string, error) {
 	metricLabels := make(map[string]string)
 	for _, l := range m.Labels {
 		metricLabels[l.Name] = l.Value
 	}
 	tpl := notifier.AlertTplData{
-		Labels: metricLabels,
-		Value:  m.Values[0],
-		Expr:   ar.Expr,
+		Labels:  metricLabels,
+		Value:   m.Values[0],
+		Expr:    ar.Expr,
+		GroupID: ar.GroupID,
 	}
 	return notifier.ExecTemplate(q, ar.Labels, tpl)
 }
@@ -315,11 +490,41 @@ func (ar *AlertingRule) UpdateWith(r Rule) error {
 	if !ok {
 		return fmt.Errorf("BUG: attempt to update alerting rule with wrong type %#v", r)
 	}
+	// rule's identity doesn't include `for`, so alerts state is preserved
+	// on config reload if unrelated rules are changed. But the change of `for`
+	// must start pending periods from scratch. Firing alerts are kept,
+	// since dropping them would make them flap without sending resolve.
+	if ar.For != nr.For {
+		ar.mu.Lock()
+		var reset int
+		for h, a := range ar.alerts {
+			if a.State == notifier.StatePending {
+				delete(ar.alerts, h)
+				reset++
+			}
+		}
+		if reset > 0 {
+			logger.Infof("alerting rule %q: resetting state of %d pending alerts because `for` changed from %v to %v",
+				ar.Name, reset, ar.For, nr.For)
+		}
+		activeAlerts.set(ar, ar.activeAlertsCount())
+		ar.mu.Unlock()
+	}
 	ar.Expr = nr.Expr
 	ar.For = nr.For
+	ar.KeepFiringFor = nr.KeepFiringFor
 	ar.Labels = nr.Labels
 	ar.Annotations = nr.Annotations
 	ar.EvalInterval = nr.EvalInterval
+	ar.Timeout = nr.Timeout
+	ar.NoData = nr.NoData
+	ar.HealthErrorsThreshold = nr.HealthErrorsThreshold
+	ar.Debug = nr.Debug
+	if ar.state.size() != nr.state.size() {
+		ar.mu.Lock()
+		ar.state = ar.state.resize(nr.state.size())
+		ar.mu.Unlock()
+	}
 	ar.q = nr.q
 	return nil
 }
@@ -343,8 +548,12 @@ func hash(m datasource.Metric) uint64 {
 	return hash.Sum64()
 }
 
+// newAlert returns alert for m with executed annotations templates.
+// The alert ID is calculated from m before executing templates,
+// so it is available in templates via $alertID variable.
 func (ar *AlertingRule) newAlert(m datasource.Metric, start time.Time, qFn notifier.QueryFn) (*notifier.Alert, error) {
 	a := &notifier.Alert{
+		ID:      hash(m),
 		GroupID: ar.GroupID,
 		Name:    ar.Name,
 		Labels:  map[string]string{},
@@ -380,6 +589,18 @@ func (ar *AlertingRule) AlertAPI(id uint64) *APIAlert {
 	return ar.newAlertAPI(*a)
 }
 
+// Healthy implements Rule interface
+func (ar *AlertingRule) Healthy() bool {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+	return ar.health() == ruleHealthOK
+}
+
+// health must be called under ar.mu lock
+func (ar *AlertingRule) health() string {
+	return ruleHealth(ar.lastExecError, ar.lastExecFailures, ar.HealthErrorsThreshold)
+}
+
 // RuleAPI returns Rule representation in form
 // of APIAlertingRule
 func (ar *AlertingRule) RuleAPI() APIAlertingRule {
@@ -389,17 +610,37 @@ func (ar *AlertingRule) RuleAPI() APIAlertingRule {
 	}
 	return APIAlertingRule{
 		// encode as strings to avoid rounding
-		ID:          fmt.Sprintf("%d", ar.ID()),
-		GroupID:     fmt.Sprintf("%d", ar.GroupID),
-		Type:        ar.Type.String(),
-		Name:        ar.Name,
-		Expression:  ar.Expr,
-		For:         ar.For.String(),
-		LastError:   lastErr,
-		LastSamples: ar.lastExecSamples,
-		LastExec:    ar.lastExecTime,
-		Labels:      ar.Labels,
-		Annotations: ar.Annotations,
+		ID:            fmt.Sprintf("%d", ar.ID()),
+		GroupID:       fmt.Sprintf("%d", ar.GroupID),
+		Type:          ar.Type.String(),
+		Name:          ar.Name,
+		Expression:    ar.Expr,
+		For:           ar.For.String(),
+		KeepFiringFor: ar.KeepFiringFor.String(),
+		LastError:     lastErr,
+		LastFailures:  ar.lastExecFailures,
+		Health:        ar.health(),
+		LastSamples:   ar.lastExecSamples,
+		LastExec:      ar.lastExecTime,
+		LastDuration:  ar.lastExecDuration.Seconds(),
+		Timeout:       durationToString(ar.Timeout),
+		Labels:        ar.Labels,
+		Annotations:   ar.Annotations,
+	}
+}
+
+// RuleDetailsAPI generates APIRuleDetails object from alerting rule
+func (ar *AlertingRule) RuleDetailsAPI() APIRuleDetails {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+	return APIRuleDetails{
+		ID:         fmt.Sprintf("%d", ar.ID()),
+		GroupID:    fmt.Sprintf("%d", ar.GroupID),
+		Name:       ar.Name,
+		Type:       ar.Type.String(),
+		Expression: ar.Expr,
+		MaxUpdates: ar.state.size(),
+		Updates:    ar.state.updatesAPI(),
 	}
 }
 
@@ -444,6 +685,10 @@ const (
 
 	// alertGroupNameLabel defines the label name attached for generated time series.
 	alertGroupNameLabel = "alertgroup"
+
+	// noDataLabel is the label name of the alert triggered
+	// by the empty query result if rule's no_data is "alert".
+	noDataLabel = "no_data"
 )
 
 // alertToTimeSeries converts the given alert with the given timestamp to timeseries
@@ -488,7 +733,8 @@ func (ar *AlertingRule) Restore(ctx context.Context, q datasource.Querier, lookb
 		return fmt.Errorf("querier is nil")
 	}
 
-	qFn := func(query string) ([]datasource.Metric, error) { return ar.q.Query(ctx, query) }
+	ts := time.Now()
+	qFn := func(query string) ([]datasource.Metric, error) { return ar.q.Query(ctx, query, ts) }
 
 	// account for external labels in filter
 	var labelsFilter string
@@ -501,7 +747,7 @@ func (ar *AlertingRule) Restore(ctx context.Context, q datasource.Querier, lookb
 	// remote write protocol which is used for state persistence in vmalert.
 	expr := fmt.Sprintf("last_over_time(%s{alertname=%q%s}[%ds])",
 		alertForStateMetricName, ar.Name, labelsFilter, int(lookback.Seconds()))
-	qMetrics, err := q.Query(ctx, expr)
+	qMetrics, err := q.Query(ctx, expr, ts)
 	if err != nil {
 		return err
 	}
@@ -522,7 +768,6 @@ func (ar *AlertingRule) Restore(ctx context.Context, q datasource.Querier, lookb
 		if err != nil {
 			return fmt.Errorf("failed to create alert: %w", err)
 		}
-		a.ID = hash(m)
 		a.State = notifier.StatePending
 		ar.alerts[a.ID] = a
 		logger.Infof("alert %q (%d) restored to state at %v", a.Name, a.ID, a.Start)
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
@@ -293,6 +295,41 @@ func TestAlertingRule_Exec(t *testing.T) {
 				hash(metricWithLabels(t, "name", "foo")): {State: notifier.StateFiring},
 			},
 		},
+		{
+			newTestAlertingRuleWithKeepFiring("for-fired=>keep-firing", 0, time.Minute),
+			[][]datasource.Metric{
+				{metricWithLabels(t, "name", "foo")},
+				// empty step keeps alert firing
+				{},
+				{},
+			},
+			map[uint64]*notifier.Alert{
+				hash(metricWithLabels(t, "name", "foo")): {State: notifier.StateFiring},
+			},
+		},
+		{
+			newTestAlertingRuleWithKeepFiring("for-fired=>keep-firing=>firing", 0, time.Minute),
+			[][]datasource.Metric{
+				{metricWithLabels(t, "name", "foo")},
+				{},
+				{metricWithLabels(t, "name", "foo")},
+			},
+			map[uint64]*notifier.Alert{
+				hash(metricWithLabels(t, "name", "foo")): {State: notifier.StateFiring},
+			},
+		},
+		{
+			newTestAlertingRuleWithKeepFiring("for-fired=>keep-firing=>inactive", 0, defaultStep),
+			[][]datasource.Metric{
+				{metricWithLabels(t, "name", "foo")},
+				// alert is kept firing for defaultStep
+				{},
+				{},
+			},
+			map[uint64]*notifier.Alert{
+				hash(metricWithLabels(t, "name", "foo")): {State: notifier.StateInactive},
+			},
+		},
 	}
 	fakeGroup := Group{Name: "TestRule_Exec"}
 	for _, tc := range testCases {
@@ -303,9 +340,16 @@ func TestAlertingRule_Exec(t *testing.T) {
 			for _, step := range tc.steps {
 				fq.reset()
 				fq.add(step...)
-				if _, err := tc.rule.Exec(context.TODO()); err != nil {
+				if _, err := tc.rule.Exec(context.TODO(), time.Now(), 0); err != nil {
 					t.Fatalf("unexpected err: %s", err)
 				}
+				// emulate successful sending of resolved alerts,
+				// so they are removed on the next Exec
+				for _, a := range tc.rule.alerts {
+					if a.State == notifier.StateInactive {
+						a.ResolvedSent++
+					}
+				}
 				// artificial delay between applying steps
 				time.Sleep(defaultStep)
 			}
@@ -607,14 +651,14 @@ func TestAlertingRule_Exec_Negative(t *testing.T) {
 
 	// successful attempt
 	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "bar"))
-	_, err := ar.Exec(context.TODO())
+	_, err := ar.Exec(context.TODO(), time.Now(), 0)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// label `job` will collide with rule extra label and will make both time series equal
 	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "baz"))
-	_, err = ar.Exec(context.TODO())
+	_, err = ar.Exec(context.TODO(), time.Now(), 0)
 	if !errors.Is(err, errDuplicate) {
 		t.Fatalf("expected to have %s error; got %s", errDuplicate, err)
 	}
@@ -623,13 +667,199 @@ func TestAlertingRule_Exec_Negative(t *testing.T) {
 
 	expErr := "connection reset by peer"
 	fq.setErr(errors.New(expErr))
-	_, err = ar.Exec(context.TODO())
+	_, err = ar.Exec(context.TODO(), time.Now(), 0)
 	if err == nil {
 		t.Fatalf("expected to get err; got nil")
 	}
 	if !strings.Contains(err.Error(), expErr) {
 		t.Fatalf("expected to get err %q; got %q insterad", expErr, err)
 	}
+	// errDuplicate and the query error are counted as consecutive failures
+	if ar.lastExecFailures != 2 {
+		t.Fatalf("expected to have 2 consecutive failures; got %d", ar.lastExecFailures)
+	}
+	if _, err := ar.Exec(context.TODO(), time.Now(), 0); err == nil {
+		t.Fatalf("expected to get err; got nil")
+	}
+	if got := ar.RuleAPI().LastFailures; got != 3 {
+		t.Fatalf("expected to have 3 consecutive failures in API; got %d", got)
+	}
+
+	fq.reset()
+	if _, err := ar.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if ar.lastExecError != nil || ar.lastExecFailures != 0 {
+		t.Fatalf("expected error to be cleared after successful evaluation; got %v and %d failures",
+			ar.lastExecError, ar.lastExecFailures)
+	}
+}
+
+func TestAlertingRule_Limit(t *testing.T) {
+	fq := &fakeQuerier{}
+	ar := newTestAlertingRule("test", 0)
+	ar.q = fq
+
+	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "bar"))
+	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "baz"))
+	if _, err := ar.Exec(context.TODO(), time.Now(), 2); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(ar.alerts) != 2 {
+		t.Fatalf("expected to have 2 alerts; got %d", len(ar.alerts))
+	}
+
+	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "qux"))
+	_, err := ar.Exec(context.TODO(), time.Now(), 2)
+	if !errors.Is(err, errLimitExceeded) {
+		t.Fatalf("expected to have %s error; got %s", errLimitExceeded, err)
+	}
+	if !errors.Is(ar.lastExecError, errLimitExceeded) {
+		t.Fatalf("expected lastExecError to be %s; got %s", errLimitExceeded, ar.lastExecError)
+	}
+	if len(ar.alerts) != 2 {
+		t.Fatalf("expected alerts state to remain unchanged; got %d alerts", len(ar.alerts))
+	}
+
+	if _, err := ar.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err with disabled limit: %s", err)
+	}
+}
+
+func TestAlertingRule_MaxActiveAlerts(t *testing.T) {
+	defer func(v int) { *maxActiveAlerts = v }(*maxActiveAlerts)
+	*maxActiveAlerts = 3
+
+	fq1, fq2 := &fakeQuerier{}, &fakeQuerier{}
+	ar1, ar2 := newTestAlertingRule("foo", 0), newTestAlertingRule("bar", 0)
+	ar1.q, ar2.q = fq1, fq2
+	defer activeAlerts.remove(ar1)
+	defer activeAlerts.remove(ar2)
+
+	fq1.add(metricWithValueAndLabels(t, 1, "job", "a"))
+	fq1.add(metricWithValueAndLabels(t, 1, "job", "b"))
+	if _, err := ar1.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	fq2.add(metricWithValueAndLabels(t, 1, "job", "a"))
+	fq2.add(metricWithValueAndLabels(t, 1, "job", "b"))
+	suppressed := alertsSuppressed.Get()
+	if _, err := ar2.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(ar1.alerts) != 2 || len(ar2.alerts) != 1 {
+		t.Fatalf("expected to have 2 and 1 alerts; got %d and %d", len(ar1.alerts), len(ar2.alerts))
+	}
+	if got := alertsSuppressed.Get() - suppressed; got != 1 {
+		t.Fatalf("expected 1 suppressed alert; got %d", got)
+	}
+
+	// already active alerts must remain active
+	fq1.add(metricWithValueAndLabels(t, 1, "job", "c"))
+	if _, err := ar1.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(ar1.alerts) != 2 {
+		t.Fatalf("expected to have 2 alerts; got %d", len(ar1.alerts))
+	}
+
+	// resolved alerts free the capacity
+	fq1.reset()
+	if _, err := ar1.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if _, err := ar2.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(ar2.alerts) != 2 {
+		t.Fatalf("expected to have 2 alerts; got %d", len(ar2.alerts))
+	}
+}
+
+func TestAlertingRule_TemplatedLabels(t *testing.T) {
+	fq := &fakeQuerier{}
+	ar := newTestAlertingRule("test", 0)
+	ar.Labels = map[string]string{
+		"severity": "{{ if gt $value 0.95 }}critical{{ else }}warning{{ end }}",
+	}
+	ar.q = fq
+
+	f := func(value float64, expSeverity string) *notifier.Alert {
+		t.Helper()
+		fq.reset()
+		fq.add(metricWithValueAndLabels(t, value, "instance", "foo"))
+		if _, err := ar.Exec(context.TODO(), time.Now(), 0); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		for _, a := range ar.alerts {
+			if a.State != notifier.StateInactive {
+				if a.Labels["severity"] != expSeverity {
+					t.Fatalf("expected severity %q; got %q", expSeverity, a.Labels["severity"])
+				}
+				return a
+			}
+		}
+		t.Fatalf("expected to have an active alert")
+		return nil
+	}
+
+	warning := f(0.5, "warning")
+	critical := f(0.99, "critical")
+	// rendered labels are a part of alert's identity
+	if warning.ID == critical.ID {
+		t.Fatalf("expected alerts with different severity to have different IDs")
+	}
+	if warning.State != notifier.StateInactive {
+		t.Fatalf("expected warning alert to be resolved; got state %s", warning.State)
+	}
+}
+
+func TestAlertingRule_NoData(t *testing.T) {
+	fq := &fakeQuerier{}
+	ar := newTestAlertingRule("test", 0)
+	ar.q = fq
+	ar.NoData = config.NoDataError
+	if _, err := ar.Exec(context.TODO(), time.Now(), 0); !errors.Is(err, errNoData) {
+		t.Fatalf("expected to get %s error; got %v", errNoData, err)
+	}
+	if !errors.Is(ar.lastExecError, errNoData) {
+		t.Fatalf("expected rule to be marked as failed; got %v", ar.lastExecError)
+	}
+
+	ar = newTestAlertingRule("test", time.Minute)
+	ar.q = fq
+	ar.NoData = config.NoDataAlert
+	ts := time.Now()
+	f := func(ts time.Time, expState notifier.AlertState) {
+		t.Helper()
+		if _, err := ar.Exec(context.TODO(), ts, 0); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if len(ar.alerts) != 1 {
+			t.Fatalf("expected to have 1 alert; got %d", len(ar.alerts))
+		}
+		for _, a := range ar.alerts {
+			if a.Labels[noDataLabel] != "true" {
+				t.Fatalf("expected alert to have %s=\"true\" label; got %v", noDataLabel, a.Labels)
+			}
+			if a.State != expState {
+				t.Fatalf("expected alert state %s; got %s", expState, a.State)
+			}
+		}
+	}
+	// no_data alert respects `for`
+	f(ts, notifier.StatePending)
+	f(ts.Add(time.Minute), notifier.StateFiring)
+	// and is resolved once data is back
+	fq.add(metricWithValueAndLabels(t, 1, "instance", "foo"))
+	if _, err := ar.Exec(context.TODO(), ts.Add(2*time.Minute), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	for _, a := range ar.alerts {
+		if a.Labels[noDataLabel] == "true" && a.State != notifier.StateInactive {
+			t.Fatalf("expected no_data alert to be resolved; got state %s", a.State)
+		}
+	}
 }
 
 func TestAlertingRule_Template(t *testing.T) {
@@ -701,6 +931,28 @@ func TestAlertingRule_Template(t *testing.T) {
 				},
 			},
 		},
+		{
+			&AlertingRule{
+				Name: "alert ID",
+				Annotations: map[string]string{
+					"id": `{{ $alertID }}`,
+				},
+				alerts: make(map[uint64]*notifier.Alert),
+			},
+			[]datasource.Metric{
+				metricWithValueAndLabels(t, 1, "instance", "foo"),
+			},
+			map[uint64]*notifier.Alert{
+				hash(metricWithLabels(t, "instance", "foo")): {
+					Labels: map[string]string{
+						"instance": "foo",
+					},
+					Annotations: map[string]string{
+						"id": fmt.Sprintf("%d", hash(metricWithLabels(t, "instance", "foo"))),
+					},
+				},
+			},
+		},
 	}
 	fakeGroup := Group{Name: "TestRule_Exec"}
 	for _, tc := range testCases {
@@ -709,7 +961,7 @@ func TestAlertingRule_Template(t *testing.T) {
 			tc.rule.GroupID = fakeGroup.ID()
 			tc.rule.q = fq
 			fq.add(tc.metrics...)
-			if _, err := tc.rule.Exec(context.TODO()); err != nil {
+			if _, err := tc.rule.Exec(context.TODO(), time.Now(), 0); err != nil {
 				t.Fatalf("unexpected err: %s", err)
 			}
 			for hash, expAlert := range tc.expAlerts {
@@ -741,3 +993,37 @@ func newTestRuleWithLabels(name string, labels ...string) *AlertingRule {
 func newTestAlertingRule(name string, waitFor time.Duration) *AlertingRule {
 	return &AlertingRule{Name: name, alerts: make(map[uint64]*notifier.Alert), For: waitFor, EvalInterval: waitFor}
 }
+
+func newTestAlertingRuleWithKeepFiring(name string, waitFor, keepFiringFor time.Duration) *AlertingRule {
+	rule := newTestAlertingRule(name, waitFor)
+	rule.KeepFiringFor = keepFiringFor
+	return rule
+}
+
+func TestAlertingRule_ForAfterMissedIterations(t *testing.T) {
+	fq := &fakeQuerier{}
+	fq.add(metricWithLabels(t, "name", "foo"))
+	ar := newTestAlertingRule("for-pending=>firing", 5*time.Minute)
+	ar.EvalInterval = time.Minute
+	ar.q = fq
+
+	ts := time.Now()
+	if _, err := ar.Exec(context.Background(), ts, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// the process was paused, so evaluations between
+	// ts and ts+5m are missed; the alert must fire anyway,
+	// since `for` depends on the time elapsed since ActiveAt
+	ts = ts.Add(5 * time.Minute)
+	if _, err := ar.Exec(context.Background(), ts, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(ar.alerts) != 1 {
+		t.Fatalf("expected to have 1 alert; got %d", len(ar.alerts))
+	}
+	for _, a := range ar.alerts {
+		if a.State != notifier.StateFiring {
+			t.Fatalf("expected alert to be firing; got %s", a.State)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// activeAlerts tracks the number of active alerts
+// across all alerting rules for -rule.maxActiveAlerts
+var activeAlerts = &activeAlertsLimiter{
+	rules: make(map[*AlertingRule]*ruleActiveAlerts),
+}
+
+var alertsSuppressed = metrics.NewCounter(`vmalert_alerts_suppressed_total`)
+
+// activeAlertsLogInterval is the min interval between
+// log messages about suppressed alerts
+const activeAlertsLogInterval = time.Minute
+
+// activeAlertsLimiter limits the total number of active
+// (pending and firing) alerts across all alerting rules.
+// It is no-op if -rule.maxActiveAlerts isn't set.
+type activeAlertsLimiter struct {
+	mu      sync.Mutex
+	total   int
+	rules   map[*AlertingRule]*ruleActiveAlerts
+	lastLog time.Time
+}
+
+type ruleActiveAlerts struct {
+	group string
+	name  string
+	count int
+}
+
+// set sets the number of active alerts for the given rule.
+func (l *activeAlertsLimiter) set(ar *AlertingRule, n int) {
+	if *maxActiveAlerts <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ra, ok := l.rules[ar]
+	if !ok {
+		ra = &ruleActiveAlerts{}
+		l.rules[ar] = ra
+	}
+	ra.group, ra.name = ar.GroupName, ar.Name
+	l.total += n - ra.count
+	ra.count = n
+}
+
+// tryInc increments the number of active alerts for the given rule
+// and returns true if the limit allows creating a new alert.
+func (l *activeAlertsLimiter) tryInc(ar *AlertingRule) bool {
+	limit := *maxActiveAlerts
+	if limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.total >= limit {
+		return false
+	}
+	ra, ok := l.rules[ar]
+	if !ok {
+		ra = &ruleActiveAlerts{group: ar.GroupName, name: ar.Name}
+		l.rules[ar] = ra
+	}
+	ra.count++
+	l.total++
+	return true
+}
+
+// remove forgets active alerts of the given rule.
+// It must be called when rule is removed.
+func (l *activeAlertsLimiter) remove(ar *AlertingRule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ra, ok := l.rules[ar]
+	if !ok {
+		return
+	}
+	l.total -= ra.count
+	delete(l.rules, ar)
+}
+
+// suppressed registers n alerts of the given rule which weren't
+// created because of the limit and logs the top contributors.
+func (l *activeAlertsLimiter) suppressed(ar *AlertingRule, n int) {
+	alertsSuppressed.Add(n)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.lastLog) < activeAlertsLogInterval {
+		return
+	}
+	l.lastLog = time.Now()
+	logger.Warnf("rule %q:%q: suppressed creation of %d new alerts because the number of active alerts reached -rule.maxActiveAlerts=%d; "+
+		"top rules by the number of active alerts: %s", ar.GroupName, ar.Name, n, *maxActiveAlerts, l.topRules(5))
+}
+
+// topRules returns n rules with the highest number of active alerts.
+// It must be called with l.mu held.
+func (l *activeAlertsLimiter) topRules(n int) string {
+	rules := make([]*ruleActiveAlerts, 0, len(l.rules))
+	for _, ra := range l.rules {
+		rules = append(rules, ra)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].count == rules[j].count {
+			return rules[i].group+rules[i].name < rules[j].group+rules[j].name
+		}
+		return rules[i].count > rules[j].count
+	})
+	if len(rules) > n {
+		rules = rules[:n]
+	}
+	s := make([]string, len(rules))
+	for i, ra := range rules {
+		s[i] = fmt.Sprintf("%q:%q (%d)", ra.group, ra.name, ra.count)
+	}
+	return strings.Join(s, ", ")
+}
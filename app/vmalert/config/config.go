@@ -2,11 +2,13 @@ package config
 
 import (
 	"crypto/md5"
+	"errors"
+	"flag"
 	"fmt"
 	"hash/fnv"
-	"io/ioutil"
-	"path/filepath"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
@@ -18,15 +20,35 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+var strictParse = flag.Bool("rule.strictParse", true, "Whether to fail parsing of -rule files containing unknown fields, "+
+	"duplicate keys or rules with range vector expressions such as up[5m]. Disable it for files with extra fields, which must be ignored by vmalert. "+
+	"Rules with range vector expressions are logged as warnings in this case")
+
+var evalEnvVars = flag.Bool("rule.evalEnvVars", false, "Whether to fail parsing of -rule files containing %{ENV_VAR} placeholders for missing env vars. "+
+	"Placeholders are always substituted with the corresponding env vars. By default, placeholders for missing env vars are left as is")
+
 // Group contains list of Rules grouped into
 // entity with one name and evaluation interval
 type Group struct {
-	Type        datasource.Type `yaml:"type,omitempty"`
-	File        string
-	Name        string             `yaml:"name"`
-	Interval    utils.PromDuration `yaml:"interval,omitempty"`
-	Rules       []Rule             `yaml:"rules"`
-	Concurrency int                `yaml:"concurrency"`
+	Type     datasource.Type `yaml:"type,omitempty"`
+	File     string
+	Name     string             `yaml:"name"`
+	Interval utils.PromDuration `yaml:"interval,omitempty"`
+	// EvalOffset shifts the group evaluation moments from the interval
+	// boundaries. For example, interval=1h and eval_offset=5m means
+	// the group is evaluated at 5 minutes past each hour.
+	EvalOffset utils.PromDuration `yaml:"eval_offset,omitempty"`
+	// EvalDelay is subtracted from the evaluation timestamp
+	// before sending it to the datasource. If not set,
+	// the -rule.evalDelay flag value is used.
+	EvalDelay   *utils.PromDuration `yaml:"eval_delay,omitempty"`
+	Rules       []Rule              `yaml:"rules"`
+	Concurrency int                 `yaml:"concurrency"`
+	// Limit defines the max number of series a single rule
+	// of the group may produce during evaluation.
+	// If exceeded, all the results of the rule are discarded.
+	// 0 means no limit.
+	Limit int `yaml:"limit,omitempty"`
 	// ExtraFilterLabels is a list label filters applied to every rule
 	// request withing a group. Is compatible only with VM datasources.
 	// See https://docs.victoriametrics.com#prometheus-querying-api-enhancements
@@ -34,12 +56,33 @@ type Group struct {
 	// Labels is a set of label value pairs, that will be added to every rule.
 	// It has priority over the external labels.
 	Labels map[string]string `yaml:"labels"`
+	// Annotations is a set of annotations added to every alerting rule
+	// of the group unless the rule has an annotation with the same name.
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+	// Params is a set of GET params added to every
+	// datasource request for rules within the group
+	Params url.Values `yaml:"params"`
+	// Headers contains optional HTTP headers added to every
+	// datasource request for rules within the group
+	Headers []Header `yaml:"headers,omitempty"`
+	// Tenant is the tenant in the form `accountID[:projectID]`
+	// which is used for reading and writing data
+	// by rules within the group in the cluster version.
+	Tenant string `yaml:"tenant,omitempty"`
+	// DatasourceURL is an optional datasource url which overrides
+	// -datasource.url for rules within the group.
+	DatasourceURL string `yaml:"datasource_url,omitempty"`
+	// DatasourceBasicAuth contains optional basic auth credentials
+	// for DatasourceURL. Credentials set via flags for -datasource.url
+	// aren't sent to DatasourceURL.
+	DatasourceBasicAuth *BasicAuth `yaml:"datasource_basic_auth,omitempty"`
+	// Notifiers is an optional list of notifier names
+	// set via -notifier.name flag. If set, alerts of the group
+	// are sent only to the given notifiers instead of all of them.
+	Notifiers []string `yaml:"notifiers,omitempty"`
 	// Checksum stores the hash of yaml definition for this group.
 	// May be used to detect any changes like rules re-ordering etc.
 	Checksum string
-
-	// Catches all undefined fields and must be empty after parsing.
-	XXX map[string]interface{} `yaml:",inline"`
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -79,6 +122,50 @@ func (g *Group) Validate(validateAnnotations, validateExpressions bool) error {
 	if len(g.Rules) == 0 {
 		return fmt.Errorf("group %q can't contain no rules", g.Name)
 	}
+	// accumulate all the errors, so they can be fixed at once
+	errGroup := new(utils.ErrGroup)
+	if g.Limit < 0 {
+		errGroup.Add(fmt.Errorf("group %q: limit can't be negative; got %d", g.Name, g.Limit))
+	}
+	if g.Tenant != "" {
+		if err := ValidateTenant(g.Tenant); err != nil {
+			errGroup.Add(fmt.Errorf("group %q: %w", g.Name, err))
+		}
+	}
+	uniqueNotifiers := make(map[string]struct{}, len(g.Notifiers))
+	for _, name := range g.Notifiers {
+		if name == "" {
+			errGroup.Add(fmt.Errorf("group %q: notifier name can't be empty", g.Name))
+			continue
+		}
+		if _, ok := uniqueNotifiers[name]; ok {
+			errGroup.Add(fmt.Errorf("group %q: notifier %q duplicate", g.Name, name))
+		}
+		uniqueNotifiers[name] = struct{}{}
+	}
+	if g.DatasourceURL != "" {
+		if err := ValidateDatasourceURL(g.DatasourceURL); err != nil {
+			errGroup.Add(fmt.Errorf("group %q: %w", g.Name, err))
+		}
+	} else if g.DatasourceBasicAuth != nil {
+		errGroup.Add(fmt.Errorf("group %q: datasource_basic_auth can be set only with datasource_url", g.Name))
+	}
+	if g.EvalDelay != nil && g.EvalDelay.Duration() < 0 {
+		errGroup.Add(fmt.Errorf("group %q: eval_delay can't be negative; got %v", g.Name, g.EvalDelay.Duration()))
+	}
+	if offset := g.EvalOffset.Duration(); offset != 0 {
+		if offset < 0 {
+			errGroup.Add(fmt.Errorf("group %q: eval_offset can't be negative; got %v", g.Name, offset))
+		} else if interval := g.Interval.Duration(); interval > 0 && offset >= interval {
+			errGroup.Add(fmt.Errorf("group %q: eval_offset=%v must be less than interval=%v", g.Name, offset, interval))
+		}
+	}
+
+	if validateAnnotations {
+		if err := notifier.ValidateTemplates(g.Annotations); err != nil {
+			errGroup.Add(fmt.Errorf("invalid annotations for group %q: %w", g.Name, err))
+		}
+	}
 
 	uniqueRules := map[uint64]struct{}{}
 	for _, r := range g.Rules {
@@ -87,11 +174,13 @@ func (g *Group) Validate(validateAnnotations, validateExpressions bool) error {
 			ruleName = r.Alert
 		}
 		if _, ok := uniqueRules[r.ID]; ok {
-			return fmt.Errorf("rule %q duplicate", ruleName)
+			errGroup.Add(fmt.Errorf("rule %q duplicate", ruleName))
+			continue
 		}
 		uniqueRules[r.ID] = struct{}{}
 		if err := r.Validate(); err != nil {
-			return fmt.Errorf("invalid rule %q.%q: %w", g.Name, ruleName, err)
+			errGroup.Add(fmt.Errorf("invalid rule %q.%q: %w", g.Name, ruleName, err))
+			continue
 		}
 		if validateExpressions {
 			// its needed only for tests.
@@ -101,37 +190,141 @@ func (g *Group) Validate(validateAnnotations, validateExpressions bool) error {
 				exprValidator = r.Type.ValidateExpr
 			}
 			if err := exprValidator(r.Expr); err != nil {
-				return fmt.Errorf("invalid expression for rule %q.%q: %w", g.Name, ruleName, err)
+				errGroup.Add(fmt.Errorf("invalid expression for rule %q.%q: %w", g.Name, ruleName, err))
 			}
 		}
 		if validateAnnotations {
 			if err := notifier.ValidateTemplates(r.Annotations); err != nil {
-				return fmt.Errorf("invalid annotations for rule %q.%q: %w", g.Name, ruleName, err)
+				errGroup.Add(fmt.Errorf("invalid annotations for rule %q.%q: %w", g.Name, ruleName, err))
 			}
 			if err := notifier.ValidateTemplates(r.Labels); err != nil {
-				return fmt.Errorf("invalid labels for rule %q.%q: %w", g.Name, ruleName, err)
+				errGroup.Add(fmt.Errorf("invalid labels for rule %q.%q: %w", g.Name, ruleName, err))
 			}
 		}
 	}
-	return checkOverflow(g.XXX, fmt.Sprintf("group %q", g.Name))
+	return errGroup.Err()
+}
+
+// ValidateTenant checks whether the given tenant
+// has the form `accountID[:projectID]`.
+func ValidateTenant(tenant string) error {
+	n := strings.IndexByte(tenant, ':')
+	accountID, projectID := tenant, "0"
+	if n >= 0 {
+		accountID, projectID = tenant[:n], tenant[n+1:]
+	}
+	if _, err := strconv.ParseUint(accountID, 10, 32); err != nil {
+		return fmt.Errorf("cannot parse accountID from tenant %q: %w", tenant, err)
+	}
+	if _, err := strconv.ParseUint(projectID, 10, 32); err != nil {
+		return fmt.Errorf("cannot parse projectID from tenant %q: %w", tenant, err)
+	}
+	return nil
+}
+
+// ValidateDatasourceURL checks whether the given url
+// may be used as a datasource url for the group.
+// Credentials in the url aren't allowed, so they can't leak
+// via logs or API responses.
+func ValidateDatasourceURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		// do not print the url, since it may contain credentials
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fmt.Errorf("cannot parse datasource_url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("datasource_url must have http or https scheme; got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("datasource_url must contain host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("datasource_url can't contain credentials; use datasource_basic_auth instead")
+	}
+	return nil
+}
+
+// BasicAuth contains basic auth credentials
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Header is an HTTP header in the form `Name: value`
+type Header struct {
+	Key   string
+	Value string
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (h *Header) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	n := strings.IndexByte(s, ':')
+	if n < 0 {
+		return fmt.Errorf("missing ':' in header %q; expecting `Name: value` format", s)
+	}
+	h.Key = strings.TrimSpace(s[:n])
+	h.Value = strings.TrimSpace(s[n+1:])
+	if h.Key == "" {
+		return fmt.Errorf("header name can't be empty in %q", s)
+	}
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (h Header) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%s: %s", h.Key, h.Value), nil
 }
 
 // Rule describes entity that represent either
 // recording rule or alerting rule.
 type Rule struct {
-	ID          uint64
-	Type        datasource.Type    `yaml:"type,omitempty"`
-	Record      string             `yaml:"record,omitempty"`
-	Alert       string             `yaml:"alert,omitempty"`
-	Expr        string             `yaml:"expr"`
-	For         utils.PromDuration `yaml:"for"`
-	Labels      map[string]string  `yaml:"labels,omitempty"`
-	Annotations map[string]string  `yaml:"annotations,omitempty"`
-
-	// Catches all undefined fields and must be empty after parsing.
-	XXX map[string]interface{} `yaml:",inline"`
+	ID     uint64
+	Type   datasource.Type    `yaml:"type,omitempty"`
+	Record string             `yaml:"record,omitempty"`
+	Alert  string             `yaml:"alert,omitempty"`
+	Expr   string             `yaml:"expr"`
+	For    utils.PromDuration `yaml:"for"`
+	// KeepFiringFor defines for how long alert keeps firing
+	// after its expression stopped returning results.
+	KeepFiringFor utils.PromDuration `yaml:"keep_firing_for,omitempty"`
+	Labels        map[string]string  `yaml:"labels,omitempty"`
+	Annotations   map[string]string  `yaml:"annotations,omitempty"`
+	// Debug enables logging of the rule's datasource
+	// requests, responses and alerts state transitions.
+	Debug bool `yaml:"debug,omitempty"`
+	// UpdateEntriesLimit defines max number of rule's state updates stored in memory.
+	// Overrides `-rule.updateEntriesLimit` if set.
+	UpdateEntriesLimit *int `yaml:"update_entries_limit,omitempty"`
+	// Timeout limits the duration of the rule's query.
+	// Overrides `-datasource.queryTimeout` if set.
+	Timeout utils.PromDuration `yaml:"timeout,omitempty"`
+	// NoData defines the rule's behavior when its query
+	// returns no data. See NoData* constants.
+	NoData string `yaml:"no_data,omitempty"`
+	// HealthErrorsThreshold is the number of consecutive failed
+	// evaluations after which the rule is marked unhealthy.
+	// Overrides `-rule.healthErrorsThreshold` if set.
+	HealthErrorsThreshold int `yaml:"health_errors_threshold,omitempty"`
 }
 
+// Supported values for Rule.NoData
+const (
+	// NoDataOK treats empty query result as usual. It is the default.
+	NoDataOK = "ok"
+	// NoDataError marks the rule evaluation as failed.
+	NoDataError = "error"
+	// NoDataAlert triggers a single alert with `no_data="true"` label.
+	// It is supported only by alerting rules.
+	NoDataAlert = "alert"
+)
+
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
 func (r *Rule) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type rule Rule
@@ -180,38 +373,121 @@ func (r *Rule) Validate() error {
 	if r.Expr == "" {
 		return fmt.Errorf("expression can't be empty")
 	}
-	return checkOverflow(r.XXX, "rule")
+	if r.UpdateEntriesLimit != nil && *r.UpdateEntriesLimit < 0 {
+		return fmt.Errorf("update_entries_limit cannot be negative")
+	}
+	if r.Timeout.Duration() < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	if r.HealthErrorsThreshold < 0 {
+		return fmt.Errorf("health_errors_threshold cannot be negative")
+	}
+	switch r.NoData {
+	case "", NoDataOK, NoDataError:
+	case NoDataAlert:
+		if r.Alert == "" {
+			return fmt.Errorf("no_data: %q is supported only by alerting rules", NoDataAlert)
+		}
+	default:
+		return fmt.Errorf("unsupported no_data value %q; expecting one of %q, %q or %q", r.NoData, NoDataOK, NoDataError, NoDataAlert)
+	}
+	return nil
 }
 
+// ErrNoFiles is returned by ParseNonEmpty if the given
+// file patterns match no files
+var ErrNoFiles = errors.New("no files found by the given patterns")
+
 // Parse parses rule configs from given file patterns
 func Parse(pathPatterns []string, validateAnnotations, validateExpressions bool) ([]Group, error) {
-	var fp []string
-	for _, pattern := range pathPatterns {
-		matches, err := filepath.Glob(pattern)
-		if err != nil {
-			return nil, fmt.Errorf("error reading file pattern %s: %w", pattern, err)
-		}
-		fp = append(fp, matches...)
+	return parseFiles(pathPatterns, validateAnnotations, validateExpressions, false)
+}
+
+// ParseNonEmpty is like Parse, but returns ErrNoFiles if the patterns match no files.
+// Files are listed and read at once, so the check can't race with files changes.
+func ParseNonEmpty(pathPatterns []string, validateAnnotations, validateExpressions bool) ([]Group, error) {
+	return parseFiles(pathPatterns, validateAnnotations, validateExpressions, true)
+}
+
+func parseFiles(pathPatterns []string, validateAnnotations, validateExpressions, nonEmpty bool) ([]Group, error) {
+	files, err := readFromFS(pathPatterns)
+	if nonEmpty && err == nil && len(files) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrNoFiles, strings.Join(pathPatterns, ";"))
 	}
 	errGroup := new(utils.ErrGroup)
+	for _, err := range flattenErrors(err) {
+		errGroup.Add(fmt.Errorf("failed to read from the config: %w", err))
+	}
+	// parse the files which were read successfully,
+	// so their errors are reported together with read errors
+	groups, err := parse(files, validateAnnotations, validateExpressions, pathPatterns)
+	for _, err := range flattenErrors(err) {
+		errGroup.Add(err)
+	}
+	if err := errGroup.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// flattenErrors returns the list of errors accumulated by err
+// if it is utils.ErrGroup. Otherwise, err itself is returned.
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var eg *utils.ErrGroup
+	if errors.As(err, &eg) {
+		return eg.Errors()
+	}
+	return []error{err}
+}
+
+// parse parses rule configs from the given files content,
+// where key define the file name.
+func parse(files map[string][]byte, validateAnnotations, validateExpressions bool, pathPatterns []string) ([]Group, error) {
+	fp := make([]string, 0, len(files))
+	for file := range files {
+		fp = append(fp, file)
+	}
+	// sort files to get stable groups order
+	sort.Strings(fp)
+	errGroup := new(utils.ErrGroup)
 	var groups []Group
+	// group names must be unique across all the files,
+	// the value is the file the group was loaded from
+	uniqueGroups := map[string]string{}
 	for _, file := range fp {
-		uniqueGroups := map[string]struct{}{}
-		gr, err := parseFile(file)
+		gr, err := parseConfig(file, files[file])
 		if err != nil {
 			errGroup.Add(fmt.Errorf("failed to parse file %q: %w", file, err))
 			continue
 		}
 		for _, g := range gr {
 			if err := g.Validate(validateAnnotations, validateExpressions); err != nil {
-				errGroup.Add(fmt.Errorf("invalid group %q in file %q: %w", g.Name, file, err))
+				for _, err := range flattenErrors(err) {
+					errGroup.Add(fmt.Errorf("invalid group %q in file %q: %w", g.Name, file, err))
+				}
 				continue
 			}
-			if _, ok := uniqueGroups[g.Name]; ok {
-				errGroup.Add(fmt.Errorf("group name %q duplicate in file %q", g.Name, file))
+			if prev, ok := uniqueGroups[g.Name]; ok {
+				if prev == file {
+					errGroup.Add(fmt.Errorf("group name %q duplicate in file %q", g.Name, file))
+				} else {
+					errGroup.Add(fmt.Errorf("group name %q duplicate in files %q and %q", g.Name, prev, file))
+				}
 				continue
 			}
-			uniqueGroups[g.Name] = struct{}{}
+			uniqueGroups[g.Name] = file
+			if validateExpressions {
+				for _, err := range rangeVectorErrors(g, file) {
+					if *strictParse {
+						errGroup.Add(err)
+						continue
+					}
+					logger.Warnf("%s", err)
+				}
+			}
 			g.File = file
 			groups = append(groups, g)
 		}
@@ -225,33 +501,52 @@ func Parse(pathPatterns []string, validateAnnotations, validateExpressions bool)
 	return groups, nil
 }
 
-func parseFile(path string) ([]Group, error) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("error reading alert rule file: %w", err)
+// rangeVectorErrors returns errors for rules of g with expressions
+// returning range vectors, since rules expect instant vectors.
+func rangeVectorErrors(g Group, file string) []error {
+	var errs []error
+	for _, r := range g.Rules {
+		t := g.Type
+		if r.Type.Get() != "" {
+			t = r.Type
+		}
+		if t.IsRangeVectorExpr(r.Expr) {
+			errs = append(errs, fmt.Errorf("rule %q.%q in file %q: expression %q returns range vector, while rules expect instant vector",
+				g.Name, r.Name(), file, r.Expr))
+		}
+	}
+	return errs
+}
+
+func parseConfig(file string, data []byte) ([]Group, error) {
+	if *evalEnvVars {
+		var err error
+		data, err = envtemplate.ReplaceStrict(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot expand environment vars: %w", err)
+		}
+	} else {
+		data = envtemplate.Replace(data)
 	}
-	data = envtemplate.Replace(data)
 	g := struct {
 		Groups []Group `yaml:"groups"`
-		// Catches all undefined fields and must be empty after parsing.
-		XXX map[string]interface{} `yaml:",inline"`
 	}{}
-	err = yaml.Unmarshal(data, &g)
-	if err != nil {
-		return nil, err
-	}
-	return g.Groups, checkOverflow(g.XXX, "config")
-}
-
-func checkOverflow(m map[string]interface{}, ctx string) error {
-	if len(m) > 0 {
-		var keys []string
-		for k := range m {
-			keys = append(keys, k)
+	if isJSONFile(file) {
+		if err := parseJSONConfig(data, &g); err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("unknown fields in %s: %s", ctx, strings.Join(keys, ", "))
+		return g.Groups, nil
 	}
-	return nil
+	unmarshal := yaml.Unmarshal
+	if *strictParse {
+		// UnmarshalStrict returns an error with the line number
+		// and the name of every unknown field
+		unmarshal = yaml.UnmarshalStrict
+	}
+	if err := unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	return g.Groups, nil
 }
 
 type item struct {
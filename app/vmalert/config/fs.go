@@ -0,0 +1,176 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config/fsgcs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config/fslocal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config/fss3"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config/fsstdin"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config/fsurl"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+)
+
+// FS represent a file system abstract for reading files.
+type FS interface {
+	// Init initializes FS.
+	Init() error
+
+	// String must return human-readable representation of FS.
+	String() string
+
+	// List returns the list of file names served by FS.
+	List() ([]string, error)
+
+	// Read returns a map of read files where
+	// key is the file name and value is file's content.
+	Read(files []string) (map[string][]byte, error)
+}
+
+var urlTimeout = flag.Duration("rule.urlTimeout", 30*time.Second, "Timeout for reading -rule files via http://, https://, s3:// or gs:// URLs")
+
+var (
+	fsRegistryMu sync.Mutex
+	fsRegistry   = make(map[string]FS)
+)
+
+// StdinPath is the path for reading rules from the standard input
+const StdinPath = "-"
+
+// IsStdin returns true if paths refer to the standard input.
+// It returns an error if StdinPath is mixed with other paths,
+// since stdin may contain only a single file.
+func IsStdin(paths []string) (bool, error) {
+	for _, path := range paths {
+		if path != StdinPath {
+			continue
+		}
+		if len(paths) > 1 {
+			return false, fmt.Errorf("%q path for reading from stdin cannot be mixed with other paths; got %q",
+				StdinPath, strings.Join(paths, ";"))
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// newFS returns FS for the given path.
+// Path may be a file pattern, StdinPath or an URL with
+// `http://`, `https://`, `s3://` or `gs://` scheme.
+// Initialized FS are cached, so every path is
+// initialized only once.
+func newFS(path string) (FS, error) {
+	fsRegistryMu.Lock()
+	defer fsRegistryMu.Unlock()
+
+	if fs, ok := fsRegistry[path]; ok {
+		return fs, nil
+	}
+	var fs FS
+	switch {
+	case path == StdinPath:
+		fs = &fsstdin.FS{}
+	case strings.HasPrefix(path, "http://"), strings.HasPrefix(path, "https://"):
+		fs = &fsurl.FS{URL: path, Timeout: *urlTimeout}
+	case strings.HasPrefix(path, "s3://"):
+		fs = &fss3.FS{Path: path, Timeout: *urlTimeout}
+	case strings.HasPrefix(path, "gs://"):
+		fs = &fsgcs.FS{Path: path, Timeout: *urlTimeout}
+	default:
+		fs = &fslocal.FS{Pattern: path}
+	}
+	if err := fs.Init(); err != nil {
+		return nil, fmt.Errorf("cannot initialize %s: %w", fs, err)
+	}
+	fsRegistry[path] = fs
+	return fs, nil
+}
+
+// ListFiles returns the sorted list of files
+// matching the given paths. Files matched by multiple
+// paths are returned only once.
+func ListFiles(paths []string) ([]string, error) {
+	if _, err := IsStdin(paths); err != nil {
+		return nil, err
+	}
+	var files []string
+	seen := make(map[string]struct{})
+	for _, path := range paths {
+		fs, err := newFS(path)
+		if err != nil {
+			return nil, err
+		}
+		list, err := fs.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files from %s: %w", fs, err)
+		}
+		files = append(files, dedupFiles(fs, list, seen)...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// dedupFiles returns files from list which are missing in seen
+// and adds them to seen. Local files are compared by absolute paths,
+// so `rules/a.yaml` and `./rules/a.yaml` are treated as the same file.
+func dedupFiles(fs FS, list []string, seen map[string]struct{}) []string {
+	var res []string
+	for _, file := range list {
+		key := file
+		if _, ok := fs.(*fslocal.FS); ok {
+			if abs, err := filepath.Abs(file); err == nil {
+				key = abs
+			}
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		res = append(res, file)
+	}
+	return res
+}
+
+// readFromFS returns contents of files matching the given paths.
+// The returned map key is the file name.
+func readFromFS(paths []string) (map[string][]byte, error) {
+	if _, err := IsStdin(paths); err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte)
+	seen := make(map[string]struct{})
+	// continue reading the rest of paths on error,
+	// so the files which were read can be validated
+	errGroup := new(utils.ErrGroup)
+	for _, path := range paths {
+		fs, err := newFS(path)
+		if err != nil {
+			errGroup.Add(err)
+			continue
+		}
+		list, err := fs.List()
+		if err != nil {
+			errGroup.Add(fmt.Errorf("failed to list files from %s: %w", fs, err))
+			continue
+		}
+		list = dedupFiles(fs, list, seen)
+		if len(list) == 0 {
+			continue
+		}
+		files, err := fs.Read(list)
+		if err != nil {
+			errGroup.Add(fmt.Errorf("failed to read files from %s: %w", fs, err))
+			continue
+		}
+		for k, v := range files {
+			result[k] = v
+		}
+	}
+	return result, errGroup.Err()
+}
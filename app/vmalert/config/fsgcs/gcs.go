@@ -0,0 +1,90 @@
+package fsgcs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// FS represents a struct which can read an object from GCS.
+// Default credentials are used for accessing GCS.
+type FS struct {
+	// Path defines the object path in form `gs://bucket/path/to/file`
+	Path string
+	// Timeout limits reading of every object if set
+	Timeout time.Duration
+
+	client *storage.Client
+}
+
+// Init parses the configured Path and initializes GCS client
+func (fs *FS) Init() error {
+	if _, _, err := parsePath(fs.Path); err != nil {
+		return err
+	}
+	// the context is used by the client for refreshing auth tokens
+	// during its lifetime, so it mustn't be limited by Timeout
+	c, err := storage.NewClient(context.Background())
+	if err != nil {
+		return fmt.Errorf("cannot create default gcs client: %w", err)
+	}
+	fs.client = c
+	return nil
+}
+
+// String implements Stringer interface
+func (fs *FS) String() string {
+	return fmt.Sprintf("GCS FS{Path: %q}", fs.Path)
+}
+
+// List returns the list of file names which will be read via Read fn
+// List isn't supported by FS and reads from Path only
+func (fs *FS) List() ([]string, error) {
+	return []string{fs.Path}, nil
+}
+
+// Read returns a map of read files where
+// key is the file name and value is file's content.
+func (fs *FS) Read(files []string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	for _, file := range files {
+		bucket, object, err := parsePath(file)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.read(bucket, object)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %q: %w", file, err)
+		}
+		result[file] = data
+	}
+	return result, nil
+}
+
+func (fs *FS) read(bucket, object string) ([]byte, error) {
+	ctx := context.Background()
+	if fs.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fs.Timeout)
+		defer cancel()
+	}
+	r, err := fs.client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return ioutil.ReadAll(r)
+}
+
+func parsePath(path string) (string, string, error) {
+	s := strings.TrimPrefix(path, "gs://")
+	n := strings.IndexByte(s, '/')
+	if n <= 0 || n == len(s)-1 {
+		return "", "", fmt.Errorf("missing bucket or object name in %q; expecting `gs://bucket/path/to/file` format", path)
+	}
+	return s[:n], s[n+1:], nil
+}
@@ -0,0 +1,112 @@
+package fss3
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// FS represents a struct which can read an object from S3.
+// Credentials and configs are loaded from default locations.
+type FS struct {
+	// Path defines the object path in form `s3://bucket/path/to/file`
+	Path string
+	// Timeout limits every request to S3 if set
+	Timeout time.Duration
+
+	bucket string
+	key    string
+	s3     *s3.S3
+}
+
+// Init parses the configured Path and initializes S3 client
+func (fs *FS) Init() error {
+	bucket, key, err := parsePath(fs.Path)
+	if err != nil {
+		return err
+	}
+	fs.bucket, fs.key = bucket, key
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create S3 session: %w", err)
+	}
+	ctx, cancel := fs.newContext()
+	defer cancel()
+	region, err := s3manager.GetBucketRegion(ctx, sess, fs.bucket, "us-west-2")
+	if err != nil {
+		return fmt.Errorf("cannot determine region for bucket %q: %w", fs.bucket, err)
+	}
+	sess.Config.WithRegion(region)
+	fs.s3 = s3.New(sess)
+	return nil
+}
+
+// String implements Stringer interface
+func (fs *FS) String() string {
+	return fmt.Sprintf("S3 FS{Path: %q}", fs.Path)
+}
+
+// List returns the list of file names which will be read via Read fn
+// List isn't supported by FS and reads from Path only
+func (fs *FS) List() ([]string, error) {
+	return []string{fs.Path}, nil
+}
+
+// Read returns a map of read files where
+// key is the file name and value is file's content.
+func (fs *FS) Read(files []string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	for _, file := range files {
+		bucket, key, err := parsePath(file)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.read(bucket, key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %q: %w", file, err)
+		}
+		result[file] = data
+	}
+	return result, nil
+}
+
+func (fs *FS) read(bucket, key string) ([]byte, error) {
+	ctx, cancel := fs.newContext()
+	defer cancel()
+	o, err := fs.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = o.Body.Close() }()
+	return ioutil.ReadAll(o.Body)
+}
+
+// newContext returns context limited by fs.Timeout if it is set
+func (fs *FS) newContext() (context.Context, context.CancelFunc) {
+	if fs.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), fs.Timeout)
+}
+
+func parsePath(path string) (string, string, error) {
+	s := strings.TrimPrefix(path, "s3://")
+	n := strings.IndexByte(s, '/')
+	if n <= 0 || n == len(s)-1 {
+		return "", "", fmt.Errorf("missing bucket or object name in %q; expecting `s3://bucket/path/to/file` format", path)
+	}
+	return s[:n], s[n+1:], nil
+}
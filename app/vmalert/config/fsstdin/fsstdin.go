@@ -0,0 +1,58 @@
+package fsstdin
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// FileName is the name of the file with rules read from stdin
+const FileName = "stdin"
+
+// FS represents a single file read from the standard input.
+// The input is read once on Init, since it cannot be re-read.
+type FS struct {
+	// Reader is used for reading the file.
+	// os.Stdin is used if Reader is nil.
+	Reader io.Reader
+
+	data []byte
+}
+
+// Init reads the whole input
+func (fs *FS) Init() error {
+	r := fs.Reader
+	if r == nil {
+		r = os.Stdin
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("cannot read from stdin: %w", err)
+	}
+	fs.data = data
+	return nil
+}
+
+// String implements Stringer interface
+func (fs *FS) String() string {
+	return "Stdin FS"
+}
+
+// List returns the list of file names which will be read via Read fn
+func (fs *FS) List() ([]string, error) {
+	return []string{FileName}, nil
+}
+
+// Read returns a map of read files where
+// key is the file name and value is file's content.
+func (fs *FS) Read(files []string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	for _, f := range files {
+		if f != FileName {
+			return nil, fmt.Errorf("unexpected file %q; stdin serves only %q", f, FileName)
+		}
+		result[f] = fs.data
+	}
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package fsstdin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFSRead(t *testing.T) {
+	const data = `groups: []`
+	r := strings.NewReader(data)
+	fs := &FS{Reader: r}
+	if err := fs.Init(); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	// the input must be served on every read,
+	// since stdin can be read only once
+	for i := 0; i < 2; i++ {
+		files, err := fs.List()
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		result, err := fs.Read(files)
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if string(result[FileName]) != data {
+			t.Fatalf("expected to read %q; got %q", data, result[FileName])
+		}
+	}
+	if _, err := fs.Read([]string{"rules.yaml"}); err == nil {
+		t.Fatalf("expected to get error for unknown file")
+	}
+}
@@ -0,0 +1,82 @@
+package fsurl
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+)
+
+var (
+	headers = flag.String("rule.urlHeaders", "", "Optional HTTP headers to send with each request for reading -rule files via http:// or https:// URLs. "+
+		"For example, -rule.urlHeaders='Authorization: Bearer foobar' would send 'Authorization: Bearer foobar' HTTP header with every request. "+
+		"Multiple headers must be delimited by '^^': -rule.urlHeaders='header1:value1^^header2:value2'")
+)
+
+// FS represents a struct which can read content from URL
+type FS struct {
+	// URL defines URL to read the data from
+	URL string
+	// Timeout limits reading of the URL if set
+	Timeout time.Duration
+
+	headers map[string]string
+	client  *http.Client
+}
+
+// Init verifies that configured URL and headers are correct
+func (fs *FS) Init() error {
+	if _, err := http.NewRequest(http.MethodGet, fs.URL, nil); err != nil {
+		return fmt.Errorf("invalid URL %q: %w", fs.URL, err)
+	}
+	hs, err := utils.ParseHeaders(*headers)
+	if err != nil {
+		return fmt.Errorf("cannot parse -rule.urlHeaders: %w", err)
+	}
+	fs.headers = hs
+	fs.client = &http.Client{Timeout: fs.Timeout}
+	return nil
+}
+
+// String implements Stringer interface
+func (fs *FS) String() string {
+	return fmt.Sprintf("URL FS{URL: %q}", fs.URL)
+}
+
+// List returns the list of file names which will be read via Read fn
+// List isn't supported by FS and reads from URL only
+func (fs *FS) List() ([]string, error) {
+	return []string{fs.URL}, nil
+}
+
+// Read returns a map of read files where
+// key is the file name and value is file's content.
+func (fs *FS) Read(files []string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	for _, file := range files {
+		req, err := http.NewRequest(http.MethodGet, file, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create request for %q: %w", file, err)
+		}
+		for k, v := range fs.headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := fs.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch %q: %w", file, err)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected response code %d for %q. Response body %q", resp.StatusCode, file, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read response from %q: %w", file, err)
+		}
+		result[file] = data
+	}
+	return result, nil
+}
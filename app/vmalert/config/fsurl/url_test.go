@@ -0,0 +1,51 @@
+package fsurl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFSRead(t *testing.T) {
+	const data = `groups: []`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rules.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer foo" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(data))
+	}))
+	defer srv.Close()
+
+	oldHeaders := *headers
+	defer func() { *headers = oldHeaders }()
+	*headers = "Authorization: Bearer foo"
+
+	fs := &FS{URL: srv.URL + "/rules.yaml"}
+	if err := fs.Init(); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	files, err := fs.List()
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	result, err := fs.Read(files)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if string(result[fs.URL]) != data {
+		t.Fatalf("expected to get %q; got %q", data, result[fs.URL])
+	}
+
+	fs = &FS{URL: srv.URL + "/missing.yaml"}
+	if err := fs.Init(); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if _, err := fs.Read([]string{fs.URL}); err == nil {
+		t.Fatalf("expected to get err for missing file")
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+)
+
+// isJSONFile returns true if the file must be parsed as JSON
+func isJSONFile(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".json")
+}
+
+// parseJSONConfig parses JSON rule file with the same schema as YAML files.
+// Unknown fields are reported with their JSON paths if -rule.strictParse is set.
+func parseJSONConfig(data []byte, dst interface{}) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("cannot parse JSON: %w", err)
+	}
+	if *strictParse {
+		errGroup := new(utils.ErrGroup)
+		checkJSONFields(errGroup, v, reflect.TypeOf(dst), "$")
+		if err := errGroup.Err(); err != nil {
+			return err
+		}
+	}
+	// re-encode JSON into YAML in order to apply
+	// the same unmarshalers and defaults as for YAML files
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot convert JSON to YAML: %w", err)
+	}
+	if err := yaml.Unmarshal(b, dst); err != nil {
+		return fmt.Errorf("cannot decode JSON: %w", err)
+	}
+	return nil
+}
+
+// checkJSONFields adds an error to errGroup for every field of v
+// which has no corresponding field in the struct type t.
+func checkJSONFields(errGroup *utils.ErrGroup, v interface{}, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Map:
+			for _, k := range sortedKeys(v) {
+				checkJSONFields(errGroup, v[k], t.Elem(), fmt.Sprintf("%s[%q]", path, k))
+			}
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for _, k := range sortedKeys(v) {
+				ft, ok := fields[k]
+				if !ok {
+					errGroup.Add(fmt.Errorf("unknown field %q at %s", k, path))
+					continue
+				}
+				checkJSONFields(errGroup, v[k], ft, path+"."+k)
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, item := range v {
+			checkJSONFields(errGroup, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	// scalar values and type mismatches are checked during decoding
+}
+
+// yamlFields returns struct fields of t by their yaml names
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// skip unexported fields
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			// yaml.v2 uses lowercased field name by default
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+package datasource
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding is the list of response encodings supported by vmalert
+const acceptEncoding = "zstd, gzip"
+
+var (
+	responseWireBytes    = metrics.NewCounter(`vmalert_datasource_response_wire_bytes_total`)
+	responseDecodedBytes = metrics.NewCounter(`vmalert_datasource_response_decoded_bytes_total`)
+)
+
+// decompressResponse replaces resp.Body with the reader
+// decoding the body according to Content-Encoding header.
+// The number of read bytes before and after decoding
+// is counted by the corresponding metrics.
+func decompressResponse(resp *http.Response) error {
+	wire := &metricReader{r: resp.Body, c: responseWireBytes}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var body io.ReadCloser
+	switch encoding {
+	case "", "identity":
+		body = wire
+	case "gzip":
+		zr, err := gzip.NewReader(wire)
+		if err != nil {
+			return fmt.Errorf("cannot read gzip response: %w", err)
+		}
+		body = &decodingReader{r: zr, close: zr.Close, body: wire}
+	case "zstd":
+		zd, err := getZstdDecoder(wire)
+		if err != nil {
+			return fmt.Errorf("cannot read zstd response: %w", err)
+		}
+		body = &decodingReader{r: zd, close: func() error { putZstdDecoder(zd); return nil }, body: wire}
+	default:
+		return fmt.Errorf("unsupported Content-Encoding %q in response", encoding)
+	}
+	resp.Body = &metricReader{r: body, c: responseDecodedBytes}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+// zstdDecoderPool contains decoders for zstd responses,
+// since creating a decoder for every response is expensive
+var zstdDecoderPool sync.Pool
+
+// zstdDecoder wraps zstd.Decoder, so the decoder is closed
+// after the wrapper is dropped from zstdDecoderPool.
+// Otherwise, the decoding goroutine of the decoder would leak.
+type zstdDecoder struct {
+	*zstd.Decoder
+}
+
+func getZstdDecoder(r io.Reader) (*zstdDecoder, error) {
+	v := zstdDecoderPool.Get()
+	if v == nil {
+		// the response is read by a single goroutine,
+		// so there is no sense in concurrent decoding
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		zd := &zstdDecoder{Decoder: d}
+		runtime.SetFinalizer(zd, func(zd *zstdDecoder) { zd.Close() })
+		return zd, nil
+	}
+	zd := v.(*zstdDecoder)
+	if err := zd.Reset(r); err != nil {
+		return nil, err
+	}
+	return zd, nil
+}
+
+func putZstdDecoder(zd *zstdDecoder) {
+	// release the reference to the response body
+	_ = zd.Reset(nil)
+	zstdDecoderPool.Put(zd)
+}
+
+// metricReader adds the number of bytes read from r to c
+type metricReader struct {
+	r io.ReadCloser
+	c *metrics.Counter
+}
+
+func (mr *metricReader) Read(p []byte) (int, error) {
+	n, err := mr.r.Read(p)
+	mr.c.Add(n)
+	return n, err
+}
+
+func (mr *metricReader) Close() error { return mr.r.Close() }
+
+// decodingReader reads decoded body via r and
+// closes both the decoder and the original body
+type decodingReader struct {
+	r     io.Reader
+	close func() error
+	body  io.Closer
+}
+
+func (dr *decodingReader) Read(p []byte) (int, error) { return dr.r.Read(p) }
+
+func (dr *decodingReader) Close() error {
+	if dr.close != nil {
+		// the decoder may be re-used after closing,
+		// so it mustn't be closed twice
+		_ = dr.close()
+		dr.close = nil
+	}
+	return dr.body.Close()
+}
@@ -2,12 +2,14 @@ package datasource
 
 import (
 	"context"
+	"net/url"
 	"time"
 )
 
 // Querier interface wraps Query and QueryRange methods
 type Querier interface {
-	Query(ctx context.Context, query string) ([]Metric, error)
+	// Query executes instant query at the given evaluation timestamp
+	Query(ctx context.Context, query string, ts time.Time) ([]Metric, error)
 	QueryRange(ctx context.Context, query string, from, to time.Time) ([]Metric, error)
 }
 
@@ -20,8 +22,47 @@ type QuerierBuilder interface {
 type QuerierParams struct {
 	DataSourceType     *Type
 	EvaluationInterval time.Duration
+	// EvalOffset shifts the aligned evaluation timestamp
+	// from the EvaluationInterval boundaries
+	EvalOffset time.Duration
+	// EvalDelay is subtracted from the evaluation timestamp.
+	// If set, it overrides the datasource lookback.
+	EvalDelay time.Duration
 	// see https://docs.victoriametrics.com/#prometheus-querying-api-enhancements
 	ExtraLabels map[string]string
+	// QueryParams contains extra GET params which
+	// are added to every query request
+	QueryParams url.Values
+	// Headers contains extra HTTP headers which are added
+	// to every query request. They override the headers
+	// set via -datasource.headers flag
+	Headers map[string]string
+	// Tenant in the form `accountID[:projectID]`. If set,
+	// queries are sent to `/select/<tenant>/` path
+	// of the vmselect configured via datasource url.
+	Tenant string
+	// DatasourceURL overrides the datasource url if set.
+	// Basic auth credentials of the datasource are replaced
+	// with BasicAuthUser and BasicAuthPass in this case.
+	DatasourceURL string
+	BasicAuthUser string
+	BasicAuthPass string
+	// Debug enables logging of the requests to the datasource
+	Debug bool
+	// OnQuery is called with stats of every successful query if set
+	OnQuery func(QueryStats)
+}
+
+// QueryStats contains stats of a single query to the datasource
+type QueryStats struct {
+	Query string
+	// Duration is the time spent on the request
+	// including response parsing
+	Duration time.Duration
+	// ResponseBytes is the size of the response body
+	ResponseBytes int64
+	// Series is the number of series in the response
+	Series int
 }
 
 // Metric is the basic entity which should be return by datasource
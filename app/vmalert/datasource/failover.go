@@ -0,0 +1,107 @@
+package datasource
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// addrPool is the list of -datasource.url addresses.
+// Requests are sent to the first healthy address and
+// fail over to the next addresses on errors.
+type addrPool struct {
+	// cooldown is how long a failed address is deprioritized
+	cooldown time.Duration
+	addrs    []*datasourceAddr
+}
+
+type datasourceAddr struct {
+	u *url.URL
+
+	mu sync.Mutex
+	// unhealthyUntil is the time until the address
+	// is deprioritized because of the last failure
+	unhealthyUntil time.Time
+
+	requests *metrics.Counter
+	errors   *metrics.Counter
+}
+
+func newAddrPool(urls []string, cooldown time.Duration) (*addrPool, error) {
+	p := &addrPool{cooldown: cooldown}
+	for _, s := range urls {
+		u, err := url.Parse(strings.TrimSuffix(s, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse datasource.url %q: %w", s, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("datasource.url must have http or https scheme; got %q", u.Redacted())
+		}
+		p.addrs = append(p.addrs, &datasourceAddr{
+			u:        u,
+			requests: metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_datasource_requests_total{url=%q}`, u.Redacted())),
+			errors:   metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_datasource_errors_total{url=%q}`, u.Redacted())),
+		})
+	}
+	return p, nil
+}
+
+// primary returns the first configured address
+func (p *addrPool) primary() string {
+	return p.addrs[0].u.String()
+}
+
+// ordered returns addresses in the order they must be tried:
+// healthy addresses go first, addresses failed during
+// the cooldown period go last. The configured order is preserved
+// within both parts.
+func (p *addrPool) ordered() []*datasourceAddr {
+	now := time.Now()
+	res := make([]*datasourceAddr, 0, len(p.addrs))
+	var unhealthy []*datasourceAddr
+	for _, a := range p.addrs {
+		if a.isHealthy(now) {
+			res = append(res, a)
+		} else {
+			unhealthy = append(unhealthy, a)
+		}
+	}
+	return append(res, unhealthy...)
+}
+
+// request returns a copy of req targeting a instead of the primary address.
+// req must be built for the primary address.
+func (p *addrPool) request(req *http.Request, a *datasourceAddr) *http.Request {
+	if a == p.addrs[0] {
+		return req
+	}
+	u := *req.URL
+	u.Scheme, u.Host, u.User = a.u.Scheme, a.u.Host, a.u.User
+	// preserve the path added to the address, e.g. tenant and query type
+	u.Path = a.u.Path + strings.TrimPrefix(req.URL.Path, p.addrs[0].u.Path)
+	r := req.Clone(req.Context())
+	r.URL = &u
+	r.Host = ""
+	return r
+}
+
+func (a *datasourceAddr) isHealthy(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return !now.Before(a.unhealthyUntil)
+}
+
+func (a *datasourceAddr) setHealthy(ok bool, cooldown time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if ok {
+		a.unhealthyUntil = time.Time{}
+		return
+	}
+	a.unhealthyUntil = time.Now().Add(cooldown)
+}
@@ -4,17 +4,36 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 )
 
 var (
-	addr = flag.String("datasource.url", "", "VictoriaMetrics or vmselect url. Required parameter. "+
-		"E.g. http://127.0.0.1:8428")
-	appendTypePrefix  = flag.Bool("datasource.appendTypePrefix", false, "Whether to add type prefix to -datasource.url based on the query type. Set to true if sending different query types to the vmselect URL.")
+	addr = flagutil.NewArray("datasource.url", "VictoriaMetrics or vmselect url. Required parameter. "+
+		"E.g. http://127.0.0.1:8428. If multiple urls are set, queries are sent to the first healthy url "+
+		"and fail over to the next urls on network errors or 5xx responses. See also -datasource.failoverCooldown")
+	failoverCooldown = flag.Duration("datasource.failoverCooldown", 30*time.Second, "How long to deprioritize -datasource.url which failed to respond. "+
+		"Deprioritized urls are queried only if the rest of urls fail. It has no effect if a single -datasource.url is set")
+	appendTypePrefix = flag.Bool("datasource.appendTypePrefix", false, "Whether to add type prefix to -datasource.url based on the query type. Set to true if sending different query types to the vmselect URL. "+
+		"The prefix is /prometheus for Prometheus queries and /graphite for Graphite queries. It isn't added if -datasource.url already ends with it")
 	basicAuthUsername = flag.String("datasource.basicAuth.username", "", "Optional basic auth username for -datasource.url")
 	basicAuthPassword = flag.String("datasource.basicAuth.password", "", "Optional basic auth password for -datasource.url")
+	bearerTokenValue  = flag.String("datasource.bearerToken", "", "Optional bearer auth token to use for -datasource.url. "+
+		"It cannot be set together with -datasource.basicAuth.* flags")
+	bearerTokenFile = flag.String("datasource.bearerTokenFile", "", "Optional path to bearer token file to use for -datasource.url. "+
+		"The file is re-read every minute, so rotated tokens are picked up. It cannot be set together with -datasource.bearerToken")
+
+	oauth2ClientID         = flag.String("datasource.oauth2.clientID", "", "Optional OAuth2 clientID to use for -datasource.url")
+	oauth2ClientSecret     = flag.String("datasource.oauth2.clientSecret", "", "Optional OAuth2 clientSecret to use for -datasource.url")
+	oauth2ClientSecretFile = flag.String("datasource.oauth2.clientSecretFile", "", "Optional OAuth2 clientSecretFile to use for -datasource.url. "+
+		"The file is re-read when the datasource rejects the token, so rotated secrets are picked up")
+	oauth2TokenURL = flag.String("datasource.oauth2.tokenUrl", "", "Optional OAuth2 tokenURL to use for -datasource.url. "+
+		"Access tokens obtained via client credentials flow are cached until the expiry")
+	oauth2Scopes = flag.String("datasource.oauth2.scopes", "", "Optional OAuth2 scopes to use for -datasource.url. Scopes must be delimited by ';'")
 
 	tlsInsecureSkipVerify = flag.Bool("datasource.tlsInsecureSkipVerify", false, "Whether to skip tls verification when connecting to -datasource.url")
 	tlsCertFile           = flag.String("datasource.tlsCertFile", "", "Optional path to client-side TLS certificate file to use when connecting to -datasource.url")
@@ -22,13 +41,45 @@ var (
 	tlsCAFile             = flag.String("datasource.tlsCAFile", "", `Optional path to TLS CA file to use for verifying connections to -datasource.url. By default, system CA is used`)
 	tlsServerName         = flag.String("datasource.tlsServerName", "", `Optional TLS server name to use for connections to -datasource.url. By default, the server name from -datasource.url is used`)
 
-	lookBack  = flag.Duration("datasource.lookback", 0, `Lookback defines how far into the past to look when evaluating queries. For example, if the datasource.lookback=5m then param "time" with value now()-5m will be added to every query.`)
+	lookBack  = flag.Duration("datasource.lookback", 0, `Lookback defines how far into the past to look when evaluating queries. For example, if the datasource.lookback=5m then param "time" with value now()-5m will be added to every query. Group's eval_delay param and -rule.evalDelay take priority over it. The effective query time is logged for rules with debug param set`)
 	queryStep = flag.Duration("datasource.queryStep", 0, "queryStep defines how far a value can fallback to when evaluating queries. "+
-		"For example, if datasource.queryStep=15s then param \"step\" with value \"15s\" will be added to every query."+
+		"For example, if datasource.queryStep=15s then param \"step\" with value \"15\" will be added to every query. "+
+		"The value is passed in seconds with millisecond precision. "+
 		"If queryStep isn't specified, rule's evaluationInterval will be used instead.")
+	queryTimeAlignment = flag.Bool("datasource.queryTimeAlignment", true, "Whether to align \"time\" parameter of instant queries with evaluation interval of the group. "+
+		"Alignment makes HA vmalert replicas evaluate rules at identical timestamps, so they produce identical results. "+
+		"Alerts activation and sending time aren't affected by the alignment")
 	maxIdleConnections = flag.Int("datasource.maxIdleConnections", 100, `Defines the number of idle (keep-alive connections) to each configured datasource. Consider setting this value equal to the value: groups_total * group.concurrency. Too low a value may result in a high number of sockets in TIME_WAIT state.`)
-	roundDigits        = flag.Int("datasource.roundDigits", 0, `Adds "round_digits" GET param to datasource requests. `+
-		`In VM "round_digits" limits the number of digits after the decimal point in response values.`)
+	idleConnTimeout    = flag.Duration("datasource.idleConnTimeout", 50*time.Second, `Defines a duration for idle (keep-alive connections) to exist. Consider setting this value less than "-http.idleConnTimeout". It must prevent possible "write: broken pipe" and "read: connection reset by peer" errors.`)
+	disableCompression = flag.Bool("datasource.disableCompression", false, "Whether to disable compression of datasource responses. "+
+		"By default, vmalert asks for zstd or gzip compressed responses via Accept-Encoding header. "+
+		"Disabling may be useful for debugging proxies which break compressed responses")
+	disableKeepAlive     = flag.Bool("datasource.disableKeepAlive", false, `Whether to disable long-lived connections to the datasource. If true, disables HTTP keep-alives and will only use the connection to the server for a single HTTP request.`)
+	maxConcurrentQueries = flag.Int("datasource.maxConcurrentQueries", 100, "The maximum number of concurrent requests to datasources made by all the groups. "+
+		"Excess requests wait for the free slot until their timeout. Set to 0 for disabling the limit")
+	headers = flag.String("datasource.headers", "", "Optional HTTP headers to send with each request to the corresponding -datasource.url. "+
+		"For example, -datasource.headers='My-Auth:foobar' would send 'My-Auth: foobar' HTTP header with every request to the corresponding -datasource.url. "+
+		"Multiple headers must be delimited by '^^': -datasource.headers='header1:value1^^header2:value2'. "+
+		"Headers set via group's headers param have priority")
+	showDatasourceURL = flag.Bool("datasource.showURL", false, "Whether to show -datasource.url password and values of datasource request headers "+
+		"in log messages and errors. They are hidden by default, since they can contain sensitive info such as auth key")
+	retries = flag.Int("datasource.retries", 1, "How many times to retry datasource requests failed because of network errors or 5xx responses. "+
+		"Retries are made with exponential backoff starting from 100ms and are bounded by the query timeout. "+
+		"Requests failed with 4xx responses aren't retried. Set to 0 for disabling retries")
+	queryMethod = flag.String("datasource.queryMethod", "GET", "HTTP method for sending queries to -datasource.url. Supported values: GET, POST. "+
+		"In POST mode query params are sent in application/x-www-form-urlencoded request body, "+
+		"so long queries aren't rejected by proxies limiting URL length")
+	roundDigits = flag.Int("datasource.roundDigits", roundDigitsDisabled, `Adds "round_digits" GET param to datasource requests. `+
+		`In VM "round_digits" limits the number of digits after the decimal point in response values. `+
+		`Values are additionally rounded by vmalert, so the rounding works for datasources which ignore the param. `+
+		`For example, -datasource.roundDigits=2 rounds 0.8999 to 0.9, while -datasource.roundDigits=0 rounds values to integers. `+
+		`By default, values aren't rounded`)
+	queryTrace = flag.Bool("datasource.queryTrace", false, "Whether to request query traces via \"trace=1\" param for rules with debug param set. "+
+		"Traces are stored in the rule's updates history available at the rule's page. "+
+		"Additionally, \"traceparent\" header with trace id unique per group evaluation is sent with every query, "+
+		"so datasource and proxy logs can be correlated to the group evaluation. See https://docs.victoriametrics.com/#query-tracing")
+	queryTraceMaxSize = flagutil.NewBytes("datasource.queryTraceMaxSize", 64*1024, "The maximum size of the query trace stored per rule's update. "+
+		"Bigger traces are truncated. See -datasource.queryTrace")
 )
 
 // Param represents an HTTP GET param
@@ -36,21 +87,97 @@ type Param struct {
 	Key, Value string
 }
 
+// retryMinBackoff is the delay before the first retry
+// of the failed datasource request
+const retryMinBackoff = 100 * time.Millisecond
+
 // Init creates a Querier from provided flag values.
 // Provided extraParams will be added as GET params to
 // each request.
 func Init(extraParams []Param) (QuerierBuilder, error) {
-	if *addr == "" {
+	if len(*addr) == 0 {
 		return nil, fmt.Errorf("datasource.url is empty")
 	}
+	addrs, err := newAddrPool(*addr, *failoverCooldown)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(*queryMethod)
+	if method != http.MethodGet && method != http.MethodPost {
+		return nil, fmt.Errorf("datasource.queryMethod must be GET or POST; got %q", *queryMethod)
+	}
+	if queryTraceMaxSize.N < 0 {
+		return nil, fmt.Errorf("datasource.queryTraceMaxSize cannot be negative; got %d", queryTraceMaxSize.N)
+	}
+	if *maxConcurrentQueries < 0 {
+		return nil, fmt.Errorf("datasource.maxConcurrentQueries cannot be negative; got %d", *maxConcurrentQueries)
+	}
+	if *retries < 0 {
+		return nil, fmt.Errorf("datasource.retries cannot be negative; got %d", *retries)
+	}
 
-	tr, err := utils.Transport(*addr, *tlsCertFile, *tlsKeyFile, *tlsCAFile, *tlsServerName, *tlsInsecureSkipVerify)
+	// TLS settings are shared by all the urls
+	tlsURL := addrs.primary()
+	for _, a := range addrs.addrs {
+		if a.u.Scheme == "https" {
+			tlsURL = a.u.String()
+		}
+	}
+	tr, err := utils.Transport(tlsURL, *tlsCertFile, *tlsKeyFile, *tlsCAFile, *tlsServerName, *tlsInsecureSkipVerify)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transport: %w", err)
+		return nil, fmt.Errorf("failed to create transport for -datasource.tls* flags: %w", err)
 	}
+	tr.DisableKeepAlives = *disableKeepAlive
 	tr.MaxIdleConnsPerHost = *maxIdleConnections
+	if tr.MaxIdleConns != 0 && tr.MaxIdleConns < tr.MaxIdleConnsPerHost {
+		tr.MaxIdleConns = tr.MaxIdleConnsPerHost
+	}
+	tr.IdleConnTimeout = *idleConnTimeout
+	// responses are decompressed by vmalert in order to count the received bytes
+	tr.DisableCompression = true
+
+	var authMethods []string
+	if *basicAuthUsername != "" || *basicAuthPassword != "" {
+		authMethods = append(authMethods, "-datasource.basicAuth.*")
+	}
+	if *bearerTokenValue != "" || *bearerTokenFile != "" {
+		authMethods = append(authMethods, "-datasource.bearerToken*")
+	}
+	if *oauth2ClientID != "" || *oauth2TokenURL != "" {
+		authMethods = append(authMethods, "-datasource.oauth2.*")
+	}
+	if len(authMethods) > 1 {
+		return nil, fmt.Errorf("only one of %s flags can be set", strings.Join(authMethods, ", "))
+	}
+	bt, err := utils.NewBearerToken(*bearerTokenValue, *bearerTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init bearer token: %w", err)
+	}
+	var scopes []string
+	if *oauth2Scopes != "" {
+		scopes = strings.Split(*oauth2Scopes, ";")
+	}
+	ot, err := utils.NewOAuth2Token(*oauth2ClientID, *oauth2ClientSecret, *oauth2ClientSecretFile, *oauth2TokenURL, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init OAuth2: %w", err)
+	}
+
+	hs, err := utils.ParseHeaders(*headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse -datasource.headers: %w", err)
+	}
+	// sort headers, so they are set in the same order for every request
+	hKeys := make([]string, 0, len(hs))
+	for k := range hs {
+		hKeys = append(hKeys, k)
+	}
+	sort.Strings(hKeys)
+	var extraHeaders []keyValue
+	for _, k := range hKeys {
+		extraHeaders = append(extraHeaders, keyValue{key: k, value: hs[k]})
+	}
 
-	if *roundDigits > 0 {
+	if *roundDigits < roundDigitsDisabled {
 		extraParams = append(extraParams, Param{
 			Key:   "round_digits",
 			Value: fmt.Sprintf("%d", *roundDigits),
@@ -58,14 +185,26 @@ func Init(extraParams []Param) (QuerierBuilder, error) {
 	}
 
 	return &VMStorage{
-		c:                &http.Client{Transport: tr},
-		basicAuthUser:    *basicAuthUsername,
-		basicAuthPass:    *basicAuthPassword,
-		datasourceURL:    strings.TrimSuffix(*addr, "/"),
-		appendTypePrefix: *appendTypePrefix,
-		lookBack:         *lookBack,
-		queryStep:        *queryStep,
-		dataSourceType:   NewPrometheusType(),
-		extraParams:      extraParams,
+		c:                  &http.Client{Transport: tr},
+		basicAuthUser:      *basicAuthUsername,
+		basicAuthPass:      *basicAuthPassword,
+		bearerToken:        bt,
+		oauth2Token:        ot,
+		roundDigits:        *roundDigits,
+		queryMethod:        method,
+		queryTrace:         *queryTrace,
+		queryTraceMaxSize:  queryTraceMaxSize.N,
+		limiter:            newQueryLimiter(*maxConcurrentQueries),
+		disableCompression: *disableCompression,
+		retries:            *retries,
+		retryBackoff:       retryMinBackoff,
+		datasourceURL:      addrs.primary(),
+		addrs:              addrs,
+		appendTypePrefix:   *appendTypePrefix,
+		lookBack:           *lookBack,
+		queryStep:          *queryStep,
+		dataSourceType:     NewPrometheusType(),
+		extraParams:        extraParams,
+		extraHeaders:       extraHeaders,
 	}, nil
 }
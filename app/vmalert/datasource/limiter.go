@@ -0,0 +1,78 @@
+package datasource
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// queryLimiter limits the number of concurrent requests
+// to datasources. Nil queryLimiter doesn't limit requests.
+type queryLimiter struct {
+	ch chan struct{}
+}
+
+// newQueryLimiter returns queryLimiter which allows up to n
+// concurrent requests. It returns nil if n is 0.
+func newQueryLimiter(n int) *queryLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &queryLimiter{ch: make(chan struct{}, n)}
+}
+
+var (
+	concurrentQueries int64
+
+	_ = metrics.NewGauge(`vmalert_datasource_concurrent_queries`, func() float64 {
+		return float64(atomic.LoadInt64(&concurrentQueries))
+	})
+	concurrentQueriesWaitSeconds = metrics.NewFloatCounter(`vmalert_datasource_concurrent_queries_wait_seconds_total`)
+)
+
+// acquire blocks until the request is allowed or ctx is done
+func (ql *queryLimiter) acquire(ctx context.Context) error {
+	if ql != nil {
+		select {
+		case ql.ch <- struct{}{}:
+		default:
+			// the limit is reached, so wait for the free slot
+			start := time.Now()
+			select {
+			case ql.ch <- struct{}{}:
+				concurrentQueriesWaitSeconds.Add(time.Since(start).Seconds())
+			case <-ctx.Done():
+				concurrentQueriesWaitSeconds.Add(time.Since(start).Seconds())
+				return ctx.Err()
+			}
+		}
+	}
+	atomic.AddInt64(&concurrentQueries, 1)
+	return nil
+}
+
+// release must be called once per successful acquire
+func (ql *queryLimiter) release() {
+	atomic.AddInt64(&concurrentQueries, -1)
+	if ql != nil {
+		<-ql.ch
+	}
+}
+
+// releaseOnClose releases the acquired slot
+// when the response body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (rc *releaseOnClose) Close() error {
+	err := rc.ReadCloser.Close()
+	rc.once.Do(rc.release)
+	return err
+}
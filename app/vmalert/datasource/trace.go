@@ -0,0 +1,86 @@
+package datasource
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// QueryTrace receives the trace of the query returned by VictoriaMetrics
+// for queries with `trace=1` param.
+// See https://docs.victoriametrics.com/#query-tracing
+type QueryTrace struct {
+	// Data contains the trace in JSON format.
+	// It is truncated to -datasource.queryTraceMaxSize.
+	Data string
+}
+
+// queryTraceTruncatedSuffix is appended to truncated traces
+const queryTraceTruncatedSuffix = "...(truncated)"
+
+func (qt *QueryTrace) set(data []byte, maxSize int) {
+	if len(data) == 0 {
+		return
+	}
+	if maxSize > 0 && len(data) > maxSize {
+		qt.Data = string(data[:maxSize]) + queryTraceTruncatedSuffix
+		return
+	}
+	qt.Data = string(data)
+}
+
+type queryTraceKey struct{}
+
+// WithQueryTrace returns ctx for receiving the trace of the query made with it into qt.
+// The trace is requested only by Querier with Debug param if -datasource.queryTrace is set.
+func WithQueryTrace(ctx context.Context, qt *QueryTrace) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, qt)
+}
+
+func queryTraceFromContext(ctx context.Context) *QueryTrace {
+	qt, _ := ctx.Value(queryTraceKey{}).(*QueryTrace)
+	return qt
+}
+
+type traceIDKey struct{}
+
+// WithTraceID returns ctx with the newly generated W3C trace id
+// if -datasource.queryTrace is set. The trace id is sent via `traceparent`
+// header with every query made with the returned ctx.
+// See https://www.w3.org/TR/trace-context/#traceparent-header
+func WithTraceID(ctx context.Context) context.Context {
+	if !*queryTrace {
+		return ctx
+	}
+	return context.WithValue(ctx, traceIDKey{}, newTraceID(16))
+}
+
+// TraceIDFromContext returns the trace id set via WithTraceID.
+// It returns empty string if ctx doesn't contain the trace id.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// setTraceParent sets `traceparent` header to req if ctx contains the trace id.
+// Every request gets unique parent id, so requests made within the same
+// trace can be distinguished.
+func setTraceParent(ctx context.Context, req *http.Request) {
+	id := TraceIDFromContext(ctx)
+	if id == "" {
+		return
+	}
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", id, newTraceID(8)))
+}
+
+// newTraceID returns random hex-encoded id of n bytes
+func newTraceID(n int) string {
+	b := make([]byte, n)
+	// crypto/rand.Read doesn't fail on supported platforms
+	_, _ = rand.Read(b)
+	// all-zero ids are invalid
+	b[n-1] |= 1
+	return hex.EncodeToString(b)
+}
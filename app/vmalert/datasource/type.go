@@ -66,6 +66,26 @@ func (t *Type) ValidateExpr(expr string) error {
 	return nil
 }
 
+// IsRangeVectorExpr returns true if the given expression
+// returns range vector, e.g. `up[5m]`. Such expressions
+// aren't supported by alerting and recording rules.
+// It returns false for invalid expressions and for graphite type.
+func (t *Type) IsRangeVectorExpr(expr string) bool {
+	if t.name != "" && t.name != prometheusType {
+		return false
+	}
+	e, err := metricsql.Parse(expr)
+	if err != nil {
+		return false
+	}
+	re, ok := e.(*metricsql.RollupExpr)
+	if !ok {
+		return false
+	}
+	// `up offset 5m` is parsed as rollup expression as well
+	return re.Window != nil || re.ForSubquery()
+}
+
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
 func (t *Type) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var s string
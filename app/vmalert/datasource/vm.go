@@ -2,40 +2,86 @@ package datasource
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
 )
 
 // VMStorage represents vmstorage entity with ability to read and write metrics
 type VMStorage struct {
-	c                *http.Client
-	datasourceURL    string
-	basicAuthUser    string
-	basicAuthPass    string
-	appendTypePrefix bool
-	lookBack         time.Duration
-	queryStep        time.Duration
+	c                  *http.Client
+	datasourceURL      string
+	addrs              *addrPool
+	basicAuthUser      string
+	basicAuthPass      string
+	bearerToken        *utils.BearerToken
+	oauth2Token        *utils.OAuth2Token
+	roundDigits        int
+	queryMethod        string
+	queryTrace         bool
+	queryTraceMaxSize  int
+	limiter            *queryLimiter
+	disableCompression bool
+	retries            int
+	retryBackoff       time.Duration
+	appendTypePrefix   bool
+	lookBack           time.Duration
+	queryStep          time.Duration
 
 	dataSourceType     Type
 	evaluationInterval time.Duration
+	evalOffset         time.Duration
+	evalDelay          time.Duration
 	extraLabels        []string
 	extraParams        []Param
+	extraHeaders       []keyValue
+	debug              bool
+	onQuery            func(QueryStats)
+}
+
+type keyValue struct {
+	key   string
+	value string
 }
 
 // Clone makes clone of VMStorage, shares http client.
 func (s *VMStorage) Clone() *VMStorage {
 	return &VMStorage{
-		c:                s.c,
-		datasourceURL:    s.datasourceURL,
-		basicAuthUser:    s.basicAuthUser,
-		basicAuthPass:    s.basicAuthPass,
-		lookBack:         s.lookBack,
-		queryStep:        s.queryStep,
-		appendTypePrefix: s.appendTypePrefix,
-		dataSourceType:   s.dataSourceType,
+		c:                  s.c,
+		datasourceURL:      s.datasourceURL,
+		addrs:              s.addrs,
+		basicAuthUser:      s.basicAuthUser,
+		basicAuthPass:      s.basicAuthPass,
+		bearerToken:        s.bearerToken,
+		oauth2Token:        s.oauth2Token,
+		roundDigits:        s.roundDigits,
+		queryMethod:        s.queryMethod,
+		queryTrace:         s.queryTrace,
+		queryTraceMaxSize:  s.queryTraceMaxSize,
+		limiter:            s.limiter,
+		disableCompression: s.disableCompression,
+		retries:            s.retries,
+		retryBackoff:       s.retryBackoff,
+		lookBack:           s.lookBack,
+		queryStep:          s.queryStep,
+		appendTypePrefix:   s.appendTypePrefix,
+		dataSourceType:     s.dataSourceType,
+		// copy extraParams, so ApplyParams won't modify the original slice
+		extraParams:  append([]Param{}, s.extraParams...),
+		extraHeaders: append([]keyValue{}, s.extraHeaders...),
 	}
 }
 
@@ -45,9 +91,47 @@ func (s *VMStorage) ApplyParams(params QuerierParams) *VMStorage {
 		s.dataSourceType = *params.DataSourceType
 	}
 	s.evaluationInterval = params.EvaluationInterval
+	s.evalOffset = params.EvalOffset
+	s.evalDelay = params.EvalDelay
+	s.debug = params.Debug
+	s.onQuery = params.OnQuery
+	if params.DatasourceURL != "" {
+		s.datasourceURL = strings.TrimSuffix(params.DatasourceURL, "/")
+		s.basicAuthUser, s.basicAuthPass = params.BasicAuthUser, params.BasicAuthPass
+		// tokens are issued for -datasource.url only
+		s.bearerToken = nil
+		s.oauth2Token = nil
+		s.addrs = nil
+	}
+	if params.Tenant != "" {
+		// vmselect serves tenant data at `/select/<tenant>/<type>/` paths
+		s.datasourceURL = tenantURL(s.datasourceURL, params.Tenant)
+		s.appendTypePrefix = true
+	}
 	for k, v := range params.ExtraLabels {
 		s.extraLabels = append(s.extraLabels, fmt.Sprintf("%s=%s", k, v))
 	}
+	// headers are set in the order of appearance, so
+	// params headers override the headers from flags
+	hKeys := make([]string, 0, len(params.Headers))
+	for k := range params.Headers {
+		hKeys = append(hKeys, k)
+	}
+	sort.Strings(hKeys)
+	for _, k := range hKeys {
+		s.extraHeaders = append(s.extraHeaders, keyValue{key: k, value: params.Headers[k]})
+	}
+	keys := make([]string, 0, len(params.QueryParams))
+	for k := range params.QueryParams {
+		keys = append(keys, k)
+	}
+	// sort keys to get stable order of params in requests
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range params.QueryParams[k] {
+			s.extraParams = append(s.extraParams, Param{Key: k, Value: v})
+		}
+	}
 	return s
 }
 
@@ -56,6 +140,10 @@ func (s *VMStorage) BuildWithParams(params QuerierParams) Querier {
 	return s.Clone().ApplyParams(params)
 }
 
+// roundDigitsDisabled is the value of -datasource.roundDigits
+// which disables rounding of response values
+const roundDigitsDisabled = 100
+
 // NewVMStorage is a constructor for VMStorage
 func NewVMStorage(baseURL, basicAuthUser, basicAuthPass string, lookBack time.Duration, queryStep time.Duration, appendTypePrefix bool, c *http.Client) *VMStorage {
 	return &VMStorage{
@@ -66,18 +154,21 @@ func NewVMStorage(baseURL, basicAuthUser, basicAuthPass string, lookBack time.Du
 		appendTypePrefix: appendTypePrefix,
 		lookBack:         lookBack,
 		queryStep:        queryStep,
+		queryMethod:      http.MethodGet,
 		dataSourceType:   NewPrometheusType(),
+		roundDigits:      roundDigitsDisabled,
 	}
 }
 
-// Query executes the given query and returns parsed response
-func (s *VMStorage) Query(ctx context.Context, query string) ([]Metric, error) {
-	req, err := s.newRequestPOST()
+// Query executes the given query at ts and returns parsed response.
+// ts is passed via `time` param, so the evaluation time doesn't depend
+// on the datasource clock or request delays.
+func (s *VMStorage) Query(ctx context.Context, query string, ts time.Time) ([]Metric, error) {
+	req, err := s.newRequest()
 	if err != nil {
 		return nil, err
 	}
 
-	ts := time.Now()
 	switch s.dataSourceType.name {
 	case "", prometheusType:
 		s.setPrometheusInstantReqParams(req, query, ts)
@@ -86,20 +177,50 @@ func (s *VMStorage) Query(ctx context.Context, query string) ([]Metric, error) {
 	default:
 		return nil, fmt.Errorf("engine not found: %q", s.dataSourceType.name)
 	}
+	qt := queryTraceFromContext(ctx)
+	if qt != nil && s.queryTrace && s.debug && s.dataSourceType.name != graphiteType {
+		q := req.URL.Query()
+		q.Set("trace", "1")
+		req.URL.RawQuery = q.Encode()
+	}
+	setTraceParent(ctx, req)
 
+	if s.debug {
+		msg := fmt.Sprintf("DEBUG datasource request: executing %s request with URL %q and headers {%s}",
+			req.Method, displayURL(req.URL), displayHeaders(req.Header))
+		if s.dataSourceType.name != graphiteType {
+			msg += fmt.Sprintf("; evaluation time %s, query time %s (eval_delay=%v, lookback=%v)",
+				ts.Format(time.RFC3339), s.instantQueryTime(ts).Format(time.RFC3339), s.evalDelay, s.lookBack)
+		}
+		logger.Infof("%s", msg)
+	}
+	start := time.Now()
 	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	body := &countingReader{r: resp.Body}
+	resp.Body = body
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
-	parseFn := parsePrometheusResponse
-	if s.dataSourceType.name != prometheusType {
-		parseFn = parseGraphiteResponse
+	var metrics []Metric
+	if s.dataSourceType.name == graphiteType {
+		metrics, err = parseGraphiteResponse(req, resp)
+	} else {
+		var trace []byte
+		metrics, trace, err = parsePrometheusResponse(req, resp)
+		if qt != nil {
+			qt.set(trace, s.queryTraceMaxSize)
+		}
 	}
-	return parseFn(req, resp)
+	if err != nil {
+		return nil, err
+	}
+	s.roundValues(metrics)
+	s.reportQuery(query, start, body.n, len(metrics))
+	return metrics, nil
 }
 
 // QueryRange executes the given query on the given time range.
@@ -109,7 +230,7 @@ func (s *VMStorage) QueryRange(ctx context.Context, query string, start, end tim
 	if s.dataSourceType.name != prometheusType {
 		return nil, fmt.Errorf("%q is not supported for QueryRange", s.dataSourceType.name)
 	}
-	req, err := s.newRequestPOST()
+	req, err := s.newRequest()
 	if err != nil {
 		return nil, err
 	}
@@ -120,37 +241,354 @@ func (s *VMStorage) QueryRange(ctx context.Context, query string, start, end tim
 		return nil, fmt.Errorf("end param is missing")
 	}
 	s.setPrometheusRangeReqParams(req, query, start, end)
+	setTraceParent(ctx, req)
+	reqStart := time.Now()
 	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	body := &countingReader{r: resp.Body}
+	resp.Body = body
 	defer func() {
 		_ = resp.Body.Close()
 	}()
-	return parsePrometheusResponse(req, resp)
+	metrics, _, err := parsePrometheusResponse(req, resp)
+	if err != nil {
+		return nil, err
+	}
+	s.roundValues(metrics)
+	s.reportQuery(query, reqStart, body.n, len(metrics))
+	return metrics, nil
+}
+
+// reportQuery passes stats of the successful query to s.onQuery
+func (s *VMStorage) reportQuery(query string, start time.Time, responseBytes int64, series int) {
+	if s.onQuery == nil {
+		return
+	}
+	s.onQuery(QueryStats{
+		Query:         query,
+		Duration:      time.Since(start),
+		ResponseBytes: responseBytes,
+		Series:        series,
+	})
+}
+
+// countingReader counts the number of bytes read from r
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
 }
 
+func (cr *countingReader) Close() error { return cr.r.Close() }
+
+// roundValues rounds values of metrics to -datasource.roundDigits
+// decimal digits, so they are rounded even if the datasource
+// doesn't support round_digits param.
+func (s *VMStorage) roundValues(metrics []Metric) {
+	if s.roundDigits >= roundDigitsDisabled {
+		return
+	}
+	for _, m := range metrics {
+		for i, v := range m.Values {
+			m.Values[i] = decimal.RoundToDecimalDigits(v, s.roundDigits)
+		}
+	}
+}
+
+// do sends req to the datasource. Requests failed because of network errors
+// or 5xx responses are retried up to s.retries times with exponential backoff.
+// Retries stop if ctx is canceled or its deadline is exceeded.
 func (s *VMStorage) do(ctx context.Context, req *http.Request) (*http.Response, error) {
-	resp, err := s.c.Do(req.WithContext(ctx))
+	setFormBody(req)
+	backoff := s.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, retriable, err := s.doFailover(ctx, req)
+		if err == nil || !retriable || attempt >= s.retries || ctx.Err() != nil {
+			return resp, err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-t.C:
+		}
+		datasourceRetries.Inc()
+		backoff *= 2
+	}
+}
+
+var datasourceRetries = metrics.NewCounter(`vmalert_datasource_request_retries_total`)
+
+// doFailover sends req to -datasource.url addresses one by one
+// until the request succeeds or fails with an error not worth retrying.
+// Throttled requests are sent to the next address as well.
+// Failed addresses are deprioritized for the cooldown period
+// or for the delay requested by the throttling address if it is bigger.
+func (s *VMStorage) doFailover(ctx context.Context, req *http.Request) (*http.Response, bool, error) {
+	if s.addrs == nil {
+		return s.doOnce(ctx, req)
+	}
+	var resp *http.Response
+	var retriable bool
+	var err error
+	for _, a := range s.addrs.ordered() {
+		a.requests.Inc()
+		resp, retriable, err = s.doOnce(ctx, s.addrs.request(req, a))
+		if err == nil {
+			a.setHealthy(true, s.addrs.cooldown)
+			return resp, false, nil
+		}
+		a.errors.Inc()
+		var te *ThrottledError
+		throttled := errors.As(err, &te)
+		if (!retriable && !throttled) || ctx.Err() != nil {
+			return nil, retriable, err
+		}
+		cooldown := s.addrs.cooldown
+		if throttled && te.RetryAfter > cooldown {
+			cooldown = te.RetryAfter
+		}
+		a.setHealthy(false, cooldown)
+	}
+	return nil, retriable, err
+}
+
+// setFormBody moves query params of POST req into
+// application/x-www-form-urlencoded body, so long queries
+// aren't limited by the max URL length of proxies.
+func setFormBody(req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+	body := req.URL.RawQuery
+	req.URL.RawQuery = ""
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+}
+
+// resetBody makes req body readable again before
+// sending req one more time on retries.
+func resetBody(req *http.Request) {
+	if req.GetBody != nil {
+		req.Body, _ = req.GetBody()
+	}
+}
+
+// doOnce sends req to the datasource. It returns true
+// if the request failed with an error worth retrying.
+func (s *VMStorage) doOnce(ctx context.Context, req *http.Request) (*http.Response, bool, error) {
+	if err := s.limiter.acquire(ctx); err != nil {
+		return nil, false, fmt.Errorf("cannot send request to %s: %w; consider increasing -datasource.maxConcurrentQueries", displayURL(req.URL), err)
+	}
+	resp, retriable, err := s.doRequest(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("error getting response from %s: %w", req.URL, err)
+		s.limiter.release()
+		return nil, retriable, err
+	}
+	// the response is still being read by the caller,
+	// so the slot is released once the body is closed
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: s.limiter.release}
+	return resp, false, nil
+}
+
+func (s *VMStorage) doRequest(ctx context.Context, req *http.Request) (*http.Response, bool, error) {
+	resetBody(req)
+	resp, err := s.send(ctx, req)
+	if err != nil {
+		return nil, true, fmt.Errorf("error getting response from %s: %w", displayURL(req.URL), err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized && s.oauth2Token != nil {
+		// the token may be revoked before its expiry,
+		// so retry once with a new token
+		_ = resp.Body.Close()
+		if err := s.oauth2Token.Reset(); err != nil {
+			return nil, false, err
+		}
+		if err := s.setOAuth2Token(req); err != nil {
+			return nil, false, err
+		}
+		resetBody(req)
+		resp, err = s.send(ctx, req)
+		if err != nil {
+			return nil, true, fmt.Errorf("error getting response from %s: %w", displayURL(req.URL), err)
+		}
 	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
 		_ = resp.Body.Close()
-		return nil, fmt.Errorf("unexpected response code %d for %s. Response body %s", resp.StatusCode, req.URL, body)
+		err := fmt.Errorf("unexpected response code %d for %s. Response body %s", resp.StatusCode, displayURL(req.URL), body)
+		if d, ok := parseRetryAfter(resp, time.Now()); ok {
+			// the datasource is overloaded, so the request
+			// mustn't be retried until the advertised delay
+			datasourceThrottled.Inc()
+			return nil, false, &ThrottledError{RetryAfter: d, Err: err}
+		}
+		// 4xx responses mean the request is wrong, so there is no sense in retrying it
+		retriable := resp.StatusCode >= 500
+		return nil, retriable, err
+	}
+	return resp, false, nil
+}
+
+// send sends req and decodes the response body
+// if it was compressed by the datasource
+func (s *VMStorage) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	resp, err := s.c.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if err := decompressResponse(resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
 	}
 	return resp, nil
 }
 
-func (s *VMStorage) newRequestPOST() (*http.Request, error) {
-	req, err := http.NewRequest("POST", s.datasourceURL, nil)
+var datasourceThrottled = metrics.NewCounter(`vmalert_datasource_throttled_total`)
+
+// ThrottledError is returned when the datasource rejects the request
+// with 429 or 503 response containing Retry-After header.
+type ThrottledError struct {
+	// RetryAfter is the delay requested by the datasource
+	RetryAfter time.Duration
+	// Err is the original error
+	Err error
+}
+
+func (te *ThrottledError) Error() string {
+	return fmt.Sprintf("%s; the datasource asked to retry after %s", te.Err, te.RetryAfter)
+}
+
+// Unwrap returns the original error
+func (te *ThrottledError) Unwrap() error { return te.Err }
+
+// parseRetryAfter returns the delay from Retry-After header
+// of 429 or 503 response. The header may contain either
+// the number of seconds or HTTP date.
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// displayURL returns u for logs and errors.
+// Password is redacted unless -datasource.showURL is set.
+func displayURL(u *url.URL) string {
+	if *showDatasourceURL {
+		return u.String()
+	}
+	return u.Redacted()
+}
+
+// displayHeaders returns h for debug logs.
+// Values are redacted unless -datasource.showURL is set.
+func displayHeaders(h http.Header) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		if k == "Content-Type" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		v := "<secret>"
+		if *showDatasourceURL {
+			v = strings.Join(h[k], ",")
+		}
+		pairs[i] = fmt.Sprintf("%s: %s", k, v)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// tenantURL returns datasourceURL with the path for the given tenant
+// appended to its path, so the base path and query args are preserved
+func tenantURL(datasourceURL, tenant string) string {
+	u, err := url.Parse(datasourceURL)
+	if err != nil {
+		// the invalid url is reported on the first request
+		return datasourceURL
+	}
+	u.Path = path.Join("/", u.Path, "select", tenant)
+	return u.String()
+}
+
+// appendTypePrefixPath appends the type prefix, e.g. `/prometheus`, to path
+// unless path already ends with it, so urls containing the prefix
+// don't get it doubled.
+func appendTypePrefixPath(path, prefix string) string {
+	if strings.HasSuffix(path, prefix) {
+		return path
+	}
+	return path + prefix
+}
+
+func (s *VMStorage) newRequest() (*http.Request, error) {
+	req, err := http.NewRequest(s.queryMethod, s.datasourceURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if !s.disableCompression {
+		// compressed responses are decoded by vmalert, so the savings
+		// are visible via vmalert_datasource_response_*_bytes_total metrics
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	for _, h := range s.extraHeaders {
+		req.Header.Set(h.key, h.value)
+	}
 	if s.basicAuthPass != "" {
 		req.SetBasicAuth(s.basicAuthUser, s.basicAuthPass)
 	}
+	if s.bearerToken != nil {
+		req.Header.Set("Authorization", "Bearer "+s.bearerToken.Get())
+	}
+	if err := s.setOAuth2Token(req); err != nil {
+		return nil, err
+	}
 	return req, nil
 }
+
+func (s *VMStorage) setOAuth2Token(req *http.Request) error {
+	if s.oauth2Token == nil {
+		return nil
+	}
+	token, err := s.oauth2Token.Get()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
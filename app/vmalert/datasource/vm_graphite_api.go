@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -11,34 +12,59 @@ import (
 type graphiteResponse []graphiteResponseTarget
 
 type graphiteResponseTarget struct {
-	Target     string            `json:"target"`
-	Tags       map[string]string `json:"tags"`
-	DataPoints [][2]float64      `json:"datapoints"`
+	Target string            `json:"target"`
+	Tags   map[string]string `json:"tags"`
+	// DataPoints contain pairs of value and timestamp.
+	// Value is nil if there is no data for the timestamp.
+	DataPoints [][2]*float64 `json:"datapoints"`
 }
 
+// graphiteNameLabel is the label containing the target name
+const graphiteNameLabel = "name"
+
 func (r graphiteResponse) metrics() []Metric {
 	var ms []Metric
 	for _, res := range r {
-		if len(res.DataPoints) < 1 {
+		last := lastGraphiteDataPoint(res.DataPoints)
+		if last == nil {
 			continue
 		}
 		var m Metric
-		// add only last value to the result.
-		last := res.DataPoints[len(res.DataPoints)-1]
-		m.Values = append(m.Values, last[0])
-		m.Timestamps = append(m.Timestamps, int64(last[1]))
-		for k, v := range res.Tags {
-			m.AddLabel(k, v)
+		// add only last non-null value to the result.
+		m.Values = append(m.Values, *last[0])
+		m.Timestamps = append(m.Timestamps, int64(*last[1]))
+		keys := make([]string, 0, len(res.Tags))
+		for k := range res.Tags {
+			keys = append(keys, k)
+		}
+		// sort tags for deterministic labels order
+		sort.Strings(keys)
+		for _, k := range keys {
+			m.AddLabel(k, res.Tags[k])
+		}
+		if m.Label(graphiteNameLabel) == "" {
+			m.AddLabel(graphiteNameLabel, res.Target)
 		}
 		ms = append(ms, m)
 	}
 	return ms
 }
 
+// lastGraphiteDataPoint returns the last data point with non-null value.
+// It returns nil if there are no such data points.
+func lastGraphiteDataPoint(dps [][2]*float64) *[2]*float64 {
+	for i := len(dps) - 1; i >= 0; i-- {
+		if dps[i][0] != nil && dps[i][1] != nil {
+			return &dps[i]
+		}
+	}
+	return nil
+}
+
 func parseGraphiteResponse(req *http.Request, resp *http.Response) ([]Metric, error) {
 	r := &graphiteResponse{}
 	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
-		return nil, fmt.Errorf("error parsing graphite metrics for %s: %w", req.URL, err)
+		return nil, fmt.Errorf("error parsing graphite metrics for %s: %w", displayURL(req.URL), err)
 	}
 	return r.metrics(), nil
 }
@@ -50,7 +76,7 @@ const (
 
 func (s *VMStorage) setGraphiteReqParams(r *http.Request, query string, timestamp time.Time) {
 	if s.appendTypePrefix {
-		r.URL.Path += graphitePrefix
+		r.URL.Path = appendTypePrefixPath(r.URL.Path, graphitePrefix)
 	}
 	r.URL.Path += graphitePath
 	q := r.URL.Query()
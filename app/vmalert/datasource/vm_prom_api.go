@@ -16,6 +16,8 @@ type promResponse struct {
 		ResultType string          `json:"resultType"`
 		Result     json.RawMessage `json:"result"`
 	} `json:"data"`
+	// Trace is returned by VictoriaMetrics if the query has `trace=1` param
+	Trace json.RawMessage `json:"trace"`
 }
 
 type promInstant struct {
@@ -50,6 +52,22 @@ func (r promInstant) metrics() ([]Metric, error) {
 	return result, nil
 }
 
+type promScalar [2]interface{}
+
+// metrics returns scalar as a single series with no labels,
+// so it may be processed as instant vector by rules
+func (r promScalar) metrics() ([]Metric, error) {
+	f, err := strconv.ParseFloat(r[1].(string), 64)
+	if err != nil {
+		return nil, fmt.Errorf("scalar %v, unable to parse float64 from %s: %w", r, r[1], err)
+	}
+	m := Metric{
+		Timestamps: []int64{int64(r[0].(float64))},
+		Values:     []float64{f},
+	}
+	return []Metric{m}, nil
+}
+
 func (r promRange) metrics() ([]Metric, error) {
 	var result []Metric
 	for i, res := range r.Result {
@@ -77,19 +95,27 @@ func (r promRange) metrics() ([]Metric, error) {
 const (
 	statusSuccess, statusError = "success", "error"
 	rtVector, rtMatrix         = "vector", "matrix"
+	rtScalar                   = "scalar"
 )
 
-func parsePrometheusResponse(req *http.Request, resp *http.Response) ([]Metric, error) {
+// parsePrometheusResponse returns metrics and the query trace from resp.
+// The trace is empty if it wasn't requested.
+func parsePrometheusResponse(req *http.Request, resp *http.Response) ([]Metric, []byte, error) {
 	r := &promResponse{}
 	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
-		return nil, fmt.Errorf("error parsing prometheus metrics for %s: %w", req.URL, err)
+		return nil, nil, fmt.Errorf("error parsing prometheus metrics for %s: %w", displayURL(req.URL), err)
 	}
 	if r.Status == statusError {
-		return nil, fmt.Errorf("response error, query: %s, errorType: %s, error: %s", req.URL, r.ErrorType, r.Error)
+		return nil, nil, fmt.Errorf("response error, query: %s, errorType: %s, error: %s", displayURL(req.URL), r.ErrorType, r.Error)
 	}
 	if r.Status != statusSuccess {
-		return nil, fmt.Errorf("unknown status: %s, Expected success or error ", r.Status)
+		return nil, nil, fmt.Errorf("unknown status: %s, Expected success or error ", r.Status)
 	}
+	metrics, err := r.metrics()
+	return metrics, r.Trace, err
+}
+
+func (r *promResponse) metrics() ([]Metric, error) {
 	switch r.Data.ResultType {
 	case rtVector:
 		var pi promInstant
@@ -103,6 +129,12 @@ func parsePrometheusResponse(req *http.Request, resp *http.Response) ([]Metric,
 			return nil, err
 		}
 		return pr.metrics()
+	case rtScalar:
+		var ps promScalar
+		if err := json.Unmarshal(r.Data.Result, &ps); err != nil {
+			return nil, err
+		}
+		return ps.metrics()
 	default:
 		return nil, fmt.Errorf("unknown result type %q", r.Data.ResultType)
 	}
@@ -114,27 +146,37 @@ const (
 	prometheusPrefix      = "/prometheus"
 )
 
+// instantQueryTime returns the value of `time` param
+// for the instant query evaluated at timestamp.
+// Group's eval_delay has priority over -datasource.lookback.
+func (s *VMStorage) instantQueryTime(timestamp time.Time) time.Time {
+	if s.evalDelay > 0 {
+		timestamp = timestamp.Add(-s.evalDelay)
+	} else if s.lookBack > 0 {
+		timestamp = timestamp.Add(-s.lookBack)
+	}
+	if *queryTimeAlignment && s.evaluationInterval > 0 {
+		// see https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1232
+		// eval offset shifts the aligned timestamp from interval boundaries
+		timestamp = timestamp.Add(-s.evalOffset).Truncate(s.evaluationInterval).Add(s.evalOffset)
+	}
+	return timestamp
+}
+
 func (s *VMStorage) setPrometheusInstantReqParams(r *http.Request, query string, timestamp time.Time) {
 	if s.appendTypePrefix {
-		r.URL.Path += prometheusPrefix
+		r.URL.Path = appendTypePrefixPath(r.URL.Path, prometheusPrefix)
 	}
 	r.URL.Path += prometheusInstantPath
 	q := r.URL.Query()
-	if s.lookBack > 0 {
-		timestamp = timestamp.Add(-s.lookBack)
-	}
-	if s.evaluationInterval > 0 {
-		// see https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1232
-		timestamp = timestamp.Truncate(s.evaluationInterval)
-	}
-	q.Set("time", fmt.Sprintf("%d", timestamp.Unix()))
+	q.Set("time", fmt.Sprintf("%d", s.instantQueryTime(timestamp).Unix()))
 	r.URL.RawQuery = q.Encode()
 	s.setPrometheusReqParams(r, query)
 }
 
 func (s *VMStorage) setPrometheusRangeReqParams(r *http.Request, query string, start, end time.Time) {
 	if s.appendTypePrefix {
-		r.URL.Path += prometheusPrefix
+		r.URL.Path = appendTypePrefixPath(r.URL.Path, prometheusPrefix)
 	}
 	r.URL.Path += prometheusRangePath
 	q := r.URL.Query()
@@ -144,16 +186,23 @@ func (s *VMStorage) setPrometheusRangeReqParams(r *http.Request, query string, s
 	s.setPrometheusReqParams(r, query)
 }
 
+// formatStep returns d in seconds with millisecond precision,
+// e.g. `60` for 1m or `1.5` for 1500ms.
+func formatStep(d time.Duration) string {
+	ms := d.Round(time.Millisecond).Milliseconds()
+	return strconv.FormatFloat(float64(ms)/1e3, 'f', -1, 64)
+}
+
 func (s *VMStorage) setPrometheusReqParams(r *http.Request, query string) {
 	q := r.URL.Query()
 	q.Set("query", query)
 	if s.evaluationInterval > 0 {
 		// set step as evaluationInterval by default
-		q.Set("step", s.evaluationInterval.String())
+		q.Set("step", formatStep(s.evaluationInterval))
 	}
 	if s.queryStep > 0 {
 		// override step with user-specified value
-		q.Set("step", s.queryStep.String())
+		q.Set("step", formatStep(s.queryStep))
 	}
 	for _, l := range s.extraLabels {
 		q.Add("extra_label", l)
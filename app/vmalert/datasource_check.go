@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var waitForBackend = flag.Duration("datasource.waitForBackend", 0, "Optional duration to wait for -datasource.url to become available on start. "+
+	"If set, vmalert sends a trivial query to the datasource on start and retries it until success or until the duration passes. "+
+	"If set to zero, vmalert exits with error right away if the datasource isn't available. "+
+	"By default, the datasource isn't checked on start")
+
+// datasourceProbeQuery is a trivial query which
+// must succeed on any Prometheus-compatible datasource
+const datasourceProbeQuery = "vector(1)"
+
+// datasourceProbeTimeout is the timeout for a single probe
+const datasourceProbeTimeout = 5 * time.Second
+
+// datasourceStatus contains the result of the datasource
+// check on start displayed on the WEB UI
+type datasourceStatus struct {
+	Checked bool
+	Time    time.Time
+	Err     string
+}
+
+// startupDatasourceStatus is set once before starting
+// the web server, so it doesn't need synchronization
+var startupDatasourceStatus datasourceStatus
+
+// waitForDatasource probes the datasource until it responds successfully
+// or until timeout passes. The datasource is probed once if timeout is zero.
+func waitForDatasource(ctx context.Context, qb datasource.QuerierBuilder, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		err := probeDatasource(ctx, qb)
+		startupDatasourceStatus = datasourceStatus{Checked: true, Time: time.Now()}
+		if err == nil {
+			return nil
+		}
+		startupDatasourceStatus.Err = err.Error()
+		if !time.Now().Before(deadline) {
+			if timeout == 0 {
+				return fmt.Errorf("datasource isn't available: %w; check -datasource.url or set -datasource.waitForBackend for waiting for it", err)
+			}
+			return fmt.Errorf("datasource isn't available after waiting for %s: %w", timeout, err)
+		}
+		logger.Warnf("datasource isn't available yet (attempt %d): %s; retrying in a second", attempt, err)
+		t := time.NewTimer(time.Second)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func probeDatasource(ctx context.Context, qb datasource.QuerierBuilder) error {
+	ctx, cancel := context.WithTimeout(ctx, datasourceProbeTimeout)
+	defer cancel()
+	q := qb.BuildWithParams(datasource.QuerierParams{})
+	_, err := q.Query(ctx, datasourceProbeQuery, time.Now())
+	return err
+}
+
+// isFlagSet returns true if the flag with the given name
+// was explicitly set via command line or environment variables
+func isFlagSet(name string) bool {
+	var set bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForDatasource(t *testing.T) {
+	defer func() { startupDatasourceStatus = datasourceStatus{} }()
+	fq := &fakeQuerier{}
+
+	if err := waitForDatasource(context.Background(), fq, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !startupDatasourceStatus.Checked || startupDatasourceStatus.Err != "" {
+		t.Fatalf("unexpected datasource status: %+v", startupDatasourceStatus)
+	}
+
+	// zero timeout means fail fast
+	fq.setErr(fmt.Errorf("connection refused"))
+	err := waitForDatasource(context.Background(), fq, 0)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected to get datasource error; got %v", err)
+	}
+	if startupDatasourceStatus.Err == "" {
+		t.Fatalf("expected to get error in datasource status")
+	}
+
+	// the datasource becomes available while waiting
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		fq.setErr(nil)
+	}()
+	if err := waitForDatasource(context.Background(), fq, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if startupDatasourceStatus.Err != "" {
+		t.Fatalf("unexpected datasource status: %+v", startupDatasourceStatus)
+	}
+
+	// waiting is stopped on context cancel
+	fq.setErr(fmt.Errorf("connection refused"))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := waitForDatasource(ctx, fq, time.Minute); err == nil {
+		t.Fatalf("expected to get error")
+	}
+}
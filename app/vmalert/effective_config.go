@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// secretValue replaces values which may contain credentials
+const secretValue = "<secret>"
+
+// effectiveGroup is the group configuration
+// which is actually used for evaluation. It contains
+// rules with applied group labels, external labels
+// and default annotations.
+type effectiveGroup struct {
+	Name              string            `yaml:"name"`
+	File              string            `yaml:"file"`
+	Type              string            `yaml:"type"`
+	Interval          string            `yaml:"interval"`
+	EvalOffset        string            `yaml:"eval_offset,omitempty"`
+	EvalDelay         string            `yaml:"eval_delay,omitempty"`
+	Concurrency       int               `yaml:"concurrency"`
+	Limit             int               `yaml:"limit,omitempty"`
+	Tenant            string            `yaml:"tenant,omitempty"`
+	DatasourceURL     string            `yaml:"datasource_url,omitempty"`
+	Notifiers         []string          `yaml:"notifiers,omitempty"`
+	ExtraFilterLabels map[string]string `yaml:"extra_filter_labels,omitempty"`
+	Params            url.Values        `yaml:"params,omitempty"`
+	Headers           map[string]string `yaml:"headers,omitempty"`
+	Rules             []effectiveRule   `yaml:"rules"`
+}
+
+// effectiveRule is the rule configuration
+// which is actually used for evaluation
+type effectiveRule struct {
+	Alert                 string            `yaml:"alert,omitempty"`
+	Record                string            `yaml:"record,omitempty"`
+	Type                  string            `yaml:"type"`
+	Expr                  string            `yaml:"expr"`
+	For                   string            `yaml:"for,omitempty"`
+	KeepFiringFor         string            `yaml:"keep_firing_for,omitempty"`
+	Timeout               string            `yaml:"timeout,omitempty"`
+	NoData                string            `yaml:"no_data,omitempty"`
+	HealthErrorsThreshold int               `yaml:"health_errors_threshold"`
+	Labels                map[string]string `yaml:"labels,omitempty"`
+	Annotations           map[string]string `yaml:"annotations,omitempty"`
+}
+
+func (g *Group) toEffectiveConfig() effectiveGroup {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	eg := effectiveGroup{
+		Name:              g.Name,
+		File:              g.File,
+		Type:              g.Type.String(),
+		Interval:          g.Interval.String(),
+		EvalOffset:        durationToString(g.EvalOffset),
+		EvalDelay:         durationToString(g.EvalDelay),
+		Concurrency:       g.Concurrency,
+		Limit:             g.Limit,
+		Tenant:            g.Tenant,
+		DatasourceURL:     g.DatasourceURL,
+		Notifiers:         g.Notifiers,
+		ExtraFilterLabels: g.ExtraFilterLabels,
+		Params:            g.Params,
+	}
+	if len(g.Headers) > 0 {
+		// headers are commonly used for passing auth tokens
+		eg.Headers = make(map[string]string, len(g.Headers))
+		for k := range g.Headers {
+			eg.Headers[k] = secretValue
+		}
+	}
+	for _, r := range g.Rules {
+		switch v := r.(type) {
+		case *AlertingRule:
+			eg.Rules = append(eg.Rules, effectiveRule{
+				Alert:                 v.Name,
+				Type:                  v.Type.String(),
+				Expr:                  v.Expr,
+				For:                   durationToString(v.For),
+				KeepFiringFor:         durationToString(v.KeepFiringFor),
+				Timeout:               durationToString(v.Timeout),
+				NoData:                v.NoData,
+				Labels:                v.Labels,
+				HealthErrorsThreshold: v.HealthErrorsThreshold,
+				Annotations:           v.Annotations,
+			})
+		case *RecordingRule:
+			eg.Rules = append(eg.Rules, effectiveRule{
+				Record:                v.Name,
+				Type:                  v.Type.String(),
+				Expr:                  v.Expr,
+				Timeout:               durationToString(v.Timeout),
+				NoData:                v.NoData,
+				Labels:                v.Labels,
+				HealthErrorsThreshold: v.HealthErrorsThreshold,
+			})
+		}
+	}
+	return eg
+}
+
+// marshalEffectiveConfig returns effective configuration
+// of the given groups in YAML format
+func marshalEffectiveConfig(groups []*Group) ([]byte, error) {
+	egs := make([]effectiveGroup, 0, len(groups))
+	for _, g := range groups {
+		egs = append(egs, g.toEffectiveConfig())
+	}
+	// sort groups for deterministic output
+	sort.Slice(egs, func(i, j int) bool {
+		if egs[i].File != egs[j].File {
+			return egs[i].File < egs[j].File
+		}
+		return egs[i].Name < egs[j].Name
+	})
+	b, err := yaml.Marshal(struct {
+		Groups []effectiveGroup `yaml:"groups"`
+	}{egs})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal effective config: %w", err)
+	}
+	return b, nil
+}
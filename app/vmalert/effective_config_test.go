@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+)
+
+func TestMarshalEffectiveConfig(t *testing.T) {
+	defer func(m map[string]string) { defaultAnnotations = m }(defaultAnnotations)
+	defaultAnnotations = map[string]string{"runbook_url": "http://runbooks/{{ $labels.alertname }}"}
+
+	cfg := config.Group{
+		Name:    "test",
+		File:    "rules.yaml",
+		Labels:  map[string]string{"team": "a"},
+		Headers: []config.Header{{Key: "Authorization", Value: "Bearer token"}},
+		DatasourceBasicAuth: &config.BasicAuth{
+			Username: "foo",
+			Password: "passw0rd",
+		},
+		DatasourceURL: "http://vmselect:8481",
+		Rules: []config.Rule{
+			{Alert: "foo", Expr: "up == 0", For: utils.NewPromDuration(time.Minute)},
+			{Record: "bar", Expr: "sum(up)"},
+		},
+	}
+	g := newGroup(cfg, &fakeQuerier{}, time.Minute, map[string]string{"cluster": "east"})
+	b, err := marshalEffectiveConfig([]*Group{g})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, secret := range []string{"passw0rd", "Bearer token"} {
+		if strings.Contains(string(b), secret) {
+			t.Fatalf("expected %q to be redacted in effective config: %s", secret, b)
+		}
+	}
+
+	var got struct {
+		Groups []effectiveGroup `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("cannot unmarshal effective config: %s", err)
+	}
+	if len(got.Groups) != 1 || len(got.Groups[0].Rules) != 2 {
+		t.Fatalf("unexpected effective config: %s", b)
+	}
+	eg := got.Groups[0]
+	if eg.Headers["Authorization"] != secretValue {
+		t.Fatalf("expected header to be redacted; got %q", eg.Headers["Authorization"])
+	}
+	alert := eg.Rules[0]
+	if alert.For != "1m0s" {
+		t.Fatalf("expected for=1m0s; got %q", alert.For)
+	}
+	expLabels := map[string]string{"team": "a", "cluster": "east"}
+	if !reflect.DeepEqual(alert.Labels, expLabels) {
+		t.Fatalf("expected labels %v; got %v", expLabels, alert.Labels)
+	}
+	if !reflect.DeepEqual(alert.Annotations, defaultAnnotations) {
+		t.Fatalf("expected annotations %v; got %v", defaultAnnotations, alert.Annotations)
+	}
+	if record := eg.Rules[1]; record.Record != "bar" || len(record.Annotations) != 0 {
+		t.Fatalf("unexpected recording rule: %#v", record)
+	}
+}
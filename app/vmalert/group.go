@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"math/rand"
+	"net/url"
+	"sort"
 	"sync"
 	"time"
 
@@ -24,11 +28,27 @@ type Group struct {
 	Rules       []Rule
 	Type        datasource.Type
 	Interval    time.Duration
+	EvalOffset  time.Duration
+	EvalDelay   time.Duration
 	Concurrency int
+	Limit       int
 	Checksum    string
+	Tenant      string
+	// Notifiers contains names of notifiers
+	// the group's alerts are sent to.
+	// Alerts are sent to all notifiers if empty.
+	Notifiers []string
+	// DatasourceURL overrides -datasource.url
+	// for rules within the group if set.
+	DatasourceURL string
+	// datasourceAuth contains credentials for DatasourceURL.
+	// It is unexported, so it isn't exposed via API.
+	datasourceAuth *config.BasicAuth
 
 	ExtraFilterLabels map[string]string
 	Labels            map[string]string
+	Params            url.Values
+	Headers           map[string]string
 
 	doneCh     chan struct{}
 	finishedCh chan struct{}
@@ -42,6 +62,11 @@ type Group struct {
 type groupMetrics struct {
 	iterationTotal    *counter
 	iterationDuration *summary
+	iterationMissed   *counter
+
+	queryDuration      *summary
+	queryResponseBytes *counter
+	querySeries        *counter
 }
 
 func newGroupMetrics(name, file string) *groupMetrics {
@@ -49,6 +74,10 @@ func newGroupMetrics(name, file string) *groupMetrics {
 	labels := fmt.Sprintf(`group=%q, file=%q`, name, file)
 	m.iterationTotal = getOrCreateCounter(fmt.Sprintf(`vmalert_iteration_total{%s}`, labels))
 	m.iterationDuration = getOrCreateSummary(fmt.Sprintf(`vmalert_iteration_duration_seconds{%s}`, labels))
+	m.iterationMissed = getOrCreateCounter(fmt.Sprintf(`vmalert_iteration_missed_total{%s}`, labels))
+	m.queryDuration = getOrCreateSummary(fmt.Sprintf(`vmalert_datasource_query_duration_seconds{%s}`, labels))
+	m.queryResponseBytes = getOrCreateCounter(fmt.Sprintf(`vmalert_datasource_query_response_bytes_total{%s}`, labels))
+	m.querySeries = getOrCreateCounter(fmt.Sprintf(`vmalert_datasource_query_series_total{%s}`, labels))
 	return m
 }
 
@@ -69,16 +98,57 @@ func mergeLabels(groupName, ruleName string, set1, set2 map[string]string) map[s
 	return r
 }
 
+// querierParams returns datasource params
+// shared by all rules within the group
+func (g *Group) querierParams() datasource.QuerierParams {
+	p := datasource.QuerierParams{
+		EvaluationInterval: g.Interval,
+		EvalOffset:         g.EvalOffset,
+		EvalDelay:          g.EvalDelay,
+		ExtraLabels:        g.ExtraFilterLabels,
+		QueryParams:        g.Params,
+		Headers:            g.Headers,
+		Tenant:             g.Tenant,
+		DatasourceURL:      g.DatasourceURL,
+		OnQuery:            g.onQuery(),
+	}
+	if g.datasourceAuth != nil {
+		p.BasicAuthUser = g.datasourceAuth.Username
+		p.BasicAuthPass = g.datasourceAuth.Password
+	}
+	return p
+}
+
+// mergeAnnotations merges the given sets of annotations.
+// Every next set has priority over the previous ones.
+func mergeAnnotations(sets ...map[string]string) map[string]string {
+	r := map[string]string{}
+	for _, set := range sets {
+		for k, v := range set {
+			r[k] = v
+		}
+	}
+	return r
+}
+
 func newGroup(cfg config.Group, qb datasource.QuerierBuilder, defaultInterval time.Duration, labels map[string]string) *Group {
 	g := &Group{
 		Type:              cfg.Type,
 		Name:              cfg.Name,
 		File:              cfg.File,
 		Interval:          cfg.Interval.Duration(),
+		EvalOffset:        cfg.EvalOffset.Duration(),
+		EvalDelay:         *evalDelay,
 		Concurrency:       cfg.Concurrency,
+		Limit:             cfg.Limit,
 		Checksum:          cfg.Checksum,
+		Tenant:            cfg.Tenant,
+		Notifiers:         cfg.Notifiers,
+		DatasourceURL:     cfg.DatasourceURL,
+		datasourceAuth:    cfg.DatasourceBasicAuth,
 		ExtraFilterLabels: cfg.ExtraFilterLabels,
 		Labels:            cfg.Labels,
+		Params:            cfg.Params,
 
 		doneCh:     make(chan struct{}),
 		finishedCh: make(chan struct{}),
@@ -91,6 +161,18 @@ func newGroup(cfg config.Group, qb datasource.QuerierBuilder, defaultInterval ti
 	if g.Concurrency < 1 {
 		g.Concurrency = 1
 	}
+	if g.Tenant == "" {
+		g.Tenant = *defaultTenant
+	}
+	if len(cfg.Headers) > 0 {
+		g.Headers = make(map[string]string, len(cfg.Headers))
+		for _, h := range cfg.Headers {
+			g.Headers[h.Key] = h.Value
+		}
+	}
+	if cfg.EvalDelay != nil {
+		g.EvalDelay = cfg.EvalDelay.Duration()
+	}
 	rules := make([]Rule, len(cfg.Rules))
 	for i, r := range cfg.Rules {
 		var extraLabels map[string]string
@@ -106,6 +188,11 @@ func newGroup(cfg config.Group, qb datasource.QuerierBuilder, defaultInterval ti
 		if len(extraLabels) > 0 {
 			r.Labels = mergeLabels(g.Name, r.Name(), extraLabels, r.Labels)
 		}
+		// apply default and group annotations to alerting rules,
+		// rule annotations have priority on them
+		if r.Alert != "" && (len(defaultAnnotations) > 0 || len(cfg.Annotations) > 0) {
+			r.Annotations = mergeAnnotations(defaultAnnotations, cfg.Annotations, r.Annotations)
+		}
 
 		rules[i] = g.newRule(qb, r)
 	}
@@ -128,6 +215,12 @@ func (g *Group) ID() uint64 {
 	hash.Write([]byte("\xff"))
 	hash.Write([]byte(g.Name))
 	hash.Write([]byte(g.Type.Get()))
+	if g.Tenant != "" {
+		// tenant is hashed only if set so IDs of
+		// groups without tenant remain unchanged
+		hash.Write([]byte("\xff"))
+		hash.Write([]byte(g.Tenant))
+	}
 	return hash.Sum64()
 }
 
@@ -144,7 +237,7 @@ func (g *Group) Restore(ctx context.Context, qb datasource.QuerierBuilder, lookb
 		}
 		// ignore g.ExtraFilterLabels on purpose, so it
 		// won't affect the restore procedure.
-		q := qb.BuildWithParams(datasource.QuerierParams{})
+		q := qb.BuildWithParams(datasource.QuerierParams{Tenant: g.Tenant})
 		if err := rr.Restore(ctx, q, lookback, labels); err != nil {
 			return fmt.Errorf("error while restoring rule %q: %w", rule, err)
 		}
@@ -192,8 +285,15 @@ func (g *Group) updateWith(newGroup *Group) error {
 	}
 	g.Type = newGroup.Type
 	g.Concurrency = newGroup.Concurrency
+	g.Limit = newGroup.Limit
+	g.EvalDelay = newGroup.EvalDelay
 	g.ExtraFilterLabels = newGroup.ExtraFilterLabels
 	g.Labels = newGroup.Labels
+	g.Params = newGroup.Params
+	g.Headers = newGroup.Headers
+	g.Notifiers = newGroup.Notifiers
+	g.DatasourceURL = newGroup.DatasourceURL
+	g.datasourceAuth = newGroup.datasourceAuth
 	g.Checksum = newGroup.Checksum
 	g.Rules = newRules
 	return nil
@@ -208,6 +308,10 @@ func (g *Group) close() {
 
 	metrics.UnregisterMetric(g.metrics.iterationDuration.name)
 	metrics.UnregisterMetric(g.metrics.iterationTotal.name)
+	metrics.UnregisterMetric(g.metrics.iterationMissed.name)
+	metrics.UnregisterMetric(g.metrics.queryDuration.name)
+	metrics.UnregisterMetric(g.metrics.queryResponseBytes.name)
+	metrics.UnregisterMetric(g.metrics.querySeries.name)
 	for _, rule := range g.Rules {
 		rule.Close()
 	}
@@ -218,114 +322,268 @@ var skipRandSleepOnGroupStart bool
 func (g *Group) start(ctx context.Context, nts []notifier.Notifier, rw *remotewrite.Client) {
 	defer func() { close(g.finishedCh) }()
 
-	// Spread group rules evaluation over time in order to reduce load on VictoriaMetrics.
-	if !skipRandSleepOnGroupStart {
-		randSleep := uint64(float64(g.Interval) * (float64(uint32(g.ID())) / (1 << 32)))
-		sleepOffset := uint64(time.Now().UnixNano()) % uint64(g.Interval)
-		if randSleep < sleepOffset {
-			randSleep += uint64(g.Interval)
+	e := &executor{rw: rw}
+	e.setNotifiers(selectNotifiers(nts, g.Notifiers))
+	var lastEval time.Time
+	eval := func(ts time.Time) {
+		if missed := missedIterations(lastEval, ts, g.Interval); missed > 0 {
+			// alerts state doesn't depend on the number of evaluations,
+			// since `for` is compared with the time elapsed since ActiveAt
+			logger.Warnf("group %q: %d evaluations were missed, since the previous evaluation happened %v ago with interval %v; "+
+				"make sure vmalert isn't paused or starved of CPU", g.Name, missed, ts.Sub(lastEval), g.Interval)
+			g.metrics.iterationMissed.Add(missed)
 		}
-		randSleep -= sleepOffset
-		sleepTimer := time.NewTimer(time.Duration(randSleep))
-		select {
-		case <-ctx.Done():
-			sleepTimer.Stop()
-			return
-		case <-g.doneCh:
-			sleepTimer.Stop()
-			return
-		case <-sleepTimer.C:
+		lastEval = ts
+		g.metrics.iterationTotal.Inc()
+		// queries of the same iteration share the trace id
+		evalCtx := datasource.WithTraceID(ctx)
+		errs := e.execConcurrently(evalCtx, g.Rules, ts, g.Concurrency, g.Interval, g.Limit)
+		el := newErrorsLogger(g.Name, *suppressDuplicateTargetErrors)
+		for err := range errs {
+			if err != nil {
+				el.log(err)
+			}
 		}
+		el.flush()
+		g.metrics.iterationDuration.UpdateDuration(ts)
 	}
 
-	logger.Infof("group %q started; interval=%v; concurrency=%d", g.Name, g.Interval, g.Concurrency)
-	e := &executor{rw: rw}
-	for _, nt := range nts {
-		ent := eNotifier{
-			Notifier:         nt,
-			alertsSent:       getOrCreateCounter(fmt.Sprintf("vmalert_alerts_sent_total{addr=%q}", nt.Addr())),
-			alertsSendErrors: getOrCreateCounter(fmt.Sprintf("vmalert_alerts_send_errors_total{addr=%q}", nt.Addr())),
+	var startupEval time.Time
+	if !*disableStartupEval {
+		// evaluate the group as soon as possible, so firing alerts
+		// are re-sent and new alerts are detected right after the start
+		if d := *startupEvalMaxDelay; d > 0 {
+			if !g.sleep(ctx, time.Duration(rand.Int63n(int64(d)))) {
+				return
+			}
+		}
+		startupEval = time.Now()
+		eval(startupEval)
+	}
+
+	if !skipRandSleepOnGroupStart {
+		if !g.sleep(ctx, g.delayBeforeStart(time.Now())) {
+			return
+		}
+		// the ticker is started below, so the first tick happens an interval
+		// after the aligned moment. Skip the evaluation at the aligned moment
+		// if the startup evaluation happened less than half of interval ago,
+		// so the group isn't evaluated twice within a short period,
+		// while the gap after the startup evaluation doesn't exceed 1.5 intervals.
+		if time.Since(startupEval) >= g.Interval/2 {
+			eval(time.Now())
 		}
-		e.notifiers = append(e.notifiers, ent)
 	}
 
+	logger.Infof("group %q started; interval=%v; concurrency=%d", g.Name, g.Interval, g.Concurrency)
 	t := time.NewTicker(g.Interval)
-	defer t.Stop()
+	defer func() { t.Stop() }()
+	var realignCh <-chan time.Time
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Infof("group %q: context cancelled", g.Name)
+			if *resolveOnShutdown {
+				g.resolveAlerts(e, g.Rules)
+			}
 			return
 		case <-g.doneCh:
 			logger.Infof("group %q: received stop signal", g.Name)
+			// the group is stopped either because it was removed
+			// from config or because vmalert is shutting down
+			if ctx.Err() == nil || *resolveOnShutdown {
+				g.resolveAlerts(e, g.Rules)
+			}
 			return
 		case ng := <-g.updateCh:
 			g.mu.Lock()
+			removed := g.removedRules(ng)
 			err := g.updateWith(ng)
 			if err != nil {
 				logger.Errorf("group %q: failed to update: %s", g.Name, err)
 				g.mu.Unlock()
 				continue
 			}
-			if g.Interval != ng.Interval {
+			if g.Interval != ng.Interval || g.EvalOffset != ng.EvalOffset {
+				// the gap before the next evaluation may exceed the new interval
+				lastEval = time.Time{}
 				g.Interval = ng.Interval
+				g.EvalOffset = ng.EvalOffset
 				t.Stop()
-				t = time.NewTicker(g.Interval)
+				realignCh = nil
+				if skipRandSleepOnGroupStart {
+					t = time.NewTicker(g.Interval)
+				} else {
+					// restart the ticker at the group's phase within the new interval
+					realignCh = time.After(g.delayBeforeStart(time.Now()))
+				}
 			}
 			g.mu.Unlock()
+			g.resolveAlerts(e, removed)
+			// notifiers are updated after resolving removed rules,
+			// so resolved alerts are sent to the same notifiers as firing ones
+			e.setNotifiers(selectNotifiers(nts, ng.Notifiers))
 			logger.Infof("group %q re-started; interval=%v; concurrency=%d", g.Name, g.Interval, g.Concurrency)
+		case <-realignCh:
+			realignCh = nil
+			t = time.NewTicker(g.Interval)
 		case <-t.C:
-			g.metrics.iterationTotal.Inc()
-			iterationStart := time.Now()
+			eval(time.Now())
+		}
+	}
+}
 
-			errs := e.execConcurrently(ctx, g.Rules, g.Concurrency, g.Interval)
-			for err := range errs {
-				if err != nil {
-					logger.Errorf("group %q: %s", g.Name, err)
-				}
-			}
+// missedIterations returns the number of evaluations missed between
+// prev and ts evaluations with the given interval. Evaluations are
+// considered missed only if the gap between them exceeds 2 intervals,
+// so ticks jitter isn't treated as missed evaluations.
+func missedIterations(prev, ts time.Time, interval time.Duration) int {
+	if prev.IsZero() || interval <= 0 {
+		return 0
+	}
+	gap := ts.Sub(prev)
+	if gap <= 2*interval {
+		return 0
+	}
+	return int(gap/interval) - 1
+}
 
-			g.metrics.iterationDuration.UpdateDuration(iterationStart)
+// removedRules returns rules of g which are absent in newGroup
+func (g *Group) removedRules(newGroup *Group) []Rule {
+	ids := make(map[uint64]struct{}, len(newGroup.Rules))
+	for _, nr := range newGroup.Rules {
+		ids[nr.ID()] = struct{}{}
+	}
+	var removed []Rule
+	for _, r := range g.Rules {
+		if _, ok := ids[r.ID()]; !ok {
+			removed = append(removed, r)
+		}
+	}
+	return removed
+}
+
+// resolveAlerts sends resolve notifications for firing
+// alerts of the given rules, which are about to stop
+func (g *Group) resolveAlerts(e *executor, rules []Rule) {
+	if err := e.resolveAlerts(rules, time.Now()); err != nil {
+		logger.Errorf("group %q: %s", g.Name, err)
+	}
+}
+
+// delayBeforeStart returns the delay from ts until the first group evaluation.
+// If EvalOffset is set, the group is evaluated at interval boundaries
+// shifted by the offset. Otherwise, groups evaluation is spread over
+// time in order to reduce load on VictoriaMetrics. The phase within
+// the interval depends on the group ID, so it is stable across restarts.
+// If -rule.evalJitter is disabled, groups are evaluated at interval boundaries.
+func (g *Group) delayBeforeStart(ts time.Time) time.Duration {
+	if g.EvalOffset > 0 || !*evalJitter {
+		next := ts.Truncate(g.Interval).Add(g.EvalOffset)
+		if next.Before(ts) {
+			next = next.Add(g.Interval)
 		}
+		return next.Sub(ts)
+	}
+	randSleep := uint64(float64(g.Interval) * (float64(uint32(g.ID())) / (1 << 32)))
+	sleepOffset := uint64(ts.UnixNano()) % uint64(g.Interval)
+	if randSleep < sleepOffset {
+		randSleep += uint64(g.Interval)
+	}
+	randSleep -= sleepOffset
+	return time.Duration(randSleep)
+}
+
+// sleep waits for the given duration d.
+// It returns false if ctx was cancelled
+// or group was stopped meanwhile.
+func (g *Group) sleep(ctx context.Context, d time.Duration) bool {
+	sleepTimer := time.NewTimer(d)
+	defer sleepTimer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-g.doneCh:
+		return false
+	case <-sleepTimer.C:
+		return true
 	}
 }
 
 type executor struct {
-	notifiers []eNotifier
+	notifiers []notifier.Notifier
 	rw        *remotewrite.Client
+
+	mu sync.Mutex
+	// throttledUntil is the time until rules evaluation
+	// is skipped, since the datasource asked to retry later
+	throttledUntil time.Time
+}
+
+// throttle delays further evaluations for the given duration
+// capped at the group interval
+func (e *executor) throttle(d, interval time.Duration) {
+	if interval > 0 && d > interval {
+		d = interval
+	}
+	until := time.Now().Add(d)
+	e.mu.Lock()
+	if until.After(e.throttledUntil) {
+		e.throttledUntil = until
+	}
+	e.mu.Unlock()
 }
 
-type eNotifier struct {
-	notifier.Notifier
-	alertsSent       *counter
-	alertsSendErrors *counter
+func (e *executor) getThrottledUntil() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.throttledUntil
 }
 
-func (e *executor) execConcurrently(ctx context.Context, rules []Rule, concurrency int, interval time.Duration) chan error {
+// execConcurrently evaluates rules and sends alerts of all the rules
+// to notifiers in batches after the evaluation is finished.
+// The returned chan contains errors of the failed rules.
+func (e *executor) execConcurrently(ctx context.Context, rules []Rule, ts time.Time, concurrency int, interval time.Duration, limit int) chan error {
 	res := make(chan error, len(rules))
+	// pending is indexed by rules, so it may be filled concurrently
+	pending := make([]*ruleAlerts, len(rules))
+	eval := func(i int) {
+		ra, err := e.eval(ctx, rules[i], ts, interval, limit)
+		if err != nil {
+			res <- err
+			return
+		}
+		pending[i] = ra
+	}
+	flush := func() {
+		for _, err := range e.sendBatched(ctx, pending, ts) {
+			res <- err
+		}
+		close(res)
+	}
 	if concurrency == 1 {
 		// fast path
-		for _, rule := range rules {
-			res <- e.exec(ctx, rule, interval)
+		for i := range rules {
+			eval(i)
 		}
-		close(res)
+		flush()
 		return res
 	}
 
 	sem := make(chan struct{}, concurrency)
 	go func() {
 		wg := sync.WaitGroup{}
-		for _, rule := range rules {
+		for i := range rules {
 			sem <- struct{}{}
 			wg.Add(1)
-			go func(r Rule) {
-				res <- e.exec(ctx, r, interval)
+			go func(i int) {
+				eval(i)
 				<-sem
 				wg.Done()
-			}(rule)
+			}(i)
 		}
 		wg.Wait()
-		close(res)
+		flush()
 	}()
 	return res
 }
@@ -333,61 +591,383 @@ func (e *executor) execConcurrently(ctx context.Context, rules []Rule, concurren
 var (
 	alertsFired = metrics.NewCounter(`vmalert_alerts_fired_total`)
 
-	execTotal  = metrics.NewCounter(`vmalert_execution_total`)
-	execErrors = metrics.NewCounter(`vmalert_execution_errors_total`)
+	execTotal   = metrics.NewCounter(`vmalert_execution_total`)
+	execErrors  = metrics.NewCounter(`vmalert_execution_errors_total`)
+	execLimit   = metrics.NewCounter(`vmalert_execution_limit_exceeded_total`)
+	execSkipped = metrics.NewCounter(`vmalert_execution_skipped_total`)
 
 	remoteWriteErrors = metrics.NewCounter(`vmalert_remotewrite_errors_total`)
 )
 
-func (e *executor) exec(ctx context.Context, rule Rule, interval time.Duration) error {
+// transientError is returned for failed evaluations
+// of rules which are still considered healthy
+type transientError struct {
+	err error
+}
+
+func (te *transientError) Error() string { return te.err.Error() }
+func (te *transientError) Unwrap() error { return te.err }
+
+// errThrottled is returned for rules which evaluation was skipped,
+// since the datasource asked to retry later
+var errThrottled = errors.New("evaluation skipped, since the datasource throttles requests")
+
+// selectNotifiers returns notifiers with the given names.
+// All the notifiers are returned if names are empty.
+func selectNotifiers(nts []notifier.Notifier, names []string) []notifier.Notifier {
+	if len(names) == 0 {
+		return nts
+	}
+	var res []notifier.Notifier
+	for _, nt := range nts {
+		for _, name := range names {
+			if nt.Name() == name {
+				res = append(res, nt)
+				break
+			}
+		}
+	}
+	return res
+}
+
+func (e *executor) setNotifiers(nts []notifier.Notifier) {
+	e.notifiers = append(e.notifiers[:0], nts...)
+}
+
+// resolveTimeout is the max duration for sending
+// resolve notifications for stopped rules
+const resolveTimeout = 10 * time.Second
+
+// resolveAlerts sends firing alerts of the given rules to notifiers
+// as resolved at ts. It is used when rules are stopped, so notifiers
+// don't have to wait until alerts expire.
+func (e *executor) resolveAlerts(rules []Rule, ts time.Time) error {
+	var alerts []notifier.Alert
+	for _, rule := range rules {
+		ar, ok := rule.(*AlertingRule)
+		if !ok {
+			continue
+		}
+		ar.mu.RLock()
+		for _, a := range ar.alerts {
+			if a.State != notifier.StateFiring {
+				continue
+			}
+			resolved := *a
+			resolved.State = notifier.StateInactive
+			resolved.End = ts
+			alerts = append(alerts, resolved)
+		}
+		ar.mu.RUnlock()
+	}
+	if len(alerts) < 1 {
+		return nil
+	}
+
+	// context of the group may be already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	errGr := new(utils.ErrGroup)
+	for len(alerts) > 0 {
+		n := len(alerts)
+		if *maxBatchSize > 0 && n > *maxBatchSize {
+			n = *maxBatchSize
+		}
+		for _, err := range e.send(ctx, alerts[:n]) {
+			errGr.Add(fmt.Errorf("failed to send resolved alerts of stopped rules: %w", err))
+		}
+		alerts = alerts[n:]
+	}
+	return errGr.Err()
+}
+
+// ruleAlerts contains alerts of the rule which must be sent
+// to notifiers after the rule evaluation
+type ruleAlerts struct {
+	rule   *AlertingRule
+	alerts []notifier.Alert
+	// firing and resolved point to the rule's alerts,
+	// which are marked as sent after the successful delivery
+	firing, resolved []*notifier.Alert
+}
+
+// alertsBatch is a batch of alerts sent in a single request
+type alertsBatch struct {
+	alerts []notifier.Alert
+	// rules contains indexes of rules which alerts are in the batch
+	rules []int
+}
+
+// sendBatched sends alerts of the pending rules in batches
+// of up to -notifier.maxBatchSize alerts. Errors of the failed batch
+// are returned for every rule with alerts in this batch.
+// Alerts of the rest of rules are marked as sent at ts.
+func (e *executor) sendBatched(ctx context.Context, pending []*ruleAlerts, ts time.Time) []error {
+	var batches []*alertsBatch
+	b := &alertsBatch{}
+	for i, ra := range pending {
+		if ra == nil {
+			continue
+		}
+		for _, a := range ra.alerts {
+			if *maxBatchSize > 0 && len(b.alerts) >= *maxBatchSize {
+				batches = append(batches, b)
+				b = &alertsBatch{}
+			}
+			if n := len(b.rules); n == 0 || b.rules[n-1] != i {
+				b.rules = append(b.rules, i)
+			}
+			b.alerts = append(b.alerts, a)
+		}
+	}
+	if len(b.alerts) > 0 {
+		batches = append(batches, b)
+	}
+
+	errGrs := make(map[int]*utils.ErrGroup)
+	for _, b := range batches {
+		for _, err := range e.send(ctx, b.alerts) {
+			for _, i := range b.rules {
+				if errGrs[i] == nil {
+					errGrs[i] = new(utils.ErrGroup)
+				}
+				errGrs[i].Add(fmt.Errorf("rule %q: failed to send alerts: %w", pending[i].rule, err))
+			}
+		}
+	}
+
+	var errs []error
+	for i, ra := range pending {
+		if ra == nil {
+			continue
+		}
+		if errGr, ok := errGrs[i]; ok {
+			errs = append(errs, errGr.Err())
+			continue
+		}
+		ra.rule.mu.Lock()
+		for _, a := range ra.firing {
+			a.LastSent = ts
+		}
+		for _, a := range ra.resolved {
+			a.LastSent = ts
+			a.ResolvedSent++
+		}
+		ra.rule.mu.Unlock()
+	}
+	return errs
+}
+
+// send sends alerts to all the notifiers concurrently, so a slow
+// or unavailable notifier doesn't delay the delivery to the rest of them.
+// It returns errors of the failed notifiers.
+func (e *executor) send(ctx context.Context, alerts []notifier.Alert) []error {
+	errs := make([]error, len(e.notifiers))
+	var wg sync.WaitGroup
+	for i, nt := range e.notifiers {
+		wg.Add(1)
+		go func(i int, nt notifier.Notifier) {
+			defer wg.Done()
+			if err := nt.Send(ctx, alerts); err != nil {
+				errs[i] = &sendError{addr: nt.Addr(), err: err}
+			}
+		}(i, nt)
+	}
+	wg.Wait()
+
+	var res []error
+	for _, err := range errs {
+		if err != nil {
+			res = append(res, err)
+		}
+	}
+	return res
+}
+
+// sendError is returned by executor.send for every failed notifier
+type sendError struct {
+	addr string
+	err  error
+}
+
+func (se *sendError) Error() string { return se.err.Error() }
+func (se *sendError) Unwrap() error { return se.err }
+
+// errorsLogger logs errors of a single group evaluation.
+// If suppressDuplicates is set, only the first of identical errors
+// of every notifier is logged, and the number of failed sends
+// per notifier is logged by flush.
+type errorsLogger struct {
+	group string
+	// targets contains send errors by notifier address.
+	// It is nil if duplicates aren't suppressed.
+	targets map[string]*targetErrors
+}
+
+type targetErrors struct {
+	// seen contains messages of already logged errors
+	seen       map[string]struct{}
+	failed     int
+	suppressed int
+	lastErr    error
+}
+
+func newErrorsLogger(group string, suppressDuplicates bool) *errorsLogger {
+	el := &errorsLogger{group: group}
+	if suppressDuplicates {
+		el.targets = make(map[string]*targetErrors)
+	}
+	return el
+}
+
+func (el *errorsLogger) log(err error) {
+	errs := []error{err}
+	if eg, ok := err.(*utils.ErrGroup); ok && el.targets != nil {
+		// errors of the rule may belong to different notifiers
+		errs = eg.Errors()
+	}
+	for _, err := range errs {
+		var se *sendError
+		if el.targets != nil && errors.As(err, &se) {
+			te, ok := el.targets[se.addr]
+			if !ok {
+				te = &targetErrors{seen: make(map[string]struct{})}
+				el.targets[se.addr] = te
+			}
+			te.failed++
+			te.lastErr = se.err
+			msg := se.err.Error()
+			if _, ok := te.seen[msg]; ok {
+				te.suppressed++
+				continue
+			}
+			te.seen[msg] = struct{}{}
+		}
+		var te *transientError
+		if errors.As(err, &te) {
+			logger.Warnf("group %q: %s", el.group, err)
+			continue
+		}
+		logger.Errorf("group %q: %s", el.group, err)
+	}
+}
+
+// flush logs the summary for notifiers with suppressed errors
+func (el *errorsLogger) flush() {
+	addrs := make([]string, 0, len(el.targets))
+	for addr, te := range el.targets {
+		if te.suppressed > 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		te := el.targets[addr]
+		logger.Errorf("group %q: %d sends to %q failed: %s", el.group, te.failed, addr, te.lastErr)
+	}
+}
+
+// getResolveDuration returns the duration after which the sent firing
+// alert is resolved by notifier automatically. It is 4 times the
+// interval of re-sending the alert, but not less than maxDuration.
+func getResolveDuration(groupInterval, resendDelay, maxDuration time.Duration) time.Duration {
+	delta := groupInterval
+	if resendDelay > delta {
+		delta = resendDelay
+	}
+	d := 4 * delta
+	if maxDuration > d {
+		d = maxDuration
+	}
+	return d
+}
+
+// exec evaluates the rule and sends its alerts to notifiers
+func (e *executor) exec(ctx context.Context, rule Rule, ts time.Time, interval time.Duration, limit int) error {
+	ra, err := e.eval(ctx, rule, ts, interval, limit)
+	if err != nil || ra == nil {
+		return err
+	}
+	if errs := e.sendBatched(ctx, []*ruleAlerts{ra}, ts); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// eval evaluates the rule and returns alerts which must be sent to notifiers.
+// It returns nil if there are no alerts to send.
+func (e *executor) eval(ctx context.Context, rule Rule, ts time.Time, interval time.Duration, limit int) (*ruleAlerts, error) {
+	if until := e.getThrottledUntil(); time.Now().Before(until) {
+		// skipped evaluations don't affect the rule state and health
+		execSkipped.Inc()
+		return nil, &transientError{err: fmt.Errorf("rule %q: %w until %s", rule, errThrottled, until.Format(time.RFC3339))}
+	}
 	execTotal.Inc()
 
-	tss, err := rule.Exec(ctx)
+	tss, err := rule.Exec(ctx, ts, limit)
 	if err != nil {
 		execErrors.Inc()
-		return fmt.Errorf("rule %q: failed to execute: %w", rule, err)
+		if errors.Is(err, errLimitExceeded) {
+			execLimit.Inc()
+		}
+		var te *datasource.ThrottledError
+		if errors.As(err, &te) {
+			e.throttle(te.RetryAfter, interval)
+		}
+		err = fmt.Errorf("rule %q: failed to execute: %w", rule, err)
+		if rule.Healthy() {
+			// the rule didn't reach its health errors threshold yet
+			return nil, &transientError{err: err}
+		}
+		return nil, err
 	}
 
 	if len(tss) > 0 && e.rw != nil {
 		for _, ts := range tss {
 			if err := e.rw.Push(ts); err != nil {
 				remoteWriteErrors.Inc()
-				return fmt.Errorf("rule %q: remote write failure: %w", rule, err)
+				return nil, fmt.Errorf("rule %q: remote write failure: %w", rule, err)
 			}
 		}
 	}
 
 	ar, ok := rule.(*AlertingRule)
 	if !ok {
-		return nil
+		return nil, nil
 	}
 	var alerts []notifier.Alert
+	var firing, resolved []*notifier.Alert
+	ar.mu.Lock()
 	for _, a := range ar.alerts {
 		switch a.State {
 		case notifier.StateFiring:
-			// set End to execStart + 3 intervals
-			// so notifier can resolve it automatically if `vmalert`
-			// won't be able to send resolve for some reason
-			a.End = time.Now().Add(3 * interval)
+			if !a.LastSent.IsZero() && ts.Sub(a.LastSent) < *resendDelay {
+				// unchanged alert was sent recently
+				continue
+			}
+			// set End in the future, so notifier can resolve
+			// it automatically if `vmalert` won't be able
+			// to send resolve for some reason
+			a.End = ts.Add(getResolveDuration(interval, *resendDelay, *maxResolveDuration))
 			alerts = append(alerts, *a)
+			firing = append(firing, a)
 		case notifier.StateInactive:
-			// set End to execStart to notify
-			// that it was just resolved
-			a.End = time.Now()
+			if !ar.needsResolveSend(a, ts) {
+				continue
+			}
+			if a.ResolvedSent > 0 && ts.Sub(a.LastSent) < *resendDelay {
+				// resolved alert was sent recently
+				continue
+			}
+			// End is set to the moment when alert
+			// was resolved, see AlertingRule.Exec
 			alerts = append(alerts, *a)
+			resolved = append(resolved, a)
 		}
 	}
+	ar.mu.Unlock()
 	if len(alerts) < 1 {
-		return nil
-	}
-
-	errGr := new(utils.ErrGroup)
-	for _, nt := range e.notifiers {
-		nt.alertsSent.Add(len(alerts))
-		if err := nt.Send(ctx, alerts); err != nil {
-			nt.alertsSendErrors.Inc()
-			errGr.Add(fmt.Errorf("rule %q: failed to send alerts: %w", rule, err))
-		}
+		return nil, nil
 	}
-	return errGr.Err()
+	return &ruleAlerts{rule: ar, alerts: alerts, firing: firing, resolved: resolved}, nil
 }
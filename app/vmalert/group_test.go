@@ -2,7 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -72,6 +78,17 @@ func TestUpdateWith(t *testing.T) {
 				},
 			}},
 		},
+		{
+			"toggle debug",
+			[]config.Rule{
+				{Alert: "foo", Expr: "up > 0"},
+				{Record: "bar", Expr: "max(up)", Debug: true},
+			},
+			[]config.Rule{
+				{Alert: "foo", Expr: "up > 0", Debug: true},
+				{Record: "bar", Expr: "max(up)"},
+			},
+		},
 		{
 			"empty rule",
 			[]config.Rule{{Alert: "foo"}, {Record: "bar"}},
@@ -163,6 +180,78 @@ func TestUpdateWith(t *testing.T) {
 	}
 }
 
+func TestUpdateWithAlertsState(t *testing.T) {
+	defer func(v int) { *maxActiveAlerts = v }(*maxActiveAlerts)
+	*maxActiveAlerts = 10
+
+	fq := &fakeQuerier{}
+	fq.add(metricWithLabels(t, "instance", "foo"))
+	fq.add(metricWithLabels(t, "instance", "bar"))
+	newTestGroup := func(rules ...config.Rule) *Group {
+		g := &Group{Name: "test"}
+		for _, r := range rules {
+			r.ID = config.HashRule(r)
+			g.Rules = append(g.Rules, g.newRule(fq, r))
+		}
+		return g
+	}
+	pending := config.Rule{Alert: "pending", Expr: "up == 0", For: utils.NewPromDuration(10 * time.Minute)}
+	other := config.Rule{Alert: "other", Expr: "up == 0"}
+
+	g := newTestGroup(pending, other)
+	ar := g.Rules[0].(*AlertingRule)
+	defer activeAlerts.remove(ar)
+	if _, err := ar.Exec(context.TODO(), time.Now().Add(-9*time.Minute), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(ar.alerts) != 2 {
+		t.Fatalf("expected to have 2 alerts; got %d", len(ar.alerts))
+	}
+	activeAt := make(map[uint64]time.Time)
+	var firingID uint64
+	for h, a := range ar.alerts {
+		activeAt[h] = a.Start
+		if a.Labels["instance"] == "bar" {
+			// the alert became firing with respect to the old `for`
+			a.State = notifier.StateFiring
+			firingID = h
+		}
+	}
+
+	// change unrelated rule
+	other.Expr = "up != 1"
+	if err := g.updateWith(newTestGroup(pending, other)); err != nil {
+		t.Fatal(err)
+	}
+	if len(ar.alerts) != 2 {
+		t.Fatalf("expected to preserve 2 alerts; got %d", len(ar.alerts))
+	}
+	for h, a := range ar.alerts {
+		if !a.Start.Equal(activeAt[h]) {
+			t.Fatalf("expected to preserve %s alert active since %v; got active since %v", a.State, activeAt[h], a.Start)
+		}
+	}
+
+	// change `for` of the rule
+	pending.For = utils.NewPromDuration(5 * time.Minute)
+	if err := g.updateWith(newTestGroup(pending, other)); err != nil {
+		t.Fatal(err)
+	}
+	// pending alert is reset while firing alert keeps firing
+	if len(ar.alerts) != 1 {
+		t.Fatalf("expected to have 1 alert; got %d", len(ar.alerts))
+	}
+	if a, ok := ar.alerts[firingID]; !ok || a.State != notifier.StateFiring || !a.Start.Equal(activeAt[firingID]) {
+		t.Fatalf("expected to preserve firing alert; got %v", ar.alerts)
+	}
+	activeAlerts.mu.Lock()
+	n := activeAlerts.rules[ar].count
+	activeAlerts.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("expected 1 active alert to be tracked for the rule; got %d", n)
+	}
+}
+
 func TestGroupStart(t *testing.T) {
 	// TODO: make parsing from string instead of file
 	groups, err := config.Parse([]string{"config/testdata/rules1-good.rules"}, true, true)
@@ -173,6 +262,10 @@ func TestGroupStart(t *testing.T) {
 	fs := &fakeQuerier{}
 	fn := &fakeNotifier{}
 
+	// every evaluation must send firing alerts
+	defer func(v time.Duration) { *resendDelay = v }(*resendDelay)
+	*resendDelay = 0
+
 	g := newGroup(groups[0], fs, evalInterval, map[string]string{"cluster": "east-1"})
 	g.Concurrency = 2
 
@@ -235,3 +328,625 @@ func TestGroupStart(t *testing.T) {
 	g.close()
 	<-finished
 }
+
+func TestExecutorResendDelay(t *testing.T) {
+	defer func(v time.Duration) { *resendDelay = v }(*resendDelay)
+	*resendDelay = time.Minute
+
+	fq := &fakeQuerier{}
+	fn := &fakeNotifier{}
+	e := &executor{notifiers: []notifier.Notifier{fn}}
+	ar := newTestAlertingRule("test", 0)
+	ar.Annotations = map[string]string{"summary": "value is {{ $value }}"}
+	ar.q = fq
+
+	f := func(ts time.Time, expSent int) {
+		t.Helper()
+		fn.Lock()
+		fn.alerts = nil
+		fn.Unlock()
+		if err := e.exec(context.Background(), ar, ts, 15*time.Second, 0); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		if got := len(fn.getAlerts()); got != expSent {
+			t.Fatalf("expected to send %d alerts at %v; got %d", expSent, ts, got)
+		}
+	}
+
+	ts := time.Now()
+	fq.add(metricWithValueAndLabels(t, 1, "job", "foo"))
+	// newly firing alert is sent immediately
+	f(ts, 1)
+	// unchanged alert isn't re-sent until the delay elapses
+	f(ts.Add(15*time.Second), 0)
+	f(ts.Add(time.Minute), 1)
+	// changed alert is sent immediately
+	fq.reset()
+	fq.add(metricWithValueAndLabels(t, 2, "job", "foo"))
+	f(ts.Add(75*time.Second), 1)
+	f(ts.Add(90*time.Second), 0)
+	// resolved alert is sent immediately
+	fq.reset()
+	f(ts.Add(105*time.Second), 1)
+	if got := fn.getAlerts()[0].End; !got.Equal(ts.Add(105 * time.Second)) {
+		t.Fatalf("expected resolved alert to end at the resolve time; got %v", got)
+	}
+}
+
+func TestExecutorResendResolved(t *testing.T) {
+	defer func(v time.Duration, c int) {
+		*resendDelay = v
+		*resendResolvedCount = c
+	}(*resendDelay, *resendResolvedCount)
+	*resendDelay = 30 * time.Second
+	*resendResolvedCount = 2
+
+	fq := &fakeQuerier{}
+	fn := &fakeNotifier{}
+	e := &executor{notifiers: []notifier.Notifier{fn}}
+	ar := newTestAlertingRule("test", 0)
+	ar.q = fq
+
+	f := func(ts time.Time, expState notifier.AlertState, expSent int) {
+		t.Helper()
+		fn.Lock()
+		fn.alerts = nil
+		fn.Unlock()
+		if err := e.exec(context.Background(), ar, ts, 15*time.Second, 0); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+		alerts := fn.getAlerts()
+		if len(alerts) != expSent {
+			t.Fatalf("expected to send %d alerts at %v; got %d", expSent, ts, len(alerts))
+		}
+		for _, a := range alerts {
+			if a.State != expState {
+				t.Fatalf("expected to send alert in state %s; got %s", expState, a.State)
+			}
+		}
+	}
+
+	ts := time.Now()
+	fq.add(metricWithValueAndLabels(t, 1, "job", "foo"))
+	f(ts, notifier.StateFiring, 1)
+	fq.reset()
+	// resolved alert is sent immediately
+	f(ts.Add(15*time.Second), notifier.StateInactive, 1)
+	// and re-sent after the resend delay
+	f(ts.Add(30*time.Second), notifier.StateInactive, 0)
+	f(ts.Add(45*time.Second), notifier.StateInactive, 1)
+	// and is removed after it was sent -rule.resendResolvedCount times
+	f(ts.Add(60*time.Second), notifier.StateInactive, 0)
+	if len(ar.alerts) != 0 {
+		t.Fatalf("expected resolved alert to be removed; got %d alerts", len(ar.alerts))
+	}
+
+	// resolved alert is replaced if series is back
+	fq.add(metricWithValueAndLabels(t, 1, "job", "foo"))
+	f(ts.Add(75*time.Second), notifier.StateFiring, 1)
+	fq.reset()
+	f(ts.Add(90*time.Second), notifier.StateInactive, 1)
+	fq.add(metricWithValueAndLabels(t, 1, "job", "foo"))
+	f(ts.Add(105*time.Second), notifier.StateFiring, 1)
+}
+
+func TestNewGroupAnnotations(t *testing.T) {
+	defer func(m map[string]string) { defaultAnnotations = m }(defaultAnnotations)
+	defaultAnnotations = map[string]string{"runbook_url": "default", "dashboard": "default"}
+
+	cfg := config.Group{
+		Name:        "test",
+		Annotations: map[string]string{"dashboard": "group", "summary": "group"},
+		Rules: []config.Rule{
+			{Alert: "foo", Expr: "up", Annotations: map[string]string{"summary": "rule"}},
+			{Alert: "bar", Expr: "up"},
+			{Record: "baz", Expr: "up"},
+		},
+	}
+	g := newGroup(cfg, &fakeQuerier{}, time.Minute, nil)
+	f := func(r Rule, exp map[string]string) {
+		t.Helper()
+		ar := r.(*AlertingRule)
+		if !reflect.DeepEqual(ar.Annotations, exp) {
+			t.Fatalf("expected annotations %v for rule %q; got %v", exp, ar.Name, ar.Annotations)
+		}
+	}
+	f(g.Rules[0], map[string]string{"runbook_url": "default", "dashboard": "group", "summary": "rule"})
+	f(g.Rules[1], map[string]string{"runbook_url": "default", "dashboard": "group", "summary": "group"})
+	if cfg.Rules[0].Annotations["dashboard"] != "" {
+		t.Fatalf("expected config annotations to remain unchanged")
+	}
+}
+
+func TestGroupDatasourceURL(t *testing.T) {
+	cfg := config.Group{
+		Name:                "test",
+		DatasourceURL:       "http://vmselect-long-term:8481",
+		DatasourceBasicAuth: &config.BasicAuth{Username: "foo", Password: "secret"},
+		Rules:               []config.Rule{{Alert: "foo", Expr: "up"}},
+	}
+	g := newGroup(cfg, &fakeQuerier{}, time.Minute, nil)
+	p := g.querierParams()
+	if p.DatasourceURL != cfg.DatasourceURL || p.BasicAuthUser != "foo" || p.BasicAuthPass != "secret" {
+		t.Fatalf("unexpected querier params: %#v", p)
+	}
+	b, err := json.Marshal(g.toAPI())
+	if err != nil {
+		t.Fatalf("failed to marshal group: %s", err)
+	}
+	if !strings.Contains(string(b), cfg.DatasourceURL) {
+		t.Fatalf("expected datasource url to be present in API response: %s", b)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Fatalf("expected password to be absent in API response: %s", b)
+	}
+}
+
+func TestGroupRemovedRules(t *testing.T) {
+	newRule := func(id uint64) Rule {
+		r := newTestAlertingRule(fmt.Sprintf("rule-%d", id), 0)
+		r.RuleID = id
+		return r
+	}
+	g := &Group{Rules: []Rule{newRule(1), newRule(2), &RecordingRule{RuleID: 3}}}
+	ng := &Group{Rules: []Rule{newRule(2), newRule(4)}}
+	removed := g.removedRules(ng)
+	if len(removed) != 2 {
+		t.Fatalf("expected to get 2 removed rules; got %d", len(removed))
+	}
+	if removed[0].ID() != 1 || removed[1].ID() != 3 {
+		t.Fatalf("unexpected removed rules: %d, %d", removed[0].ID(), removed[1].ID())
+	}
+	if removed := g.removedRules(g); len(removed) != 0 {
+		t.Fatalf("expected to get no removed rules; got %d", len(removed))
+	}
+}
+
+func TestSelectNotifiers(t *testing.T) {
+	paging, tickets, unnamed := &fakeNotifier{name: "paging"}, &fakeNotifier{name: "tickets"}, &fakeNotifier{}
+	nts := []notifier.Notifier{paging, tickets, unnamed}
+	f := func(names []string, exp ...notifier.Notifier) {
+		t.Helper()
+		got := selectNotifiers(nts, names)
+		if len(got) != len(exp) {
+			t.Fatalf("expected to get %d notifiers for %q; got %d", len(exp), names, len(got))
+		}
+		for i := range exp {
+			if got[i] != exp[i] {
+				t.Fatalf("expected to get notifier %q at position %d; got %q", exp[i].Name(), i, got[i].Name())
+			}
+		}
+	}
+	f(nil, paging, tickets, unnamed)
+	f([]string{"tickets"}, tickets)
+	f([]string{"tickets", "paging"}, paging, tickets)
+	f([]string{"unknown"})
+}
+
+// blockingNotifier blocks sending until release is closed
+type blockingNotifier struct {
+	fakeNotifier
+	release chan struct{}
+}
+
+func (bn *blockingNotifier) Send(ctx context.Context, alerts []notifier.Alert) error {
+	<-bn.release
+	return bn.fakeNotifier.Send(ctx, alerts)
+}
+
+// faultyNotifier fails to send alerts
+type faultyNotifier struct {
+	fakeNotifier
+}
+
+func (*faultyNotifier) Send(context.Context, []notifier.Alert) error {
+	return errors.New("connection refused")
+}
+
+func TestExecutorSend(t *testing.T) {
+	blocking := &blockingNotifier{release: make(chan struct{})}
+	fn := &fakeNotifier{}
+	e := &executor{}
+	e.setNotifiers([]notifier.Notifier{blocking, &faultyNotifier{}, fn})
+
+	alerts := []notifier.Alert{{Name: "foo"}}
+	errCh := make(chan []error)
+	go func() { errCh <- e.send(context.Background(), alerts) }()
+
+	// alerts must be delivered to fn while
+	// the blocking notifier is still sending
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fn.getAlerts()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("alerts weren't delivered while the first notifier is blocked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(blocking.release)
+
+	errs := <-errCh
+	if len(errs) != 1 {
+		t.Fatalf("expected to get 1 error; got %v", errs)
+	}
+	if len(blocking.getAlerts()) != 1 {
+		t.Fatalf("expected alerts to be delivered to the blocking notifier")
+	}
+}
+
+// batchNotifier records the sent batches and fails
+// to send batches with alerts of the given rule
+type batchNotifier struct {
+	fakeNotifier
+	failRule string
+	batches  [][]string
+}
+
+func (bn *batchNotifier) Send(_ context.Context, alerts []notifier.Alert) error {
+	bn.Lock()
+	defer bn.Unlock()
+	var names []string
+	for _, a := range alerts {
+		names = append(names, a.Name)
+	}
+	bn.batches = append(bn.batches, names)
+	for _, a := range alerts {
+		if a.Name == bn.failRule {
+			return errors.New("connection refused")
+		}
+	}
+	return nil
+}
+
+func TestExecutorSendBatched(t *testing.T) {
+	defer func(v int) { *maxBatchSize = v }(*maxBatchSize)
+	*maxBatchSize = 2
+
+	bn := &batchNotifier{failRule: "r3"}
+	e := &executor{}
+	e.setNotifiers([]notifier.Notifier{bn})
+
+	var rules []Rule
+	for i, n := range []int{1, 2, 1} {
+		fq := &fakeQuerier{}
+		for j := 0; j < n; j++ {
+			fq.add(metricWithValueAndLabels(t, 1, "instance", strconv.Itoa(j)))
+		}
+		ar := newTestAlertingRule(fmt.Sprintf("r%d", i+1), 0)
+		ar.q = fq
+		rules = append(rules, ar)
+	}
+
+	ts := time.Now()
+	var errs []error
+	for err := range e.execConcurrently(context.Background(), rules, ts, 1, time.Minute, 0) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	expBatches := [][]string{{"r1", "r2"}, {"r2", "r3"}}
+	if !reflect.DeepEqual(bn.batches, expBatches) {
+		t.Fatalf("expected batches %v; got %v", expBatches, bn.batches)
+	}
+	// the failed batch contains alerts of r2 and r3
+	if len(errs) != 2 {
+		t.Fatalf("expected to get 2 errors; got %v", errs)
+	}
+	for i, name := range []string{"r2", "r3"} {
+		if !strings.Contains(errs[i].Error(), fmt.Sprintf("rule %q", name)) {
+			t.Fatalf("expected error for rule %q; got %s", name, errs[i])
+		}
+	}
+	for _, r := range rules {
+		ar := r.(*AlertingRule)
+		for _, a := range ar.alerts {
+			if sent := !a.LastSent.IsZero(); sent != (ar.Name == "r1") {
+				t.Fatalf("unexpected LastSent %v for alert of rule %q", a.LastSent, ar.Name)
+			}
+		}
+	}
+}
+
+func TestErrorsLogger(t *testing.T) {
+	refused := &sendError{addr: "http://am1", err: errors.New("connection refused")}
+	timeout := &sendError{addr: "http://am1", err: errors.New("timeout")}
+	other := &sendError{addr: "http://am2", err: errors.New("connection refused")}
+	ruleErr := func(rule string, errs ...error) error {
+		errGr := new(utils.ErrGroup)
+		for _, err := range errs {
+			errGr.Add(fmt.Errorf("rule %q: failed to send alerts: %w", rule, err))
+		}
+		return errGr.Err()
+	}
+
+	el := newErrorsLogger("test", true)
+	el.log(ruleErr("r1", refused, other))
+	el.log(ruleErr("r2", refused, other))
+	el.log(ruleErr("r3", timeout))
+	el.log(errors.New("rule \"r4\": failed to execute: unexpected error"))
+	el.flush()
+
+	te := el.targets["http://am1"]
+	if te.failed != 3 || te.suppressed != 1 {
+		t.Fatalf("expected 3 failed and 1 suppressed sends to am1; got %d failed and %d suppressed", te.failed, te.suppressed)
+	}
+	if te.lastErr != timeout.err {
+		t.Fatalf("expected last error %q; got %q", timeout.err, te.lastErr)
+	}
+	te = el.targets["http://am2"]
+	if te.failed != 2 || te.suppressed != 1 {
+		t.Fatalf("expected 2 failed and 1 suppressed sends to am2; got %d failed and %d suppressed", te.failed, te.suppressed)
+	}
+
+	el = newErrorsLogger("test", false)
+	el.log(ruleErr("r1", refused))
+	if el.targets != nil {
+		t.Fatalf("expected errors to be logged without tracking")
+	}
+}
+
+func TestExecutorResolveAlerts(t *testing.T) {
+	fq := &fakeQuerier{}
+	fn := &fakeNotifier{}
+	e := &executor{notifiers: []notifier.Notifier{fn}}
+	firing := newTestAlertingRule("firing", 0)
+	firing.q = fq
+	pending := newTestAlertingRule("pending", time.Hour)
+	pending.q = fq
+
+	ts := time.Now()
+	fq.add(metricWithValueAndLabels(t, 1, "job", "foo"))
+	for _, ar := range []*AlertingRule{firing, pending} {
+		if _, err := ar.Exec(context.Background(), ts, 0); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	resolveTS := ts.Add(time.Minute)
+	if err := e.resolveAlerts([]Rule{firing, pending, &RecordingRule{}}, resolveTS); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	alerts := fn.getAlerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected to send 1 resolved alert; got %d", len(alerts))
+	}
+	if alerts[0].State != notifier.StateInactive {
+		t.Fatalf("expected to send alert in state %s; got %s", notifier.StateInactive, alerts[0].State)
+	}
+	if !alerts[0].End.Equal(resolveTS) {
+		t.Fatalf("expected resolved alert to end at %v; got %v", resolveTS, alerts[0].End)
+	}
+	// state of the rule itself remains unchanged
+	for _, a := range firing.alerts {
+		if a.State != notifier.StateFiring {
+			t.Fatalf("expected rule's alert to remain in state %s; got %s", notifier.StateFiring, a.State)
+		}
+	}
+}
+
+func TestGroupResolveRemovedRules(t *testing.T) {
+	groups, err := config.Parse([]string{"config/testdata/rules1-good.rules"}, true, true)
+	if err != nil {
+		t.Fatalf("failed to parse rules: %s", err)
+	}
+	defer func(v time.Duration) { *resendDelay = v }(*resendDelay)
+	*resendDelay = 0
+
+	fs := &fakeQuerier{}
+	fn := &fakeNotifier{}
+	fs.add(metricWithLabels(t, "instance", "foo", "job", "bar"))
+	g := newGroup(groups[0], fs, time.Millisecond, nil)
+	finished := make(chan struct{})
+	go func() {
+		g.start(context.Background(), []notifier.Notifier{fn}, nil)
+		close(finished)
+	}()
+
+	waitForState := func(exp notifier.AlertState) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			alerts := fn.getAlerts()
+			if len(alerts) > 0 && alerts[0].State == exp {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("expected to receive alert in state %s", exp)
+	}
+	waitForState(notifier.StateFiring)
+
+	// remove all rules from the group
+	cfg := groups[0]
+	cfg.Rules = nil
+	g.updateCh <- newGroup(cfg, fs, time.Millisecond, nil)
+	waitForState(notifier.StateInactive)
+
+	g.close()
+	<-finished
+}
+
+func TestGetResolveDuration(t *testing.T) {
+	f := func(interval, resendDelay, maxDuration, exp time.Duration) {
+		t.Helper()
+		if got := getResolveDuration(interval, resendDelay, maxDuration); got != exp {
+			t.Fatalf("expected resolve duration %v; got %v", exp, got)
+		}
+	}
+	f(time.Minute, 0, 0, 4*time.Minute)
+	f(15*time.Second, 30*time.Second, 0, 2*time.Minute)
+	f(time.Minute, 30*time.Second, 10*time.Minute, 10*time.Minute)
+	f(time.Minute, 0, time.Minute, 4*time.Minute)
+}
+
+func TestGroupDelayBeforeStart(t *testing.T) {
+	g := &Group{Name: "test", Interval: time.Hour, EvalOffset: 5 * time.Minute}
+
+	ts := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	if d := g.delayBeforeStart(ts); d != 5*time.Minute {
+		t.Fatalf("expected delay of 5m; got %v", d)
+	}
+	ts = time.Date(2021, 1, 1, 10, 30, 0, 0, time.UTC)
+	if d := g.delayBeforeStart(ts); d != 35*time.Minute {
+		t.Fatalf("expected delay of 35m; got %v", d)
+	}
+	ts = time.Date(2021, 1, 1, 10, 5, 0, 0, time.UTC)
+	if d := g.delayBeforeStart(ts); d != 0 {
+		t.Fatalf("expected no delay; got %v", d)
+	}
+
+	g.EvalOffset = 0
+	if d := g.delayBeforeStart(ts); d < 0 || d >= g.Interval {
+		t.Fatalf("expected delay within the interval; got %v", d)
+	}
+	// the phase must be stable over time
+	phase := ts.Add(g.delayBeforeStart(ts)).Sub(ts.Truncate(g.Interval)) % g.Interval
+	for _, d := range []time.Duration{time.Second, 17 * time.Minute, 3 * time.Hour} {
+		next := ts.Add(d)
+		got := next.Add(g.delayBeforeStart(next)).Sub(next.Truncate(g.Interval)) % g.Interval
+		if got != phase {
+			t.Fatalf("expected to get the same phase %v at %v; got %v", phase, next, got)
+		}
+	}
+
+	*evalJitter = false
+	defer func() { *evalJitter = true }()
+	ts = time.Date(2021, 1, 1, 10, 20, 0, 0, time.UTC)
+	if d := g.delayBeforeStart(ts); d != 40*time.Minute {
+		t.Fatalf("expected delay of 40m; got %v", d)
+	}
+}
+
+func TestGroupStartupEval(t *testing.T) {
+	groups, err := config.Parse([]string{"config/testdata/rules1-good.rules"}, true, true)
+	if err != nil {
+		t.Fatalf("failed to parse rules: %s", err)
+	}
+	f := func(disable bool) {
+		t.Helper()
+		*disableStartupEval = disable
+		defer func() { *disableStartupEval = false }()
+
+		// interval is big enough, so no ticks happen during the test
+		g := newGroup(groups[0], &fakeQuerier{}, time.Hour, nil)
+		finished := make(chan struct{})
+		go func() {
+			g.start(context.Background(), []notifier.Notifier{&fakeNotifier{}}, nil)
+			close(finished)
+		}()
+		defer func() {
+			g.close()
+			<-finished
+		}()
+
+		wait := time.Second
+		if disable {
+			wait = 100 * time.Millisecond
+		}
+		deadline := time.Now().Add(wait)
+		for g.metrics.iterationTotal.Get() == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		evaluated := g.metrics.iterationTotal.Get() > 0
+		if evaluated == disable {
+			t.Fatalf("expected group to be evaluated on start: %v; got evaluated: %v", !disable, evaluated)
+		}
+	}
+	f(false)
+	f(true)
+}
+
+func TestGroupStartupEvalAligned(t *testing.T) {
+	groups, err := config.Parse([]string{"config/testdata/rules1-good.rules"}, true, true)
+	if err != nil {
+		t.Fatalf("failed to parse rules: %s", err)
+	}
+	skipRandSleepOnGroupStart = false
+	defer func() { skipRandSleepOnGroupStart = true }()
+
+	interval := time.Second
+	g := newGroup(groups[0], &fakeQuerier{}, interval, nil)
+	start := time.Now()
+	delay := g.delayBeforeStart(start)
+	for d := delay - interval/2; d > -100*time.Millisecond && d < 100*time.Millisecond; d = delay - interval/2 {
+		// avoid starting close to the threshold for skipping the aligned evaluation
+		time.Sleep(200 * time.Millisecond)
+		start = time.Now()
+		delay = g.delayBeforeStart(start)
+	}
+	// the group is evaluated at the aligned moment only if the startup
+	// evaluation happened at least half of interval before it
+	exp := uint64(1)
+	if delay >= interval/2 {
+		exp = 2
+	}
+	// the first tick can't happen earlier than interval after the aligned moment
+	checkAt := start.Add(delay + interval/2)
+	finished := make(chan struct{})
+	go func() {
+		g.start(context.Background(), []notifier.Notifier{&fakeNotifier{}}, nil)
+		close(finished)
+	}()
+	defer func() {
+		g.close()
+		<-finished
+	}()
+
+	time.Sleep(time.Until(checkAt))
+	if n := g.metrics.iterationTotal.Get(); n != exp {
+		t.Fatalf("expected group to be evaluated %d times before the first tick with delay %v; got %d evaluations", exp, delay, n)
+	}
+}
+
+func TestMissedIterations(t *testing.T) {
+	f := func(prev, ts time.Time, interval time.Duration, exp int) {
+		t.Helper()
+		if got := missedIterations(prev, ts, interval); got != exp {
+			t.Fatalf("expected %d missed iterations; got %d", exp, got)
+		}
+	}
+	ts := time.Now()
+	f(time.Time{}, ts, time.Minute, 0)
+	f(ts.Add(-time.Minute), ts, time.Minute, 0)
+	// jitter of ticks isn't treated as missed iterations
+	f(ts.Add(-2*time.Minute), ts, time.Minute, 0)
+	f(ts.Add(-150*time.Second), ts, time.Minute, 1)
+	f(ts.Add(-10*time.Minute), ts, time.Minute, 9)
+	f(ts.Add(-10*time.Minute), ts, 0, 0)
+}
+
+func TestExecutorThrottle(t *testing.T) {
+	fq := &fakeQuerier{}
+	e := &executor{}
+	ar := newTestAlertingRule("test", 0)
+	ar.q = fq
+	rr := &RecordingRule{Name: "record", state: newRuleState(10), q: fq}
+
+	fq.setErr(&datasource.ThrottledError{RetryAfter: time.Hour, Err: fmt.Errorf("too many requests")})
+	err := e.exec(context.Background(), ar, time.Now(), time.Minute, 0)
+	if err == nil || errors.Is(err, errThrottled) {
+		t.Fatalf("expected to get datasource error; got %v", err)
+	}
+
+	// the next evaluations are skipped without querying the datasource
+	fq.reset()
+	err = e.exec(context.Background(), rr, time.Now(), time.Minute, 0)
+	if !errors.Is(err, errThrottled) {
+		t.Fatalf("expected to get errThrottled; got %v", err)
+	}
+	var te *transientError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected skipped evaluation to be a transient error")
+	}
+	if rr.lastExecError != nil || !rr.lastExecTime.IsZero() {
+		t.Fatalf("skipped evaluation mustn't change the rule state")
+	}
+
+	// the delay is capped at the group interval
+	if d := time.Until(e.getThrottledUntil()); d > time.Minute {
+		t.Fatalf("expected throttling to be capped at the group interval; got %v", d)
+	}
+	e.throttledUntil = time.Time{}
+	if err := e.exec(context.Background(), rr, time.Now(), time.Minute, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
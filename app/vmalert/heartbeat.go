@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	heartbeatInterval = flag.Duration("notifier.heartbeat.interval", 0, "How often to send the heartbeat alert to all notifiers. "+
+		"The heartbeat alert is always firing, so it may be used as dead man's switch: the receiver raises an incident "+
+		"if the alert stops arriving. The alert is sent regardless of rules evaluation and datasource availability. "+
+		"By default, the heartbeat is disabled")
+	heartbeatAlertName = flag.String("notifier.heartbeat.alertName", "Watchdog", "The name of the heartbeat alert. See -notifier.heartbeat.interval")
+	heartbeatLabels    = flagutil.NewArray("notifier.heartbeat.label", "Optional label in the form 'name=value' to add to the heartbeat alert "+
+		"in addition to -external.label. See -notifier.heartbeat.interval")
+)
+
+var heartbeatSendErrors = metrics.NewCounter(`vmalert_heartbeat_send_errors_total`)
+
+// heartbeat sends the firing alert to notifiers every interval
+type heartbeat struct {
+	interval  time.Duration
+	notifiers []notifier.Notifier
+	alert     notifier.Alert
+}
+
+// newHeartbeat returns heartbeat configured via -notifier.heartbeat.* flags.
+// It returns nil if the heartbeat is disabled.
+func newHeartbeat(nts []notifier.Notifier) (*heartbeat, error) {
+	if *heartbeatInterval <= 0 {
+		return nil, nil
+	}
+	if len(nts) == 0 {
+		return nil, fmt.Errorf("`-notifier.heartbeat.interval` requires `-notifier.url` or `-notifier.config` to be set")
+	}
+	if *heartbeatAlertName == "" {
+		return nil, fmt.Errorf("`-notifier.heartbeat.alertName` can't be empty")
+	}
+	labels, err := parseExternalLabels(*externalLabels)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := parseExternalLabels(*heartbeatLabels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid `-notifier.heartbeat.label`: %w", err)
+	}
+	for k, v := range extra {
+		labels[k] = v
+	}
+	h := fnv.New64a()
+	h.Write([]byte(*heartbeatAlertName))
+	return &heartbeat{
+		interval:  *heartbeatInterval,
+		notifiers: nts,
+		alert: notifier.Alert{
+			Name:   *heartbeatAlertName,
+			Labels: labels,
+			State:  notifier.StateFiring,
+			// the alert has the same start during vmalert lifetime,
+			// so the receiver treats it as the same alert
+			Start: time.Now(),
+			ID:    h.Sum64(),
+		},
+	}, nil
+}
+
+// run sends the heartbeat alert until ctx is cancelled
+func (hb *heartbeat) run(ctx context.Context) {
+	t := time.NewTicker(hb.interval)
+	defer t.Stop()
+	for {
+		hb.send(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// send sends the heartbeat alert to all notifiers concurrently.
+// The alert expires after a few missed intervals, so the receiver
+// resolves it if vmalert stops sending it.
+func (hb *heartbeat) send(ctx context.Context, ts time.Time) {
+	a := hb.alert
+	a.End = ts.Add(getResolveDuration(hb.interval, 0, 0))
+	var wg sync.WaitGroup
+	for _, nt := range hb.notifiers {
+		wg.Add(1)
+		go func(nt notifier.Notifier) {
+			defer wg.Done()
+			if err := nt.Send(ctx, []notifier.Alert{a}); err != nil {
+				heartbeatSendErrors.Inc()
+				logger.Errorf("failed to send heartbeat alert %q to %q: %s", a.Name, nt.Addr(), err)
+			}
+		}(nt)
+	}
+	wg.Wait()
+}
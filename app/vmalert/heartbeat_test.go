@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+)
+
+func TestHeartbeat(t *testing.T) {
+	oldInterval, oldLabels, oldExternalLabels := *heartbeatInterval, *heartbeatLabels, *externalLabels
+	defer func() {
+		*heartbeatInterval, *heartbeatLabels, *externalLabels = oldInterval, oldLabels, oldExternalLabels
+	}()
+
+	hb, err := newHeartbeat(nil)
+	if err != nil || hb != nil {
+		t.Fatalf("expected disabled heartbeat; got %v, %v", hb, err)
+	}
+	*heartbeatInterval = time.Minute
+	if _, err := newHeartbeat(nil); err == nil {
+		t.Fatalf("expected error for heartbeat without notifiers")
+	}
+	*heartbeatLabels = []string{"severity"}
+	if _, err := newHeartbeat([]notifier.Notifier{&fakeNotifier{}}); err == nil {
+		t.Fatalf("expected error for invalid label")
+	}
+
+	*externalLabels = []string{"cluster=east", "severity=critical"}
+	*heartbeatLabels = []string{"severity=none"}
+	fn := &fakeNotifier{}
+	hb, err = newHeartbeat([]notifier.Notifier{fn})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ts := time.Now()
+	for i := 0; i < 2; i++ {
+		hb.send(context.Background(), ts.Add(time.Duration(i)*hb.interval))
+	}
+	alerts := fn.getAlerts()
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert; got %d", len(alerts))
+	}
+	a := alerts[0]
+	if a.Name != "Watchdog" || a.State != notifier.StateFiring {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+	// heartbeat labels override external labels
+	if a.Labels["cluster"] != "east" || a.Labels["severity"] != "none" {
+		t.Fatalf("unexpected labels %v", a.Labels)
+	}
+	if exp := ts.Add(5 * hb.interval); !a.End.Equal(exp) {
+		t.Fatalf("expected EndsAt %v; got %v", exp, a.End)
+	}
+	if !a.Start.Equal(hb.alert.Start) {
+		t.Fatalf("expected the same StartsAt %v; got %v", hb.alert.Start, a.Start)
+	}
+
+	// the heartbeat is sent on start without waiting for the interval
+	fn = &fakeNotifier{}
+	hb.notifiers = []notifier.Notifier{fn}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hb.run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fn.getAlerts()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout while waiting for the heartbeat")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
@@ -44,10 +44,10 @@ func (fq *fakeQuerier) BuildWithParams(_ datasource.QuerierParams) datasource.Qu
 }
 
 func (fq *fakeQuerier) QueryRange(ctx context.Context, q string, _, _ time.Time) ([]datasource.Metric, error) {
-	return fq.Query(ctx, q)
+	return fq.Query(ctx, q, time.Now())
 }
 
-func (fq *fakeQuerier) Query(_ context.Context, _ string) ([]datasource.Metric, error) {
+func (fq *fakeQuerier) Query(_ context.Context, _ string, _ time.Time) ([]datasource.Metric, error) {
 	fq.Lock()
 	defer fq.Unlock()
 	if fq.err != nil {
@@ -60,10 +60,12 @@ func (fq *fakeQuerier) Query(_ context.Context, _ string) ([]datasource.Metric,
 
 type fakeNotifier struct {
 	sync.Mutex
+	name   string
 	alerts []notifier.Alert
 }
 
-func (*fakeNotifier) Addr() string { return "" }
+func (*fakeNotifier) Addr() string    { return "" }
+func (fn *fakeNotifier) Name() string { return fn.name }
 func (fn *fakeNotifier) Send(_ context.Context, alerts []notifier.Alert) error {
 	fn.Lock()
 	defer fn.Unlock()
@@ -160,6 +162,9 @@ func compareRecordingRules(t *testing.T, a, b *RecordingRule) error {
 	if !reflect.DeepEqual(a.Labels, b.Labels) {
 		return fmt.Errorf("expected to have labels %#v; got %#v", a.Labels, b.Labels)
 	}
+	if a.Debug != b.Debug {
+		return fmt.Errorf("expected to have debug %v; got %v", a.Debug, b.Debug)
+	}
 	return nil
 }
 
@@ -180,6 +185,9 @@ func compareAlertingRules(t *testing.T, a, b *AlertingRule) error {
 	if a.Type.String() != b.Type.String() {
 		return fmt.Errorf("expected to have Type %#v; got %#v", a.Type.String(), b.Type.String())
 	}
+	if a.Debug != b.Debug {
+		return fmt.Errorf("expected to have debug %v; got %v", a.Debug, b.Debug)
+	}
 	return nil
 }
 
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"net"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,19 +36,77 @@ Examples:
  -rule="/path/to/file". Path to a single file with alerting rules
  -rule="dir/*.yaml" -rule="/*.yaml". Relative path to all .yaml files in "dir" folder,
 absolute path to all .yaml files in root.
-Rule files may contain %{ENV_VAR} placeholders, which are substituted by the corresponding env vars.`)
+ -rule="https://example.com/rules.yaml". Rules file fetched via http:// or https:// URL, see also -rule.urlHeaders
+ -rule="s3://bucket/path/to/rules.yaml" -rule="gs://bucket/path/to/rules.yaml". Rules file fetched from S3 or GCS object.
+Credentials are loaded from default locations.
+Rule files may contain %{ENV_VAR} placeholders, which are substituted by the corresponding env vars. See also -rule.evalEnvVars.
+Files with .json extension are parsed as JSON with the same schema as YAML files.
+ -rule="-". Rules are read from stdin on start. Rules reload isn't supported in this case. It cannot be mixed with other paths.`)
 
+	ruleAllowEmpty = flag.Bool("rule.allowEmpty", false, "Whether to allow starting vmalert when -rule patterns match no files. "+
+		"If set, vmalert starts with no groups and loads them on the subsequent config reload once the files appear. "+
+		"By default, vmalert fails to start in this case, so typos in -rule patterns are caught early")
 	rulesCheckInterval = flag.Duration("rule.configCheckInterval", 0, "Interval for checking for changes in '-rule' files. "+
 		"By default the checking is disabled. Send SIGHUP signal in order to force config check for changes")
 
 	httpListenAddr     = flag.String("httpListenAddr", ":8880", "Address to listen for http connections")
 	evaluationInterval = flag.Duration("evaluationInterval", time.Minute, "How often to evaluate the rules")
+	defaultTenant      = flag.String("defaultTenant", "", "Default tenant in the form `accountID[:projectID]` for groups without tenant param. "+
+		"If set, rules queries are sent to `<-datasource.url>/select/<tenant>/` and results are written to `<-remoteWrite.url>/insert/<tenant>/prometheus`. "+
+		"Compatible only with the cluster version of VictoriaMetrics")
+	evalDelay = flag.Duration("rule.evalDelay", 0, "Default delay subtracted from the evaluation timestamp sent to the datasource. "+
+		"Helps to avoid evaluating rules over incomplete data when data is ingested with a delay. "+
+		"Alerts activation and notification timestamps are not affected. Can be overridden by group's eval_delay param. "+
+		"If set, it takes priority over -datasource.lookback")
+	queryTimeout = flag.Duration("datasource.queryTimeout", 0, "Default timeout for rule's query to the datasource. "+
+		"If exceeded, the query is cancelled and the rule is marked with an error for the current evaluation, "+
+		"so the slow rule doesn't delay the rest of rules in the group. Can be overridden by rule's timeout param. "+
+		"By default, queries aren't limited")
+	evalJitter = flag.Bool("rule.evalJitter", true, "Whether to spread evaluations of groups uniformly over their evaluation interval "+
+		"in order to avoid load spikes on the datasource. The phase of every group depends on the hash of its name and file, so it is stable across restarts. "+
+		"If disabled, groups without eval_offset are evaluated at interval boundaries")
+	disableStartupEval = flag.Bool("rule.disableStartupEval", false, "Whether to disable groups evaluation right after the start. "+
+		"If set, the first evaluation of every group happens after the group's interval")
+	startupEvalMaxDelay = flag.Duration("rule.startupEvalMaxDelay", 0, "The max random delay before the first evaluation of every group after the start. "+
+		"It may be used for spreading the load on the datasource at the start of vmalert with many groups. See also -rule.disableStartupEval")
+	resendDelay = flag.Duration("rule.resendDelay", 30*time.Second, "The minimum delay before re-sending the unchanged firing alert to notifiers. "+
+		"Newly firing, resolved and changed alerts are sent immediately regardless of the delay. "+
+		"Setting it to 0 re-sends firing alerts on every evaluation")
+	healthErrorsThreshold = flag.Int("rule.healthErrorsThreshold", 1, "The number of consecutive failed evaluations after which the rule is marked unhealthy. "+
+		"Earlier failures are logged at warn level and don't affect rule's health in API and vmalert_*_rules_error metrics. "+
+		"Can be overridden by rule's health_errors_threshold param")
+	resendResolvedCount = flag.Int("rule.resendResolvedCount", 1, "How many times the resolved alert is sent to notifiers. "+
+		"Repeated sends happen not more often than -rule.resendDelay. Resolved alerts are retried on send errors "+
+		"until they are sent the given number of times or until the resolve duration elapses, see -rule.maxResolveDuration")
+	maxResolveDuration = flag.Duration("rule.maxResolveDuration", 0, "The min duration after which the sent firing alert is resolved by notifier automatically "+
+		"if vmalert stops sending it, e.g. after the crash. The actual duration is the max of the flag value and 4 times "+
+		"the group's evaluation interval or -rule.resendDelay. By default, the duration is derived from the group's interval")
+	resolveOnShutdown = flag.Bool("rule.resolveOnShutdown", false, "Whether to send resolve notifications for firing alerts on graceful shutdown. "+
+		"It isn't recommended for setups with alerts state restoring via -remoteRead.url, since alerts are re-sent after the restart. "+
+		"Alerts of rules removed from config on hot reload are always resolved")
+	maxBatchSize = flag.Int("notifier.maxBatchSize", 64, "The max number of alerts sent to notifiers in a single request. "+
+		"Alerts of all the rules of a group are accumulated during the group evaluation and are sent in batches of the given size. "+
+		"Set to 0 for sending all the alerts of the group evaluation in a single request")
+	suppressDuplicateTargetErrors = flag.Bool("notifier.suppressDuplicateTargetErrors", false, "Whether to log only the first of identical errors "+
+		"of every notifier during the group evaluation. The rest of errors are counted and summarized by a single line per notifier "+
+		"with the number of failed sends and the last error. Metrics of failed sends aren't affected")
+	maxActiveAlerts = flag.Int("rule.maxActiveAlerts", 0, "The max number of active (pending and firing) alerts across all alerting rules. "+
+		"If the number is reached, creation of new alerts is suppressed while already active alerts remain unaffected. "+
+		"This protects the notifiers from alerts flood caused by unexpected labels explosion. See also group's limit param. "+
+		"By default, the number of active alerts is unlimited")
+	ruleUpdateEntriesLimit = flag.Int("rule.updateEntriesLimit", 20, "Defines the max number of rule's state updates stored in-memory. "+
+		"Rule's updates are available on rule's Details page and are used for debugging purposes. The number of stored updates can be overridden per rule via update_entries_limit param. "+
+		"Setting it to 0 disables the history")
 
 	validateTemplates   = flag.Bool("rule.validateTemplates", true, "Whether to validate annotation and label templates")
 	validateExpressions = flag.Bool("rule.validateExpressions", true, "Whether to validate rules expressions via MetricsQL engine")
 	externalURL         = flag.String("external.url", "", "External URL is used as alert's source for sent alerts to the notifier")
 	externalAlertSource = flag.String("external.alert.source", "", `External Alert Source allows to override the Source link for alerts sent to AlertManager for cases where you want to build a custom link to Grafana, Prometheus or any other service.
-eg. 'explore?orgId=1&left=[\"now-1h\",\"now\",\"VictoriaMetrics\",{\"expr\": \"{{$expr|quotesEscape|crlfEscape|queryEscape}}\"},{\"mode\":\"Metrics\"},{\"ui\":[true,true,true,\"none\"]}]'.If empty '/api/v1/:groupID/alertID/status' is used`)
+eg. 'explore?orgId=1&left=[\"now-1h\",\"now\",\"VictoriaMetrics\",{\"expr\": \"{{$expr|quotesEscape|crlfEscape|queryEscape}}\"},{\"mode\":\"Metrics\"},{\"ui\":[true,true,true,\"none\"]}]'.
+The template has access to $expr, $labels, $value, $groupID and $alertID variables. The result is appended to -external.url. If empty '/api/v1/:groupID/:alertID/status' is used`)
+	ruleDefaultAnnotations = flagutil.NewArray("rule.defaultAnnotation", "Optional annotation in the form 'name=template' to add to all alerting rules. "+
+		"The template may refer to the same variables as rule annotations, e.g. $labels. Group's and rule's annotations with the same name have priority. "+
+		"Pass multiple -rule.defaultAnnotation flags in order to add multiple annotations")
 	externalLabels = flagutil.NewArray("external.label", "Optional label in the form 'name=value' to add to all generated recording rules and alerts. "+
 		"Pass multiple -label flags in order to add multiple label sets.")
 
@@ -54,7 +116,9 @@ eg. 'explore?orgId=1&left=[\"now-1h\",\"now\",\"VictoriaMetrics\",{\"expr\": \"{
 
 	disableAlertGroupLabel = flag.Bool("disableAlertgroupLabel", false, "Whether to disable adding group's name as label to generated alerts and time series.")
 
-	dryRun = flag.Bool("dryRun", false, "Whether to check only config files without running vmalert. The rules file are validated. The `-rule` flag must be specified.")
+	dryRun            = flag.Bool("dryRun", false, "Whether to check only config files without running vmalert. The rules file are validated. The `-rule` flag must be specified.")
+	dryRunPrintConfig = flag.Bool("dryRun.printConfig", false, "Whether to print the effective configuration of groups and rules in YAML format in -dryRun mode. "+
+		"The effective configuration contains rules with applied group labels, external labels and default annotations")
 )
 
 func main() {
@@ -63,11 +127,47 @@ func main() {
 	flag.Usage = usage
 	envflag.Parse()
 	buildinfo.Init()
+	notifier.InitSecretFlags()
 	logger.Init()
 
+	if *defaultTenant != "" {
+		if err := config.ValidateTenant(*defaultTenant); err != nil {
+			logger.Fatalf("invalid -defaultTenant: %s", err)
+		}
+	}
+	if *healthErrorsThreshold < 1 {
+		logger.Fatalf("-rule.healthErrorsThreshold must be at least 1; got %d", *healthErrorsThreshold)
+	}
+	if *resendResolvedCount < 1 {
+		logger.Fatalf("-rule.resendResolvedCount must be at least 1; got %d", *resendResolvedCount)
+	}
+	var err error
+	rulesFromStdin, err = config.IsStdin(*rulePath)
+	if err != nil {
+		logger.Fatalf("invalid -rule: %s", err)
+	}
+	defaultAnnotations, err = parseDefaultAnnotations(*ruleDefaultAnnotations)
+	if err != nil {
+		logger.Fatalf("invalid -rule.defaultAnnotation: %s", err)
+	}
+	rulesFilter, err = newGroupsFilter(*ruleGroupFilter, *ruleNameFilter)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+
+	if len(*unitTestFiles) > 0 {
+		if !unitTest(*unitTestFiles) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *dryRun {
 		u, _ := url.Parse("https://victoriametrics.com/")
 		notifier.InitTemplateFunc(u)
+		if err := notifier.ValidateTemplates(defaultAnnotations); err != nil {
+			logger.Fatalf("invalid -rule.defaultAnnotation: %s", err)
+		}
 		groups, err := config.Parse(*rulePath, true, true)
 		if err != nil {
 			logger.Fatalf("failed to parse %q: %s", *rulePath, err)
@@ -75,6 +175,16 @@ func main() {
 		if len(groups) == 0 {
 			logger.Fatalf("No rules for validation. Please specify path to file(s) with alerting and/or recording rules using `-rule` flag")
 		}
+		if err := validateEvalOffset(groups, *evaluationInterval); err != nil {
+			logger.Fatalf("failed to validate %q: %s", *rulePath, err)
+		}
+		if *dryRunPrintConfig {
+			b, err := dryRunEffectiveConfig(groups)
+			if err != nil {
+				logger.Fatalf("cannot print effective config: %s", err)
+			}
+			fmt.Printf("%s", b)
+		}
 		return
 	}
 	if *replayFrom != "" || *replayTo != "" {
@@ -91,6 +201,7 @@ func main() {
 		if err != nil {
 			logger.Fatalf("cannot parse configuration file: %s", err)
 		}
+		groupsCfg = filterGroups(groupsCfg)
 		// prevent queries from caching and boundaries aligning
 		// when querying VictoriaMetrics datasource.
 		noCache := datasource.Param{Key: "nocache", Value: "1"}
@@ -109,20 +220,49 @@ func main() {
 	if err != nil {
 		logger.Fatalf("failed to init: %s", err)
 	}
+	// the heartbeat is re-sent every interval, so it bypasses
+	// the send queue and retries of manager.notifiers
+	hb, err := newHeartbeat(notifier.GetDirectNotifiers())
+	if err != nil {
+		logger.Fatalf("failed to init heartbeat: %s", err)
+	}
+	if hb != nil {
+		// the heartbeat is started before waiting for the datasource,
+		// since it must be sent even if the datasource is unavailable
+		go hb.run(ctx)
+	}
+	if isFlagSet("datasource.waitForBackend") {
+		if *waitForBackend < 0 {
+			logger.Fatalf("-datasource.waitForBackend cannot be negative; got %s", *waitForBackend)
+		}
+		if err := waitForDatasource(ctx, manager.querierBuilder, *waitForBackend); err != nil {
+			logger.Fatalf("%s", err)
+		}
+		logger.Infof("datasource is available")
+	}
 
 	logger.Infof("reading rules configuration file from %q", strings.Join(*rulePath, ";"))
-	groupsCfg, err := config.Parse(*rulePath, *validateTemplates, *validateExpressions)
+	groupsCfg, err := config.ParseNonEmpty(*rulePath, *validateTemplates, *validateExpressions)
+	if errors.Is(err, config.ErrNoFiles) {
+		if !*ruleAllowEmpty {
+			logger.Fatalf("%s; pass -rule.allowEmpty in order to start without rules", err)
+		}
+		logger.Warnf("%s; starting without rules since -rule.allowEmpty is set", err)
+		err = nil
+	}
 	if err != nil {
 		logger.Fatalf("cannot parse configuration file: %s", err)
 	}
+	groupsCfg = filterGroups(groupsCfg)
 
 	if err := manager.start(ctx, groupsCfg); err != nil {
 		logger.Fatalf("failed to start: %s", err)
 	}
 
-	go configReload(ctx, manager, groupsCfg)
+	reloadCh := make(chan chan error)
+	go configReload(ctx, manager, groupsCfg, reloadCh)
 
-	rh := &requestHandler{m: manager}
+	rh := &requestHandler{m: manager, reloadCh: reloadCh}
 	go httpserver.Serve(*httpListenAddr, rh.handler)
 
 	sig := procutil.WaitForSigterm()
@@ -139,8 +279,25 @@ var (
 	configReloadErrors = metrics.NewCounter(`vmalert_config_last_reload_errors_total`)
 	configSuccess      = metrics.NewCounter(`vmalert_config_last_reload_successful`)
 	configTimestamp    = metrics.NewCounter(`vmalert_config_last_reload_success_timestamp_seconds`)
+	configHashValue    = metrics.NewCounter(`vmalert_config_hash`)
 )
 
+// configStatus contains the state of rules configuration
+// displayed on the WEB UI
+type configStatus struct {
+	LastReloadSuccessful bool
+	LastSuccess          time.Time
+	Hash                 string
+}
+
+func getConfigStatus() configStatus {
+	return configStatus{
+		LastReloadSuccessful: configSuccess.Get() == 1,
+		LastSuccess:          time.Unix(int64(configTimestamp.Get()), 0),
+		Hash:                 strconv.FormatUint(configHashValue.Get(), 10),
+	}
+}
+
 func newManager(ctx context.Context) (*manager, error) {
 	q, err := datasource.Init(nil)
 	if err != nil {
@@ -151,20 +308,26 @@ func newManager(ctx context.Context) (*manager, error) {
 		return nil, fmt.Errorf("failed to init `external.url`: %w", err)
 	}
 	notifier.InitTemplateFunc(eu)
+	if *validateTemplates {
+		if err := notifier.ValidateTemplates(defaultAnnotations); err != nil {
+			return nil, fmt.Errorf("invalid `-rule.defaultAnnotation`: %w", err)
+		}
+	}
 	aug, err := getAlertURLGenerator(eu, *externalAlertSource, *validateTemplates)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init `external.alert.source`: %w", err)
 	}
-	nts, err := notifier.Init(aug)
+	nts, err := notifier.Init(ctx, aug)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init notifier: %w", err)
 	}
 
 	manager := &manager{
-		groups:         make(map[uint64]*Group),
-		querierBuilder: q,
-		notifiers:      nts,
-		labels:         map[string]string{},
+		groups:           make(map[uint64]*Group),
+		querierBuilder:   q,
+		notifiers:        nts,
+		requireNotifiers: true,
+		labels:           map[string]string{},
 	}
 	rw, err := remotewrite.Init(ctx)
 	if err != nil {
@@ -178,7 +341,17 @@ func newManager(ctx context.Context) (*manager, error) {
 	}
 	manager.rr = rr
 
-	for _, s := range *externalLabels {
+	manager.labels, err = parseExternalLabels(*externalLabels)
+	if err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// parseExternalLabels parses labels in the form `name=value`
+func parseExternalLabels(ss []string) (map[string]string, error) {
+	labels := make(map[string]string, len(ss))
+	for _, s := range ss {
 		if len(s) == 0 {
 			continue
 		}
@@ -186,28 +359,91 @@ func newManager(ctx context.Context) (*manager, error) {
 		if n < 0 {
 			return nil, fmt.Errorf("missing '=' in `-label`. It must contain label in the form `name=value`; got %q", s)
 		}
-		manager.labels[s[:n]] = s[n+1:]
+		labels[s[:n]] = s[n+1:]
 	}
-	return manager, nil
+	return labels, nil
+}
+
+// dryRunEffectiveConfig returns effective configuration of the given groups
+// without connecting to the datasource.
+func dryRunEffectiveConfig(groupsCfg []config.Group) ([]byte, error) {
+	labels, err := parseExternalLabels(*externalLabels)
+	if err != nil {
+		return nil, err
+	}
+	groupsCfg, _, _ = rulesFilter.apply(groupsCfg)
+	// querier is never used, since rules aren't evaluated
+	qb := datasource.NewVMStorage("", "", "", 0, 0, false, nil)
+	groups := make([]*Group, 0, len(groupsCfg))
+	for _, cfg := range groupsCfg {
+		groups = append(groups, newGroup(cfg, qb, *evaluationInterval, labels))
+	}
+	return marshalEffectiveConfig(groups)
+}
+
+// defaultAnnotations contains annotations set via -rule.defaultAnnotation flag
+var defaultAnnotations map[string]string
+
+// parseDefaultAnnotations parses annotations in the form `name=template`
+func parseDefaultAnnotations(ss []string) (map[string]string, error) {
+	m := make(map[string]string, len(ss))
+	for _, s := range ss {
+		if len(s) == 0 {
+			continue
+		}
+		n := strings.IndexByte(s, '=')
+		if n <= 0 {
+			return nil, fmt.Errorf("annotation must be in the form `name=template`; got %q", s)
+		}
+		m[s[:n]] = s[n+1:]
+	}
+	return m, nil
 }
 
 func getExternalURL(externalURL, httpListenAddr string, isSecure bool) (*url.URL, error) {
 	if externalURL != "" {
-		return url.Parse(externalURL)
+		u, err := url.Parse(externalURL)
+		if err != nil {
+			return nil, err
+		}
+		// trim trailing slash, so links built from the URL
+		// don't contain double slashes after the path prefix
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		return u, nil
 	}
 	hname, err := os.Hostname()
 	if err != nil {
-		return nil, err
+		logger.Warnf("cannot obtain hostname for `external.url`, the listen address is used instead: %s", err)
 	}
-	port := ""
-	if ipport := strings.Split(httpListenAddr, ":"); len(ipport) > 1 {
-		port = ":" + ipport[1]
+	return buildExternalURL(hname, httpListenAddr, isSecure), nil
+}
+
+// buildExternalURL builds the URL from the given hostname and the port of httpListenAddr.
+// The host of httpListenAddr is used if hostname is empty.
+func buildExternalURL(hname, httpListenAddr string, isSecure bool) *url.URL {
+	host, port, err := net.SplitHostPort(httpListenAddr)
+	if err != nil {
+		// httpListenAddr may contain no port
+		host, port = httpListenAddr, ""
 	}
-	schema := "http://"
+	// prefer hostname over the listen address, since
+	// the latter is usually unspecified, e.g. `:8880` or `0.0.0.0:8880`
+	if hname != "" {
+		host = hname
+	} else if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	if port != "" {
+		// JoinHostPort wraps IPv6 addresses into square brackets
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	schema := "http"
 	if isSecure {
-		schema = "https://"
+		schema = "https"
 	}
-	return url.Parse(fmt.Sprintf("%s%s%s", schema, hname, port))
+	return &url.URL{Scheme: schema, Host: host}
 }
 
 func getAlertURLGenerator(externalURL *url.URL, externalAlertSource string, validateTemplate bool) (notifier.AlertURLGenerator, error) {
@@ -244,14 +480,19 @@ See the docs at https://docs.victoriametrics.com/vmalert.html .
 	flagutil.Usage(s)
 }
 
-func configReload(ctx context.Context, m *manager, groupsCfg []config.Group) {
+// configReload re-reads rules configuration on SIGHUP signal, on every -rule.configCheckInterval
+// and on every request received via reloadCh. The result of reload is sent back to the request channel.
+// Reloads are processed one at a time, so simultaneous requests can't race on manager's groups.
+func configReload(ctx context.Context, m *manager, groupsCfg []config.Group, reloadCh <-chan chan error) {
 	// Register SIGHUP handler for config re-read just before manager.start call.
 	// This guarantees that the config will be re-read if the signal arrives during manager.start call.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1240
 	sighupCh := procutil.NewSighupChan()
 
 	var configCheckCh <-chan time.Time
-	if *rulesCheckInterval > 0 {
+	if *rulesCheckInterval > 0 && rulesFromStdin {
+		logger.Warnf("-rule.configCheckInterval is ignored, since rules are read from stdin")
+	} else if *rulesCheckInterval > 0 {
 		ticker := time.NewTicker(*rulesCheckInterval)
 		configCheckCh = ticker.C
 		defer ticker.Stop()
@@ -260,40 +501,121 @@ func configReload(ctx context.Context, m *manager, groupsCfg []config.Group) {
 	// init reload metrics with positive values to improve alerting conditions
 	configSuccess.Set(1)
 	configTimestamp.Set(fasttime.UnixTimestamp())
+	configHashValue.Set(uint64(configHash(groupsCfg)))
 	for {
+		var respCh chan error
 		select {
 		case <-ctx.Done():
 			return
 		case <-sighupCh:
 			logger.Infof("SIGHUP received. Going to reload rules %q ...", *rulePath)
 			configReloads.Inc()
+		case respCh = <-reloadCh:
+			logger.Infof("api config reload was called. Going to reload rules %q ...", *rulePath)
+			configReloads.Inc()
 		case <-configCheckCh:
 		}
-		newGroupsCfg, err := config.Parse(*rulePath, *validateTemplates, *validateExpressions)
+		newGroupsCfg, err := reloadRules(ctx, m, groupsCfg)
+		if err == nil {
+			groupsCfg = newGroupsCfg
+		}
+		// notifiers are reloaded regardless of rules,
+		// so an error in one of configs doesn't block updates of another
+		if nErr := notifier.Reload(); nErr != nil {
+			if err == nil {
+				err = nErr
+			} else {
+				err = fmt.Errorf("%s; %w", err, nErr)
+			}
+		}
+		if respCh != nil {
+			respCh <- err
+		}
 		if err != nil {
 			configReloadErrors.Inc()
 			configSuccess.Set(0)
-			logger.Errorf("cannot parse configuration file: %s", err)
-			continue
+			logger.Errorf("%s", err)
 		}
-		if configsEqual(newGroupsCfg, groupsCfg) {
-			// set success to 1 since previous reload
-			// could have been unsuccessful
+	}
+}
+
+// rulesFromStdin is set if -rule refers to stdin
+var rulesFromStdin bool
+
+// reloadRules parses -rule files and applies them to m
+// if they differ from groupsCfg. It returns the applied configuration.
+func reloadRules(ctx context.Context, m *manager, groupsCfg []config.Group) ([]config.Group, error) {
+	if rulesFromStdin {
+		// stdin can be read only once
+		logger.Infof("skipping rules reload, since rules were read from stdin on start; restart vmalert in order to apply new rules")
+		return groupsCfg, nil
+	}
+	// patterns are expanded on every reload to pick up added and deleted files
+	newGroupsCfg, err := config.ParseNonEmpty(*rulePath, *validateTemplates, *validateExpressions)
+	if errors.Is(err, config.ErrNoFiles) {
+		if *ruleAllowEmpty && len(groupsCfg) == 0 {
+			// files haven't appeared yet - nothing to load
 			configSuccess.Set(1)
-			// config didn't change - skip it
-			continue
+			return groupsCfg, nil
 		}
-		groupsCfg = newGroupsCfg
-		if err := m.update(ctx, groupsCfg, false); err != nil {
-			configReloadErrors.Inc()
-			configSuccess.Set(0)
-			logger.Errorf("error while reloading rules: %s", err)
+		// prevent from unloading all the groups because of
+		// temporarily missing files or a typo in patterns
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse configuration file: %w", err)
+	}
+	// log skipped groups only if config changes, since it is checked periodically
+	newGroupsCfg, skippedGroups, skippedRules := rulesFilter.apply(newGroupsCfg)
+	if configsEqual(newGroupsCfg, groupsCfg) {
+		// set success to 1 since previous reload
+		// could have been unsuccessful
+		configSuccess.Set(1)
+		// config didn't change - skip it
+		return groupsCfg, nil
+	}
+	if err := m.update(ctx, newGroupsCfg, false); err != nil {
+		return nil, fmt.Errorf("error while reloading rules: %w", err)
+	}
+	logSkippedGroups(len(newGroupsCfg), skippedGroups, skippedRules)
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+	configHashValue.Set(uint64(configHash(newGroupsCfg)))
+	logger.Infof("Rules reloaded successfully from %q; loaded files: %s",
+		*rulePath, strings.Join(groupsFiles(newGroupsCfg), ", "))
+	return newGroupsCfg, nil
+}
+
+// configHash returns the hash of the given groups configuration.
+// It doesn't depend on files location and order, so it may be used
+// for comparing configurations of vmalert replicas.
+func configHash(groups []config.Group) uint32 {
+	checksums := make([]string, len(groups))
+	for i, g := range groups {
+		checksums[i] = g.Checksum
+	}
+	sort.Strings(checksums)
+	h := fnv.New32a()
+	for _, c := range checksums {
+		h.Write([]byte(c))
+	}
+	return h.Sum32()
+}
+
+// groupsFiles returns sorted list of unique files
+// the given groups were loaded from
+func groupsFiles(groups []config.Group) []string {
+	m := make(map[string]struct{})
+	var files []string
+	for _, g := range groups {
+		if _, ok := m[g.File]; ok {
 			continue
 		}
-		configSuccess.Set(1)
-		configTimestamp.Set(fasttime.UnixTimestamp())
-		logger.Infof("Rules reloaded successfully from %q", *rulePath)
+		m[g.File] = struct{}{}
+		files = append(files, g.File)
 	}
+	sort.Strings(files)
+	return files
 }
 
 func configsEqual(a, b []config.Group) bool {
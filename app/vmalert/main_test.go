@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
 )
@@ -31,6 +33,32 @@ func TestGetExternalURL(t *testing.T) {
 	if u.String() != expURL {
 		t.Errorf("unexpected url want %s, got %s", expURL, u.String())
 	}
+	u, err = getExternalURL("https://victoriametrics.com/vmalert/", "", false)
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	if exp := "https://victoriametrics.com/vmalert"; u.String() != exp {
+		t.Errorf("unexpected url want %s, got %s", exp, u.String())
+	}
+}
+
+func TestBuildExternalURL(t *testing.T) {
+	f := func(hname, httpListenAddr string, isSecure bool, exp string) {
+		t.Helper()
+		if got := buildExternalURL(hname, httpListenAddr, isSecure).String(); got != exp {
+			t.Errorf("unexpected url for hostname %q and listen addr %q; want %s, got %s", hname, httpListenAddr, exp, got)
+		}
+	}
+	f("vmalert-0", ":8880", false, "http://vmalert-0:8880")
+	f("vmalert-0", "[::]:8880", true, "https://vmalert-0:8880")
+	f("vmalert-0", "", false, "http://vmalert-0")
+	f("", ":8880", false, "http://localhost:8880")
+	f("", "0.0.0.0:8880", false, "http://localhost:8880")
+	f("", "[::]:8880", false, "http://localhost:8880")
+	f("", "[::1]:8880", false, "http://[::1]:8880")
+	f("", "[fe80::1]:8880", true, "https://[fe80::1]:8880")
+	f("", "127.0.0.1:8880", false, "http://127.0.0.1:8880")
+	f("", "::1", false, "http://[::1]")
 }
 
 func TestGetAlertURLGenerator(t *testing.T) {
@@ -54,6 +82,16 @@ func TestGetAlertURLGenerator(t *testing.T) {
 	if exp := "https://victoriametrics.com/path/foo?query=4"; exp != fn(testAlert) {
 		t.Errorf("unexpected url want %s, got %s", exp, fn(testAlert))
 	}
+	testAlert.Expr = `up{job="foo"}`
+	testAlert.Labels = map[string]string{"instance": "bar"}
+	fn, err = getAlertURLGenerator(u, `explore?left={"queries":[{"expr":{{$expr|jsonEscape|queryEscape}}}]}&instance={{$labels.instance}}&id={{$groupID}}-{{$alertID}}`, true)
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	exp := `https://victoriametrics.com/path/explore?left={"queries":[{"expr":%22up%7Bjob%3D%5C%22foo%5C%22%7D%22}]}&instance=bar&id=42-2`
+	if exp != fn(testAlert) {
+		t.Errorf("unexpected url want %s, got %s", exp, fn(testAlert))
+	}
 }
 
 func TestConfigReload(t *testing.T) {
@@ -101,7 +139,7 @@ groups:
 		groups:         make(map[uint64]*Group),
 		labels:         map[string]string{},
 	}
-	go configReload(ctx, m, nil)
+	go configReload(ctx, m, nil, nil)
 
 	lenLocked := func(m *manager) int {
 		m.groupsMu.RLock()
@@ -138,6 +176,93 @@ groups:
 	if groupsLen != 1 { // should remain unchanged
 		t.Fatalf("expected to have exactly 1 group loaded; got %d", groupsLen)
 	}
+
+	if err := os.Remove(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	procutil.SelfSIGHUP()
+	time.Sleep(*rulesCheckInterval / 2)
+	groupsLen = lenLocked(m)
+	if groupsLen != 1 { // should remain unchanged since no files were found
+		t.Fatalf("expected to have exactly 1 group loaded; got %d", groupsLen)
+	}
+}
+
+func TestReloadRulesAllowEmpty(t *testing.T) {
+	originalRulePath := *rulePath
+	defer func() {
+		*rulePath = originalRulePath
+		*ruleAllowEmpty = false
+	}()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	*rulePath = []string{dir + "/*.yaml"}
+
+	m := &manager{
+		querierBuilder: &fakeQuerier{},
+		groups:         make(map[uint64]*Group),
+		labels:         map[string]string{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		m.close()
+	}()
+
+	if _, err := reloadRules(ctx, m, nil); err == nil {
+		t.Fatalf("expected to get error when no files found")
+	}
+
+	*ruleAllowEmpty = true
+	groupsCfg, err := reloadRules(ctx, m, nil)
+	if err != nil {
+		t.Fatalf("unexpected error with -rule.allowEmpty: %s", err)
+	}
+	if len(groupsCfg) != 0 {
+		t.Fatalf("expected to have no groups; got %d", len(groupsCfg))
+	}
+
+	writeToFile(t, dir+"/rules.yaml", `
+groups:
+  - name: group
+    rules:
+      - record: foo
+        expr: up
+`)
+	groupsCfg, err = reloadRules(ctx, m, groupsCfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(groupsCfg) != 1 {
+		t.Fatalf("expected to have 1 group; got %d", len(groupsCfg))
+	}
+
+	// loaded groups must remain active if files disappear
+	if err := os.Remove(dir + "/rules.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloadRules(ctx, m, groupsCfg); err == nil {
+		t.Fatalf("expected to get error when no files found")
+	}
+}
+
+func TestReloadRulesFromStdin(t *testing.T) {
+	defer func() { rulesFromStdin = false }()
+	rulesFromStdin = true
+
+	m := &manager{groups: make(map[uint64]*Group)}
+	groupsCfg := []config.Group{{Name: "group"}}
+	got, err := reloadRules(context.Background(), m, groupsCfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(got, groupsCfg) {
+		t.Fatalf("expected reload to be a no-op; got %v", got)
+	}
 }
 
 func writeToFile(t *testing.T, file, b string) {
@@ -147,3 +272,41 @@ func writeToFile(t *testing.T, file, b string) {
 		t.Fatal(err)
 	}
 }
+
+func TestParseDefaultAnnotations(t *testing.T) {
+	got, err := parseDefaultAnnotations([]string{"runbook_url=https://runbooks/{{ $labels.alertname }}", "", "dashboard=a=b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exp := map[string]string{
+		"runbook_url": "https://runbooks/{{ $labels.alertname }}",
+		"dashboard":   "a=b",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected to get %v; got %v", exp, got)
+	}
+	for _, s := range []string{"foo", "=bar"} {
+		if _, err := parseDefaultAnnotations([]string{s}); err == nil {
+			t.Fatalf("expected to get error for %q", s)
+		}
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	f := func(paths ...string) uint32 {
+		t.Helper()
+		groups, err := config.Parse(paths, true, true)
+		if err != nil {
+			t.Fatalf("failed to parse rules: %s", err)
+		}
+		return configHash(groups)
+	}
+	h1 := f("config/testdata/rules1-good.rules", "config/testdata/rules2-good.rules")
+	h2 := f("config/testdata/rules2-good.rules", "config/testdata/rules1-good.rules")
+	if h1 != h2 {
+		t.Fatalf("expected hash to not depend on files order; got %d and %d", h1, h2)
+	}
+	if h3 := f("config/testdata/rules1-good.rules"); h1 == h3 {
+		t.Fatalf("expected hash to change with configuration")
+	}
+}
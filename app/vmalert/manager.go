@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/remotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 )
 
@@ -16,8 +18,14 @@ import (
 type manager struct {
 	querierBuilder datasource.QuerierBuilder
 	notifiers      []notifier.Notifier
+	// requireNotifiers rejects groups with alerting rules
+	// if no notifiers are configured
+	requireNotifiers bool
 
 	rw *remotewrite.Client
+	// remote write clients for groups with tenant
+	rwTenantsMu sync.Mutex
+	rwTenants   map[string]*remotewrite.Client
 	// remote read builder.
 	rr datasource.QuerierBuilder
 
@@ -49,6 +57,29 @@ func (m *manager) AlertAPI(gID, aID uint64) (*APIAlert, error) {
 	return nil, fmt.Errorf("can't find alert with id %q in group %q", aID, g.Name)
 }
 
+// RuleAPI generates APIRuleDetails object from rule by its ID(hash)
+func (m *manager) RuleAPI(gID, rID uint64) (APIRuleDetails, error) {
+	m.groupsMu.RLock()
+	defer m.groupsMu.RUnlock()
+
+	g, ok := m.groups[gID]
+	if !ok {
+		return APIRuleDetails{}, fmt.Errorf("can't find group with id %q", gID)
+	}
+	for _, rule := range g.Rules {
+		if rule.ID() != rID {
+			continue
+		}
+		switch r := rule.(type) {
+		case *AlertingRule:
+			return r.RuleDetailsAPI(), nil
+		case *RecordingRule:
+			return r.RuleDetailsAPI(), nil
+		}
+	}
+	return APIRuleDetails{}, fmt.Errorf("can't find rule with id %q in group %q", rID, g.Name)
+}
+
 func (m *manager) start(ctx context.Context, groupsCfg []config.Group) error {
 	return m.update(ctx, groupsCfg, true)
 }
@@ -60,9 +91,59 @@ func (m *manager) close() {
 			logger.Fatalf("cannot stop the remotewrite: %s", err)
 		}
 	}
+	m.rwTenantsMu.Lock()
+	for tenant, rw := range m.rwTenants {
+		if err := rw.Close(); err != nil {
+			logger.Fatalf("cannot stop the remotewrite for tenant %q: %s", tenant, err)
+		}
+	}
+	m.rwTenantsMu.Unlock()
 	m.wg.Wait()
 }
 
+// remoteWrite returns remote write client for the given tenant.
+// Clients for tenants are created on the first request.
+func (m *manager) remoteWrite(ctx context.Context, tenant string) (*remotewrite.Client, error) {
+	if tenant == "" || m.rw == nil {
+		return m.rw, nil
+	}
+	m.rwTenantsMu.Lock()
+	defer m.rwTenantsMu.Unlock()
+	if rw, ok := m.rwTenants[tenant]; ok {
+		return rw, nil
+	}
+	rw, err := remotewrite.InitWithTenant(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init remoteWrite for tenant %q: %w", tenant, err)
+	}
+	if m.rwTenants == nil {
+		m.rwTenants = make(map[string]*remotewrite.Client)
+	}
+	m.rwTenants[tenant] = rw
+	return rw, nil
+}
+
+// closeUnusedRemoteWrites closes remote write clients of tenants
+// which aren't used by groups anymore, so their buffered data is flushed
+// and resources are released. It must be called under m.groupsMu.
+func (m *manager) closeUnusedRemoteWrites() {
+	tenants := make(map[string]struct{})
+	for _, g := range m.groups {
+		tenants[g.Tenant] = struct{}{}
+	}
+	m.rwTenantsMu.Lock()
+	defer m.rwTenantsMu.Unlock()
+	for tenant, rw := range m.rwTenants {
+		if _, ok := tenants[tenant]; ok {
+			continue
+		}
+		if err := rw.Close(); err != nil {
+			logger.Errorf("cannot stop the remotewrite for tenant %q: %s", tenant, err)
+		}
+		delete(m.rwTenants, tenant)
+	}
+}
+
 func (m *manager) startGroup(ctx context.Context, group *Group, restore bool) error {
 	if restore && m.rr != nil {
 		err := group.Restore(ctx, m.rr, *remoteReadLookBack, m.labels)
@@ -74,17 +155,80 @@ func (m *manager) startGroup(ctx context.Context, group *Group, restore bool) er
 		}
 	}
 
+	rw, err := m.remoteWrite(ctx, group.Tenant)
+	if err != nil {
+		return err
+	}
+
 	m.wg.Add(1)
 	id := group.ID()
 	go func() {
-		group.start(ctx, m.notifiers, m.rw)
+		group.start(ctx, m.notifiers, rw)
 		m.wg.Done()
 	}()
 	m.groups[id] = group
 	return nil
 }
 
+// validateNotifiers checks whether notifiers referred
+// by groups are present in m.notifiers and whether
+// there are notifiers for groups with alerting rules
+func (m *manager) validateNotifiers(groupsCfg []config.Group) error {
+	names := make(map[string]struct{}, len(m.notifiers))
+	for _, nt := range m.notifiers {
+		if nt.Name() != "" {
+			names[nt.Name()] = struct{}{}
+		}
+	}
+	errGroup := new(utils.ErrGroup)
+	for _, cfg := range groupsCfg {
+		if m.requireNotifiers && len(m.notifiers) == 0 && hasAlertingRules(cfg) {
+			errGroup.Add(fmt.Errorf("group %q contains alerting rules, but no notifiers are configured; "+
+				"set `-notifier.url`, `-notifier.config` or `-notifier.blackhole` flag", cfg.Name))
+			continue
+		}
+		for _, name := range cfg.Notifiers {
+			if _, ok := names[name]; !ok {
+				errGroup.Add(fmt.Errorf("group %q: unknown notifier %q; notifiers must be named via `-notifier.name` flag", cfg.Name, name))
+			}
+		}
+	}
+	return errGroup.Err()
+}
+
+// validateEvalOffset checks whether eval_offset of groups is less than
+// their effective interval. Groups without interval are evaluated
+// with defaultInterval, so they can't be checked during config parsing.
+func validateEvalOffset(groupsCfg []config.Group, defaultInterval time.Duration) error {
+	errGroup := new(utils.ErrGroup)
+	for _, cfg := range groupsCfg {
+		interval := cfg.Interval.Duration()
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+		if offset := cfg.EvalOffset.Duration(); offset > 0 && offset >= interval {
+			errGroup.Add(fmt.Errorf("group %q: eval_offset=%v must be less than interval=%v", cfg.Name, offset, interval))
+		}
+	}
+	return errGroup.Err()
+}
+
+func hasAlertingRules(cfg config.Group) bool {
+	for _, r := range cfg.Rules {
+		if r.Alert != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore bool) error {
+	if err := m.validateNotifiers(groupsCfg); err != nil {
+		return err
+	}
+	if err := validateEvalOffset(groupsCfg, *evaluationInterval); err != nil {
+		return err
+	}
 	groupsRegistry := make(map[uint64]*Group)
 	for _, cfg := range groupsCfg {
 		ng := newGroup(cfg, m.querierBuilder, *evaluationInterval, m.labels)
@@ -118,6 +262,7 @@ func (m *manager) update(ctx context.Context, groupsCfg []config.Group, restore
 			return err
 		}
 	}
+	m.closeUnusedRemoteWrites()
 	m.groupsMu.Unlock()
 
 	if len(toUpdate) > 0 {
@@ -146,9 +291,16 @@ func (g *Group) toAPI() APIGroup {
 		Type:              g.Type.String(),
 		File:              g.File,
 		Interval:          g.Interval.String(),
+		EvalOffset:        g.EvalOffset.String(),
+		EvalDelay:         g.EvalDelay.String(),
 		Concurrency:       g.Concurrency,
+		Limit:             g.Limit,
 		ExtraFilterLabels: g.ExtraFilterLabels,
 		Labels:            g.Labels,
+		Params:            g.Params,
+		Tenant:            g.Tenant,
+		Notifiers:         g.Notifiers,
+		DatasourceURL:     g.DatasourceURL,
 	}
 	for _, r := range g.Rules {
 		switch v := r.(type) {
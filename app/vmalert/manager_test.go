@@ -12,6 +12,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/remotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
 )
 
 func TestMain(m *testing.M) {
@@ -31,6 +33,68 @@ func TestManagerEmptyRulesDir(t *testing.T) {
 	}
 }
 
+func TestManagerValidateNotifiers(t *testing.T) {
+	m := &manager{notifiers: []notifier.Notifier{&fakeNotifier{name: "paging"}, &fakeNotifier{}}}
+	f := func(names []string, expErr bool) {
+		t.Helper()
+		cfg := []config.Group{{Name: "test", Notifiers: names}}
+		err := m.validateNotifiers(cfg)
+		if expErr && err == nil {
+			t.Fatalf("expected to get error for notifiers %q", names)
+		}
+		if !expErr && err != nil {
+			t.Fatalf("unexpected error for notifiers %q: %s", names, err)
+		}
+	}
+	f(nil, false)
+	f([]string{"paging"}, false)
+	f([]string{"tickets"}, true)
+	f([]string{"paging", "tickets"}, true)
+}
+
+func TestManagerValidateNotifiers_Required(t *testing.T) {
+	alerting := config.Group{Name: "alerting", Rules: []config.Rule{{Alert: "foo"}}}
+	recording := config.Group{Name: "recording", Rules: []config.Rule{{Record: "foo"}}}
+
+	m := &manager{requireNotifiers: true}
+	if err := m.validateNotifiers([]config.Group{recording}); err != nil {
+		t.Fatalf("unexpected error for recording rules without notifiers: %s", err)
+	}
+	if err := m.validateNotifiers([]config.Group{recording, alerting}); err == nil {
+		t.Fatalf("expected to get error for alerting rules without notifiers")
+	}
+	m.notifiers = []notifier.Notifier{&fakeNotifier{}}
+	if err := m.validateNotifiers([]config.Group{recording, alerting}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateEvalOffset(t *testing.T) {
+	rules := []config.Rule{{Record: "foo", Expr: "up"}}
+	f := func(interval, offset time.Duration, expErr bool) {
+		t.Helper()
+		cfg := config.Group{
+			Name:       "group",
+			Interval:   utils.NewPromDuration(interval),
+			EvalOffset: utils.NewPromDuration(offset),
+			Rules:      rules,
+		}
+		err := validateEvalOffset([]config.Group{cfg}, time.Minute)
+		if expErr && err == nil {
+			t.Fatalf("expected to get error for interval=%v and eval_offset=%v", interval, offset)
+		}
+		if !expErr && err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	f(0, 0, false)
+	f(5*time.Minute, 2*time.Minute, false)
+	// groups without interval are evaluated with the default interval
+	f(0, 30*time.Second, false)
+	f(0, 2*time.Minute, true)
+	f(0, time.Minute, true)
+}
+
 // TestManagerUpdateConcurrent supposed to test concurrent
 // execution of configuration update.
 // Should be executed with -race flag
@@ -79,6 +143,27 @@ func TestManagerUpdateConcurrent(t *testing.T) {
 
 // TestManagerUpdate tests sequential configuration
 // updates.
+func TestManagerCloseUnusedRemoteWrites(t *testing.T) {
+	newClient := func() *remotewrite.Client {
+		t.Helper()
+		rw, err := remotewrite.NewClient(context.Background(), remotewrite.Config{Addr: "http://localhost:8428"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return rw
+	}
+	g := &Group{Name: "group", Tenant: "1"}
+	m := &manager{
+		groups:    map[uint64]*Group{g.ID(): g},
+		rwTenants: map[string]*remotewrite.Client{"1": newClient(), "2": newClient()},
+	}
+	m.closeUnusedRemoteWrites()
+	if len(m.rwTenants) != 1 || m.rwTenants["1"] == nil {
+		t.Fatalf("expected to keep only the client of the used tenant; got %v", m.rwTenants)
+	}
+	_ = m.rwTenants["1"].Close()
+}
+
 func TestManagerUpdate(t *testing.T) {
 	const defaultEvalInterval = time.Second * 30
 	currentEvalInterval := *evaluationInterval
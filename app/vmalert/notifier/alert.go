@@ -25,6 +25,17 @@ type Alert struct {
 	End   time.Time
 	Value float64
 	ID    uint64
+	// KeepFiringSince is the moment of time when the firing alert
+	// stopped being returned by expression. Is zero if alert is
+	// still returned or if keep_firing_for isn't configured.
+	KeepFiringSince time.Time
+	// LastSent is the evaluation time when the firing alert
+	// was successfully sent to notifiers last time.
+	// Is zero if alert wasn't sent yet or has changed since then.
+	LastSent time.Time
+	// ResolvedSent is the number of times the resolved
+	// alert was successfully sent to notifiers.
+	ResolvedSent int
 }
 
 // AlertState type indicates the Alert state
@@ -57,16 +68,20 @@ type AlertTplData struct {
 	Labels map[string]string
 	Value  float64
 	Expr   string
+	// AlertID and GroupID are available in templates
+	// as $alertID and $groupID variables
+	AlertID uint64
+	GroupID uint64
 }
 
-const tplHeader = `{{ $value := .Value }}{{ $labels := .Labels }}{{ $expr := .Expr }}`
+const tplHeader = `{{ $value := .Value }}{{ $labels := .Labels }}{{ $expr := .Expr }}{{ $alertID := .AlertID }}{{ $groupID := .GroupID }}`
 
 // ExecTemplate executes the Alert template for given
 // map of annotations.
 // Every alert could have a different datasource, so function
 // requires a queryFunction as an argument.
 func (a *Alert) ExecTemplate(q QueryFn, annotations map[string]string) (map[string]string, error) {
-	tplData := AlertTplData{Value: a.Value, Labels: a.Labels, Expr: a.Expr}
+	tplData := AlertTplData{Value: a.Value, Labels: a.Labels, Expr: a.Expr, AlertID: a.ID, GroupID: a.GroupID}
 	return templateAnnotations(annotations, tplData, funcsWithQuery(q))
 }
 
@@ -62,6 +62,24 @@ func TestAlert_ExecTemplate(t *testing.T) {
 				"exprEscapedPath":  "vm_rows%7B%5C%22label%5C%22=%5C%22bar%5C%22%7D%3E0",
 			},
 		},
+		{
+			name: "json-escape-template",
+			alert: &Alert{
+				ID:      2,
+				GroupID: 42,
+				Expr:    `vm_rows{"label"="bar"}>0`,
+			},
+			annotations: map[string]string{
+				"exprJSON":       `{"expr":{{ $expr|jsonEscape }}}`,
+				"exprJSONEscape": `{"expr":{{ $expr|jsonEscape|queryEscape }}}`,
+				"ids":            "{{ $groupID }}/{{ $alertID }}",
+			},
+			expTpl: map[string]string{
+				"exprJSON":       `{"expr":"vm_rows{\"label\"=\"bar\"}\u003e0"}`,
+				"exprJSONEscape": `{"expr":%22vm_rows%7B%5C%22label%5C%22%3D%5C%22bar%5C%22%7D%5Cu003e0%22}`,
+				"ids":            "42/2",
+			},
+		},
 		{
 			name:  "query",
 			alert: &Alert{Expr: `vm_rows{"label"="bar"}>0`},
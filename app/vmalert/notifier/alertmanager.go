@@ -3,43 +3,82 @@ package notifier
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
 )
 
 // AlertManager represents integration provider with Prometheus alert manager
 // https://github.com/prometheus/alertmanager
 type AlertManager struct {
 	addr          string
-	alertURL      string
+	name          string
+	baseURL       string
 	basicAuthUser string
 	basicAuthPass string
+	bearerToken   *utils.BearerToken
+	oauth2Token   *utils.OAuth2Token
 	argFunc       AlertURLGenerator
 	client        *http.Client
+	// timeout limits the duration of a single Send call,
+	// including retries after 401 responses
+	timeout time.Duration
+	// headers are sent with every request
+	headers map[string]string
+	// signer signs request bodies if set
+	signer *signer
+
+	// authCfg contains auth and TLS settings from -notifier.config
+	authCfg *promauth.Config
+	// cfgKey identifies settings from -notifier.config the AlertManager
+	// is created with, so it is re-used on reload only if they aren't changed
+	cfgKey string
+
+	mu sync.Mutex
+	// alertURL is empty until API version is detected
+	// if API version is set to apiVersionAuto
+	alertURL string
 }
 
 // Addr returns address where alerts are sent.
-func (am AlertManager) Addr() string { return am.addr }
+func (am *AlertManager) Addr() string { return am.addr }
+
+// Name returns the optional name set via -notifier.name flag.
+func (am *AlertManager) Name() string { return am.name }
 
 // Send an alert or resolve message
 func (am *AlertManager) Send(ctx context.Context, alerts []Alert) error {
+	ctx, cancel := am.withTimeout(ctx)
+	defer cancel()
+	alertURL, err := am.getAlertURL(ctx)
+	if err != nil {
+		return err
+	}
 	b := &bytes.Buffer{}
 	writeamRequest(b, alerts, am.argFunc)
 
-	req, err := http.NewRequest("POST", am.alertURL, b)
+	resp, err := am.send(ctx, http.MethodPost, alertURL, b.Bytes())
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req = req.WithContext(ctx)
-	if am.basicAuthPass != "" {
-		req.SetBasicAuth(am.basicAuthUser, am.basicAuthPass)
-	}
-	resp, err := am.client.Do(req)
-	if err != nil {
-		return err
+	if resp.StatusCode == http.StatusUnauthorized && am.refreshToken() {
+		// the token may be revoked or rotated before
+		// the expiry, so retry once with a new token
+		_ = resp.Body.Close()
+		resp, err = am.send(ctx, http.MethodPost, alertURL, b.Bytes())
+		if err != nil {
+			return err
+		}
 	}
 
 	defer func() { _ = resp.Body.Close() }()
@@ -47,27 +86,242 @@ func (am *AlertManager) Send(ctx context.Context, alerts []Alert) error {
 	if resp.StatusCode != http.StatusOK {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("failed to read response from %q: %w", am.alertURL, err)
+			return fmt.Errorf("failed to read response from %q: %w", alertURL, err)
 		}
-		return fmt.Errorf("invalid SC %d from %q; response body: %s", resp.StatusCode, am.alertURL, string(body))
+		return fmt.Errorf("invalid SC %d from %q; response body: %s", resp.StatusCode, alertURL, parseErrorBody(body))
+	}
+	return nil
+}
+
+// parseErrorBody returns the error message from Alertmanager response body.
+// API v2 returns either JSON object with `message` field or JSON string.
+func parseErrorBody(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &e); err == nil && e.Message != "" {
+		return e.Message
+	}
+	var s string
+	if err := json.Unmarshal(body, &s); err == nil && s != "" {
+		return s
+	}
+	return string(body)
+}
+
+// getAlertURL returns the url for sending alerts. The API version
+// is detected on the first call if it is set to apiVersionAuto.
+// Failed detection is repeated on the next call.
+// The detection request is sent without holding am.mu,
+// so a slow Alertmanager doesn't block concurrent sends and withAddr calls.
+func (am *AlertManager) getAlertURL(ctx context.Context) (string, error) {
+	am.mu.Lock()
+	alertURL := am.alertURL
+	am.mu.Unlock()
+	if alertURL != "" {
+		return alertURL, nil
+	}
+	statusURL := am.baseURL + alertManagerStatusPath
+	resp, err := am.send(ctx, http.MethodGet, statusURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot detect API version of %q: %w", am.addr, err)
+	}
+	_ = resp.Body.Close()
+	version := apiVersionV2
+	switch resp.StatusCode {
+	case http.StatusOK:
+		alertURL = am.baseURL + alertManagerPath
+	case http.StatusNotFound:
+		// API v2 is missing in Alertmanager older than v0.16
+		version = apiVersionV1
+		alertURL = am.baseURL + alertManagerV1Path
+	default:
+		return "", fmt.Errorf("cannot detect API version of %q: unexpected response code %d from %q", am.addr, resp.StatusCode, statusURL)
+	}
+	am.mu.Lock()
+	if am.alertURL == "" {
+		// concurrent calls may detect the version simultaneously,
+		// so the result is logged only once
+		am.alertURL = alertURL
+		logger.Infof("detected Alertmanager API %s for %q", version, am.addr)
+	}
+	alertURL = am.alertURL
+	am.mu.Unlock()
+	return alertURL, nil
+}
+
+// setAPIVersion sets API version used for sending alerts
+func (am *AlertManager) setAPIVersion(v string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	switch v {
+	case "", apiVersionV2:
+		am.alertURL = am.baseURL + alertManagerPath
+	case apiVersionV1:
+		am.alertURL = am.baseURL + alertManagerV1Path
+	case apiVersionAuto:
+		am.alertURL = ""
+	default:
+		return fmt.Errorf("unsupported API version %q; supported values: %s, %s, %s", v, apiVersionAuto, apiVersionV1, apiVersionV2)
 	}
 	return nil
 }
 
+// withTimeout returns ctx limited by am.timeout
+func (am *AlertManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if am.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, am.timeout)
+}
+
+func (am *AlertManager) send(ctx context.Context, method, reqURL string, body []byte) (*http.Response, error) {
+	req, err := am.newRequest(ctx, method, reqURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	return am.client.Do(req)
+}
+
+// newRequest returns request with custom and auth headers set.
+// Auth headers have priority over the custom ones.
+// Non-empty body is signed if am.signer is set.
+func (am *AlertManager) newRequest(ctx context.Context, method, reqURL string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range am.headers {
+		req.Header.Set(k, v)
+	}
+	if am.signer != nil && body != nil {
+		am.signer.sign(req, body)
+	}
+	if am.basicAuthUser != "" || am.basicAuthPass != "" {
+		req.SetBasicAuth(am.basicAuthUser, am.basicAuthPass)
+	}
+	if am.bearerToken != nil {
+		req.Header.Set("Authorization", "Bearer "+am.bearerToken.Get())
+	}
+	if am.oauth2Token != nil {
+		token, err := am.oauth2Token.Get()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if am.authCfg != nil {
+		if ah := am.authCfg.GetAuthHeader(); ah != "" {
+			req.Header.Set("Authorization", ah)
+		}
+	}
+	return req, nil
+}
+
+// refreshToken obtains a new token after 401 response.
+// It returns true if the request must be retried with the new token.
+func (am *AlertManager) refreshToken() bool {
+	switch {
+	case am.oauth2Token != nil:
+		if err := am.oauth2Token.Reset(); err != nil {
+			logger.Errorf("cannot refresh OAuth2 token for %q: %s", am.addr, err)
+			return false
+		}
+		return true
+	case am.bearerToken != nil:
+		changed, err := am.bearerToken.Refresh()
+		if err != nil {
+			logger.Errorf("cannot refresh bearer token for %q: %s", am.addr, err)
+		}
+		return changed
+	default:
+		return false
+	}
+}
+
+const (
+	// dialTimeout is the max duration for establishing connections to notifiers
+	dialTimeout = 5 * time.Second
+	// tlsHandshakeTimeout is the max duration for TLS handshake with notifiers
+	tlsHandshakeTimeout = 5 * time.Second
+)
+
+// setTransportTimeouts limits the time for establishing connections via tr,
+// so unavailable notifiers are detected before the send timeout
+func setTransportTimeouts(tr *http.Transport) {
+	tr.DialContext = (&net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	tr.TLSHandshakeTimeout = tlsHandshakeTimeout
+}
+
 // AlertURLGenerator returns URL to single alert by given name
 type AlertURLGenerator func(Alert) string
 
-const alertManagerPath = "/api/v2/alerts"
+const (
+	alertManagerPath       = "/api/v2/alerts"
+	alertManagerV1Path     = "/api/v1/alerts"
+	alertManagerStatusPath = "/api/v2/status"
+)
+
+// Supported values for -notifier.apiVersion
+const (
+	apiVersionAuto = "auto"
+	apiVersionV1   = "v1"
+	apiVersionV2   = "v2"
+)
 
-// NewAlertManager is a constructor for AlertManager
+// NewAlertManager is a constructor for AlertManager.
+// Credentials embedded into alertManagerURL are used
+// if user and pass are empty.
 func NewAlertManager(alertManagerURL, user, pass string, fn AlertURLGenerator, c *http.Client) *AlertManager {
-	url := strings.TrimSuffix(alertManagerURL, "/") + alertManagerPath
+	addr := alertManagerURL
+	if u, err := url.Parse(alertManagerURL); err == nil && u.User != nil {
+		if user == "" && pass == "" {
+			user = u.User.Username()
+			pass, _ = u.User.Password()
+		}
+		// addr is used in logs and metrics, so it mustn't contain credentials
+		addr = u.Redacted()
+		u.User = nil
+		alertManagerURL = u.String()
+	}
+	baseURL := strings.TrimSuffix(alertManagerURL, "/")
 	return &AlertManager{
-		addr:          alertManagerURL,
-		alertURL:      url,
+		addr:          addr,
+		baseURL:       baseURL,
+		alertURL:      baseURL + alertManagerPath,
 		argFunc:       fn,
 		client:        c,
 		basicAuthUser: user,
 		basicAuthPass: pass,
 	}
 }
+
+// withAddr returns a copy of am which sends alerts to addr.
+// The copy shares client, credentials and tokens with am
+// and uses the same API version.
+func (am *AlertManager) withAddr(addr string) *AlertManager {
+	c := NewAlertManager(addr, am.basicAuthUser, am.basicAuthPass, am.argFunc, am.client)
+	c.name = am.name
+	c.bearerToken = am.bearerToken
+	c.oauth2Token = am.oauth2Token
+	c.authCfg = am.authCfg
+	c.cfgKey = am.cfgKey
+	c.timeout = am.timeout
+	c.headers = am.headers
+	c.signer = am.signer
+	am.mu.Lock()
+	if am.alertURL == "" {
+		c.alertURL = ""
+	} else {
+		c.alertURL = c.baseURL + strings.TrimPrefix(am.alertURL, am.baseURL)
+	}
+	am.mu.Unlock()
+	return c
+}
@@ -3,11 +3,19 @@ package notifier
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
 )
 
 func TestAlertManager_Addr(t *testing.T) {
@@ -18,6 +26,31 @@ func TestAlertManager_Addr(t *testing.T) {
 	}
 }
 
+func TestAlertManager_URLCredentials(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(alertManagerPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.User != nil {
+			t.Errorf("expected credentials to be removed from url")
+		}
+		user, pass, _ := r.BasicAuth()
+		if user != "foo" || pass != "bar" {
+			t.Errorf("wrong creds %q:%q; expected %q:%q", user, pass, "foo", "bar")
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	u.User = url.UserPassword("foo", "bar")
+	am := NewAlertManager(u.String(), "", "", func(Alert) string { return "" }, srv.Client())
+	if strings.Contains(am.Addr(), "bar") {
+		t.Errorf("expected password to be redacted in %q", am.Addr())
+	}
+	if err := am.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
 func TestAlertManager_Send(t *testing.T) {
 	const baUser, baPass = "foo", "bar"
 	mux := http.NewServeMux()
@@ -97,3 +130,225 @@ func TestAlertManager_Send(t *testing.T) {
 		t.Errorf("expected 2 calls(count from zero) to server got %d", c)
 	}
 }
+
+func TestAlertManager_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	am := NewAlertManager(srv.URL, "", "", func(Alert) string { return "" }, srv.Client())
+	am.timeout = 50 * time.Millisecond
+	start := time.Now()
+	err := am.Send(context.Background(), []Alert{{Name: "alert0"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to get deadline exceeded error; got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("expected Send to be interrupted after the timeout; took %s", d)
+	}
+}
+
+func TestAlertManager_Headers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get("X-Api-Key"); h != "foo" {
+			t.Errorf("unexpected X-Api-Key header %q", h)
+		}
+		// auth settings have priority over custom headers
+		if user, _, _ := r.BasicAuth(); user != "bar" {
+			t.Errorf("unexpected basic auth user %q", user)
+		}
+	}))
+	defer srv.Close()
+
+	am := NewAlertManager(srv.URL, "bar", "", func(Alert) string { return "" }, srv.Client())
+	var err error
+	am.headers, err = utils.ParseHeaders("X-Api-Key: foo^^Authorization: Bearer baz")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := am.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestAlertManager_BearerToken(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	writeToken := func(token string) {
+		t.Helper()
+		if err := ioutil.WriteFile(f.Name(), []byte(token), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeToken("foo")
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer bar" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	bt, err := utils.NewBearerToken("", f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	am := NewAlertManager(srv.URL, "", "", func(Alert) string { return "" }, srv.Client())
+	am.bearerToken = bt
+
+	// the token is rotated before the refresh interval,
+	// so it must be re-read on 401 response
+	writeToken("bar")
+	if err := am.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if requests != 2 {
+		t.Fatalf("expected to retry once with the new token; got %d requests", requests)
+	}
+
+	// static token can't be refreshed, so the request isn't retried
+	am.bearerToken, err = utils.NewBearerToken("foo", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	requests = 0
+	if err := am.Send(context.Background(), []Alert{{Name: "alert0"}}); err == nil {
+		t.Fatalf("expected to get error for unauthorized request")
+	}
+	if requests != 1 {
+		t.Fatalf("expected no retries for static token; got %d requests", requests)
+	}
+}
+
+func TestAlertManager_OAuth2Token(t *testing.T) {
+	var tokensIssued int
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokensIssued++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, tokensIssued)
+	}))
+	defer tokenSrv.Close()
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// the first token is revoked
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	ot, err := utils.NewOAuth2Token("client", "secret", "", tokenSrv.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	am := NewAlertManager(srv.URL, "", "", func(Alert) string { return "" }, srv.Client())
+	am.oauth2Token = ot
+	if err := am.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if requests != 2 || tokensIssued != 2 {
+		t.Fatalf("expected to retry once with a new token; got %d requests and %d issued tokens", requests, tokensIssued)
+	}
+}
+
+func TestAlertManager_APIVersion(t *testing.T) {
+	f := func(statusCode int, expPath string) {
+		t.Helper()
+		var probes, sent int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case alertManagerStatusPath:
+				probes++
+				w.WriteHeader(statusCode)
+			case expPath:
+				sent++
+			default:
+				t.Errorf("unexpected request to %q", r.URL.Path)
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer srv.Close()
+
+		am := NewAlertManager(srv.URL, "", "", func(Alert) string { return "" }, srv.Client())
+		if err := am.setAPIVersion(apiVersionAuto); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := am.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+		if probes != 1 || sent != 2 {
+			t.Fatalf("expected 1 probe and 2 sent requests; got %d and %d", probes, sent)
+		}
+	}
+	f(http.StatusOK, alertManagerPath)
+	f(http.StatusNotFound, alertManagerV1Path)
+
+	am := NewAlertManager("http://localhost", "", "", nil, nil)
+	if err := am.setAPIVersion("v3"); err == nil {
+		t.Fatalf("expected to get error for unsupported version")
+	}
+}
+
+func TestAlertManager_APIVersionProbeUnlocked(t *testing.T) {
+	probeStarted := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == alertManagerStatusPath {
+			probeStarted <- struct{}{}
+			<-release
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	am := NewAlertManager(srv.URL, "", "", func(Alert) string { return "" }, srv.Client())
+	if err := am.setAPIVersion(apiVersionAuto); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sendErr := make(chan error, 1)
+	go func() { sendErr <- am.Send(context.Background(), []Alert{{Name: "alert0"}}) }()
+	<-probeStarted
+
+	// the slow probe mustn't block copying am for discovered targets
+	copied := make(chan struct{})
+	go func() {
+		am.withAddr("http://localhost:9093")
+		close(copied)
+	}()
+	select {
+	case <-copied:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("withAddr is blocked by API version detection")
+	}
+	release <- struct{}{}
+	if err := <-sendErr; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestParseErrorBody(t *testing.T) {
+	f := func(body, exp string) {
+		t.Helper()
+		if got := parseErrorBody([]byte(body)); got != exp {
+			t.Fatalf("expected to get %q; got %q", exp, got)
+		}
+	}
+	f(`{"code":400,"message":"start time must be before end time"}`, "start time must be before end time")
+	f(`"invalid alerts"`, "invalid alerts")
+	f(`bad gateway`, "bad gateway")
+	f(``, "")
+}
@@ -0,0 +1,22 @@
+package notifier
+
+import "context"
+
+// blackholeAddr is the address of blackholeNotifier
+// used in logs and metrics
+const blackholeAddr = "blackhole"
+
+// blackholeNotifier discards the sent alerts without making any requests.
+// It is used via -notifier.blackhole flag for running vmalert
+// without notifiers, e.g. only for recording rules.
+type blackholeNotifier struct{}
+
+// Send discards alerts. The discarded alerts are accounted
+// in vmalert_alerts_sent_total{addr="blackhole"} metric by statsNotifier.
+func (bn *blackholeNotifier) Send(_ context.Context, _ []Alert) error { return nil }
+
+// Addr returns "blackhole".
+func (bn *blackholeNotifier) Addr() string { return blackholeAddr }
+
+// Name returns empty string, since blackhole notifier can't be named.
+func (bn *blackholeNotifier) Name() string { return "" }
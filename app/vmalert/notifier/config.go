@@ -0,0 +1,482 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/consul"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultTimeout is the default timeout for requests
+// to targets discovered via -notifier.config
+const defaultTimeout = 10 * time.Second
+
+// Config contains the list of sources for discovering notifier targets.
+// It is loaded from -notifier.config file.
+type Config struct {
+	// Scheme is the scheme for the discovered targets.
+	// It may be overridden via `__scheme__` label during relabeling.
+	Scheme string `yaml:"scheme,omitempty"`
+	// PathPrefix is added to the path of the discovered targets
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+	// APIVersion is the Alertmanager API version,
+	// see -notifier.apiVersion for details
+	APIVersion string `yaml:"api_version,omitempty"`
+	// Timeout is the timeout for requests to the discovered targets
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Headers are sent with every request to the discovered targets.
+	// Every header must be in the form `Name: value`.
+	Headers []string `yaml:"headers,omitempty"`
+	// Signature contains settings for signing requests
+	// to the discovered targets
+	Signature *SignatureConfig `yaml:"signature,omitempty"`
+	// HTTPClientConfig contains auth and TLS settings
+	// for the discovered targets
+	HTTPClientConfig promauth.HTTPClientConfig `yaml:",inline"`
+
+	StaticConfigs   []StaticConfig              `yaml:"static_configs,omitempty"`
+	ConsulSDConfigs []*consul.SDConfig          `yaml:"consul_sd_configs,omitempty"`
+	RelabelConfigs  []promrelabel.RelabelConfig `yaml:"relabel_configs,omitempty"`
+	// AlertRelabelConfigs are applied to labels of alerts
+	// before sending them to the discovered targets
+	AlertRelabelConfigs []promrelabel.RelabelConfig `yaml:"alert_relabel_configs,omitempty"`
+}
+
+// StaticConfig contains the list of notifier targets
+// with optional labels available during relabeling.
+type StaticConfig struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+	// Timeout overrides the top-level timeout for Targets
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Headers override the top-level headers for Targets if set
+	Headers []string `yaml:"headers,omitempty"`
+	// Signature overrides the top-level signature settings for Targets if set
+	Signature *SignatureConfig `yaml:"signature,omitempty"`
+	// HTTPClientConfig overrides the top-level
+	// auth and TLS settings for Targets if set
+	HTTPClientConfig promauth.HTTPClientConfig `yaml:",inline"`
+}
+
+// parseConfig parses and validates the config data read from path
+func parseConfig(data []byte, path string) (*Config, error) {
+	data, err := envtemplate.ReplaceStrict(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand environment vars in %q: %w", path, err)
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	switch cfg.Scheme {
+	case "":
+		cfg.Scheme = "http"
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q in %q; supported schemes: http, https", cfg.Scheme, path)
+	}
+	if cfg.PathPrefix != "" {
+		cfg.PathPrefix = "/" + strings.Trim(cfg.PathPrefix, "/")
+	}
+	if cfg.Timeout < 0 {
+		return nil, fmt.Errorf("timeout can't be negative in %q", path)
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	for i := range cfg.StaticConfigs {
+		sc := &cfg.StaticConfigs[i]
+		if sc.Timeout < 0 {
+			return nil, fmt.Errorf("timeout can't be negative in static_configs #%d in %q", i+1, path)
+		}
+		if sc.Timeout == 0 {
+			sc.Timeout = cfg.Timeout
+		}
+	}
+	if len(cfg.StaticConfigs) == 0 && len(cfg.ConsulSDConfigs) == 0 {
+		return nil, fmt.Errorf("no static_configs or consul_sd_configs found in %q", path)
+	}
+	return cfg, nil
+}
+
+// configNotifier sends alerts to all targets discovered
+// according to -notifier.config. Targets are refreshed periodically.
+// The last discovered targets are kept if discovery fails.
+type configNotifier struct {
+	path    string
+	baseDir string
+	gen     AlertURLGenerator
+
+	mu    sync.Mutex
+	state *configState
+
+	// refreshMu serializes refresh and reload,
+	// so targets of the previous state aren't updated during reload
+	refreshMu sync.Mutex
+}
+
+// configState contains the parsed -notifier.config
+// and the targets discovered according to it
+type configState struct {
+	data []byte
+	cfg  *Config
+
+	relabelConfigs      *promrelabel.ParsedConfigs
+	alertRelabelConfigs *promrelabel.ParsedConfigs
+	// staticTmpls contains templates for targets
+	// of the corresponding cfg.StaticConfigs
+	staticTmpls []*AlertManager
+	targets     *discoveredTargets
+}
+
+// newConfigNotifier returns configNotifier for the config at path
+func newConfigNotifier(path string, gen AlertURLGenerator) (*configNotifier, error) {
+	cn := &configNotifier{
+		path:    path,
+		baseDir: filepath.Dir(path),
+		gen:     gen,
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", path, err)
+	}
+	cn.state, err = cn.newState(data)
+	if err != nil {
+		return nil, err
+	}
+	return cn, nil
+}
+
+func (cn *configNotifier) newState(data []byte) (*configState, error) {
+	cfg, err := parseConfig(data, cn.path)
+	if err != nil {
+		return nil, err
+	}
+	relabelConfigs, err := promrelabel.ParseRelabelConfigs(cfg.RelabelConfigs, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse relabel_configs in %q: %w", cn.path, err)
+	}
+	alertRelabelConfigs, err := promrelabel.ParseRelabelConfigs(cfg.AlertRelabelConfigs, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse alert_relabel_configs in %q: %w", cn.path, err)
+	}
+	tmpl, err := cn.newTemplate(cfg.HTTPClientConfig, cfg.Timeout, cfg.Headers, cfg.Signature, cfg.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	st := &configState{
+		data:                data,
+		cfg:                 cfg,
+		relabelConfigs:      relabelConfigs,
+		alertRelabelConfigs: alertRelabelConfigs,
+		targets:             newDiscoveredTargets(tmpl),
+	}
+	for i, sc := range cfg.StaticConfigs {
+		if sc.HTTPClientConfig == (promauth.HTTPClientConfig{}) && sc.Timeout == cfg.Timeout && len(sc.Headers) == 0 && sc.Signature == nil {
+			st.staticTmpls = append(st.staticTmpls, tmpl)
+			continue
+		}
+		hcc := sc.HTTPClientConfig
+		if hcc == (promauth.HTTPClientConfig{}) {
+			hcc = cfg.HTTPClientConfig
+		}
+		headers := sc.Headers
+		if len(headers) == 0 {
+			headers = cfg.Headers
+		}
+		sig := sc.Signature
+		if sig == nil {
+			sig = cfg.Signature
+		}
+		t, err := cn.newTemplate(hcc, sc.Timeout, headers, sig, cfg.APIVersion)
+		if err != nil {
+			return nil, fmt.Errorf("static_configs #%d: %w", i+1, err)
+		}
+		st.staticTmpls = append(st.staticTmpls, t)
+	}
+	return st, nil
+}
+
+// newTemplate returns AlertManager with empty base url,
+// so the discovered targets get only the API path from it
+func (cn *configNotifier) newTemplate(hcc promauth.HTTPClientConfig, timeout time.Duration, headers []string, sig *SignatureConfig, apiVersion string) (*AlertManager, error) {
+	ac, err := hcc.NewConfig(cn.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config in %q: %w", cn.path, err)
+	}
+	hs, err := utils.ParseHeadersList(headers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid headers in %q: %w", cn.path, err)
+	}
+	s, err := sig.newSigner(cn.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature in %q: %w", cn.path, err)
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = ac.NewTLSConfig()
+	setTransportTimeouts(tr)
+	tmpl := NewAlertManager("", "", "", cn.gen, &http.Client{Transport: tr})
+	tmpl.authCfg = ac
+	tmpl.cfgKey = templateKey(hcc, timeout, headers, sig, apiVersion)
+	tmpl.timeout = timeout
+	tmpl.headers = hs
+	tmpl.signer = s
+	if err := tmpl.setAPIVersion(apiVersion); err != nil {
+		return nil, fmt.Errorf("invalid api_version in %q: %w", cn.path, err)
+	}
+	return tmpl, nil
+}
+
+// templateKey returns the key identifying the given target settings
+func templateKey(hcc promauth.HTTPClientConfig, timeout time.Duration, headers []string, sig *SignatureConfig, apiVersion string) string {
+	// signature is marshaled without hiding the secret,
+	// so changing the secret changes the key
+	type signature SignatureConfig
+	var s *signature
+	if sig != nil {
+		v := signature(*sig)
+		s = &v
+	}
+	// yaml.Marshal doesn't fail for the parsed config
+	data, _ := yaml.Marshal(struct {
+		HTTPClientConfig promauth.HTTPClientConfig
+		Timeout          time.Duration
+		Headers          []string
+		Signature        *signature
+		APIVersion       string
+	}{hcc, timeout, headers, s, apiVersion})
+	return string(data)
+}
+
+func (cn *configNotifier) getState() *configState {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	return cn.state
+}
+
+// Addr returns the path to -notifier.config.
+func (cn *configNotifier) Addr() string { return cn.path }
+
+// Name returns empty string, since -notifier.name
+// is applicable only to -notifier.url.
+func (cn *configNotifier) Name() string { return "" }
+
+// Send sends alerts to all discovered targets concurrently
+// after applying alert_relabel_configs to them.
+// Nothing is sent if all the alerts are dropped.
+func (cn *configNotifier) Send(ctx context.Context, alerts []Alert) error {
+	st := cn.getState()
+	alerts = relabelAlerts(alerts, st.alertRelabelConfigs)
+	if len(alerts) == 0 {
+		return nil
+	}
+	return st.targets.send(ctx, alerts, cn.path)
+}
+
+// refresh discovers targets and updates them.
+// Targets aren't updated if any source fails.
+func (cn *configNotifier) refresh() error {
+	cn.refreshMu.Lock()
+	defer cn.refreshMu.Unlock()
+	st := cn.getState()
+	targets, err := cn.discover(st)
+	if err != nil {
+		return err
+	}
+	st.targets.update(targets, cn.path)
+	return nil
+}
+
+// discover returns targets discovered according to the config of st
+func (cn *configNotifier) discover(st *configState) (map[string]*discoveredTarget, error) {
+	targets := make(map[string]*discoveredTarget)
+	for i, sc := range st.cfg.StaticConfigs {
+		for _, t := range sc.Targets {
+			m := map[string]string{"__address__": t}
+			for k, v := range sc.Labels {
+				m[k] = v
+			}
+			st.addTarget(targets, m, TargetSourceStaticConfigs, st.staticTmpls[i])
+		}
+	}
+	for i, sdc := range st.cfg.ConsulSDConfigs {
+		ms, err := sdc.GetLabels(cn.baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot discover targets via consul_sd_configs #%d in %q: %w", i+1, cn.path, err)
+		}
+		for _, m := range ms {
+			st.addTarget(targets, m, TargetSourceConsul, nil)
+		}
+	}
+	return targets, nil
+}
+
+// addTarget applies relabeling to labels of the discovered target
+// and adds it to dst unless it was dropped during relabeling.
+// The target is created from tmpl if it is set.
+func (st *configState) addTarget(dst map[string]*discoveredTarget, m map[string]string, source string, tmpl *AlertManager) {
+	labels := make([]prompbmarshal.Label, 0, len(m)+1)
+	for k, v := range m {
+		labels = append(labels, prompbmarshal.Label{Name: k, Value: v})
+	}
+	if _, ok := m["__scheme__"]; !ok {
+		labels = append(labels, prompbmarshal.Label{Name: "__scheme__", Value: st.cfg.Scheme})
+	}
+	// sort labels, so relabeling results don't depend on map iteration order
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	labels = st.relabelConfigs.Apply(labels, 0, false)
+	addr := promrelabel.GetLabelValueByName(labels, "__address__")
+	if addr == "" {
+		// the target is dropped during relabeling
+		return
+	}
+	scheme := promrelabel.GetLabelValueByName(labels, "__scheme__")
+	u := fmt.Sprintf("%s://%s%s", scheme, addr, st.cfg.PathPrefix)
+	lm := make(map[string]string, len(labels))
+	for _, l := range promrelabel.RemoveMetaLabels(nil, labels) {
+		lm[l.Name] = l.Value
+	}
+	dst[u] = &discoveredTarget{tmpl: tmpl, source: source, labels: lm}
+}
+
+// reload re-reads the config and applies it if it was changed.
+// The targets are discovered before applying the config,
+// so the previous config and targets are kept on error.
+func (cn *configNotifier) reload() error {
+	data, err := ioutil.ReadFile(cn.path)
+	if err != nil {
+		return fmt.Errorf("cannot read %q: %w", cn.path, err)
+	}
+	cn.refreshMu.Lock()
+	defer cn.refreshMu.Unlock()
+	prev := cn.getState()
+	if bytes.Equal(data, prev.data) {
+		return nil
+	}
+	st, err := cn.newState(data)
+	if err != nil {
+		return err
+	}
+	// unchanged consul_sd_configs are re-used, so their watchers
+	// keep the discovered targets instead of starting from scratch
+	prevSDConfigs := make(map[string]*consul.SDConfig, len(prev.cfg.ConsulSDConfigs))
+	for _, sdc := range prev.cfg.ConsulSDConfigs {
+		prevSDConfigs[sdConfigKey(sdc)] = sdc
+	}
+	var newSDConfigs []*consul.SDConfig
+	for i, sdc := range st.cfg.ConsulSDConfigs {
+		k := sdConfigKey(sdc)
+		if prevSDC, ok := prevSDConfigs[k]; ok {
+			st.cfg.ConsulSDConfigs[i] = prevSDC
+			delete(prevSDConfigs, k)
+			continue
+		}
+		newSDConfigs = append(newSDConfigs, sdc)
+	}
+	targets, err := cn.discover(st)
+	if err != nil {
+		for _, sdc := range newSDConfigs {
+			sdc.MustStop()
+		}
+		return err
+	}
+	// unchanged targets are carried over,
+	// so they keep detected API version and auth state
+	prevTargets := make(map[string]*AlertManager)
+	for _, t := range prev.targets.get() {
+		prevTargets[t.am.Addr()] = t.am
+	}
+	for addr, t := range targets {
+		tmpl := t.tmpl
+		if tmpl == nil {
+			tmpl = st.targets.tmpl
+		}
+		if am, ok := prevTargets[addr]; ok && am.cfgKey == tmpl.cfgKey {
+			t.am = am
+		}
+	}
+	st.targets.update(targets, cn.path)
+
+	cn.mu.Lock()
+	cn.state = st
+	cn.mu.Unlock()
+	for _, sdc := range prevSDConfigs {
+		sdc.MustStop()
+	}
+	// the previous targets are dropped together with the previous state
+	for addr := range prevTargets {
+		if !st.targets.has(addr) {
+			unregisterTargetStats(addr)
+		}
+	}
+	logger.Infof("reloaded -notifier.config=%q", cn.path)
+	return nil
+}
+
+func sdConfigKey(sdc *consul.SDConfig) string {
+	// yaml.Marshal doesn't fail for the parsed config
+	data, _ := yaml.Marshal(sdc)
+	return string(data)
+}
+
+// initialRefreshInterval is used for refreshing targets
+// until the first targets are discovered, since consul_sd_configs
+// discover targets in background
+const initialRefreshInterval = time.Second
+
+// run refreshes targets every interval until ctx is cancelled
+func (cn *configNotifier) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTimer(initialRefreshInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			for _, sdc := range cn.getState().cfg.ConsulSDConfigs {
+				sdc.MustStop()
+			}
+			return
+		case <-t.C:
+		}
+		err := cn.refresh()
+		n := len(cn.getState().targets.get())
+		if err != nil {
+			logger.Warnf("%s; keeping %d previously discovered targets", err, n)
+		}
+		if n == 0 && interval > initialRefreshInterval {
+			t.Reset(initialRefreshInterval)
+			continue
+		}
+		t.Reset(interval)
+	}
+}
+
+// Reload re-reads -notifier.config if it was set.
+// The previous config is kept on error.
+func Reload() error {
+	initializedMu.Lock()
+	nts := append([]Notifier{}, initialized...)
+	initializedMu.Unlock()
+	for _, nt := range nts {
+		if cn, ok := nt.(*configNotifier); ok {
+			if err := cn.reload(); err != nil {
+				return fmt.Errorf("cannot reload -notifier.config: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "notifier-config")
+	if err != nil {
+		t.Fatalf("cannot create temp file: %s", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("cannot write temp file: %s", err)
+	}
+	return f.Name()
+}
+
+func TestParseConfig_Failure(t *testing.T) {
+	f := func(data, errStr string) {
+		t.Helper()
+		_, err := parseConfig([]byte(data), "config.yml")
+		if err == nil {
+			t.Fatalf("expected to get error for %q", data)
+		}
+		if !strings.Contains(err.Error(), errStr) {
+			t.Fatalf("expected error to contain %q; got %q", errStr, err)
+		}
+	}
+	f(`scheme: ftp
+static_configs:
+- targets: [localhost:9093]`, "unsupported scheme")
+	f(`foo: bar`, "not found in type")
+	f(`scheme: http`, "no static_configs or consul_sd_configs")
+	f(`timeout: -1s
+static_configs:
+- targets: [localhost:9093]`, "timeout can't be negative")
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+path_prefix: /alertmanager/
+consul_sd_configs:
+- server: localhost:8500
+  datacenter: dc1
+  services: [alertmanager]
+  tags: [prod]
+  token: secret
+relabel_configs:
+- source_labels: [__meta_consul_tags]
+  regex: .*,canary,.*
+  action: drop
+static_configs:
+- targets: [localhost:9093]
+  timeout: 5s
+- targets: [localhost:9094]
+`), "config.yml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Scheme != "http" {
+		t.Fatalf("expected default scheme %q; got %q", "http", cfg.Scheme)
+	}
+	if cfg.PathPrefix != "/alertmanager" {
+		t.Fatalf("unexpected path prefix %q", cfg.PathPrefix)
+	}
+	if len(cfg.ConsulSDConfigs) != 1 {
+		t.Fatalf("expected 1 consul_sd_config; got %d", len(cfg.ConsulSDConfigs))
+	}
+	if cfg.Timeout != defaultTimeout {
+		t.Fatalf("expected default timeout %s; got %s", defaultTimeout, cfg.Timeout)
+	}
+	if cfg.StaticConfigs[0].Timeout != 5*time.Second || cfg.StaticConfigs[1].Timeout != defaultTimeout {
+		t.Fatalf("unexpected static_configs timeouts: %s, %s", cfg.StaticConfigs[0].Timeout, cfg.StaticConfigs[1].Timeout)
+	}
+	sdc := cfg.ConsulSDConfigs[0]
+	if sdc.Datacenter != "dc1" || sdc.Token == nil || *sdc.Token != "secret" || sdc.Services[0] != "alertmanager" {
+		t.Fatalf("unexpected consul_sd_config: %#v", sdc)
+	}
+}
+
+func TestConfigNotifier(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/am"+alertManagerV1Path {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer foo" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	path := writeConfig(t, fmt.Sprintf(`
+path_prefix: am
+api_version: v1
+bearer_token: foo
+static_configs:
+- targets: [%s]
+  labels:
+    env: prod
+- targets: [canary:9093]
+  labels:
+    env: canary
+relabel_configs:
+- source_labels: [env]
+  regex: canary
+  action: drop
+`, u.Host))
+	defer func() { _ = os.Remove(path) }()
+	cn, err := newConfigNotifier(path, func(alert Alert) string { return "" })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.refresh(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	targets := cn.getState().targets.list()
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target after relabeling; got %d", len(targets))
+	}
+	target := targets[0]
+	if target.Addr != srv.URL+"/am" {
+		t.Fatalf("unexpected target address %q", target.Addr)
+	}
+	if target.Source != TargetSourceStaticConfigs || target.Labels["env"] != "prod" {
+		t.Fatalf("unexpected target: %#v", target)
+	}
+	if err := cn.Send(context.Background(), []Alert{{}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hits != 1 {
+		t.Fatalf("expected 1 request; got %d", hits)
+	}
+}
+
+func TestConfigNotifier_StaticConfigAuth(t *testing.T) {
+	auth := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth <- r.Host + " " + r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	// the same server is available via two addresses
+	// and receives different credentials via each of them
+	path := writeConfig(t, fmt.Sprintf(`
+bearer_token: foo
+static_configs:
+- targets: [%s]
+- targets: [localhost:%s]
+  basic_auth:
+    username: bar
+`, u.Host, u.Port()))
+	defer func() { _ = os.Remove(path) }()
+	cn, err := newConfigNotifier(path, func(alert Alert) string { return "" })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.refresh(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.Send(context.Background(), []Alert{{}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := map[string]bool{<-auth: true, <-auth: true}
+	for _, exp := range []string{
+		u.Host + " Bearer foo",
+		"localhost:" + u.Port() + " Basic YmFyOg==",
+	} {
+		if !got[exp] {
+			t.Fatalf("expected request %q; got %v", exp, got)
+		}
+	}
+}
+
+func TestConfigNotifier_StaticConfigHeaders(t *testing.T) {
+	headers := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Host + " " + r.Header.Get("X-Route") + " " + r.Header.Get("X-Api-Key")
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	path := writeConfig(t, fmt.Sprintf(`
+headers:
+- "X-Route: am"
+- "X-Api-Key: foo"
+static_configs:
+- targets: [%s]
+- targets: [localhost:%s]
+  headers: ["X-Route: am-backup"]
+`, u.Host, u.Port()))
+	defer func() { _ = os.Remove(path) }()
+	cn, err := newConfigNotifier(path, func(alert Alert) string { return "" })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.refresh(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.Send(context.Background(), []Alert{{}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := map[string]bool{<-headers: true, <-headers: true}
+	for _, exp := range []string{
+		u.Host + " am foo",
+		// headers of static_configs override the top-level headers
+		"localhost:" + u.Port() + " am-backup ",
+	} {
+		if !got[exp] {
+			t.Fatalf("expected request %q; got %v", exp, got)
+		}
+	}
+
+	path = writeConfig(t, `
+headers: ["X-Api-Key"]
+static_configs:
+- targets: [localhost:9093]
+`)
+	defer func() { _ = os.Remove(path) }()
+	if _, err := newConfigNotifier(path, func(alert Alert) string { return "" }); err == nil {
+		t.Fatalf("expected error on invalid headers")
+	}
+}
+
+func TestConfigNotifier_Reload(t *testing.T) {
+	path := writeConfig(t, `
+static_configs:
+- targets: [alertmanager-1:9093]
+`)
+	defer func() { _ = os.Remove(path) }()
+	cn, err := newConfigNotifier(path, func(alert Alert) string { return "" })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.refresh(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(data string, expErr bool, expAddr string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("cannot write config: %s", err)
+		}
+		err := cn.reload()
+		if expErr && err == nil {
+			t.Fatalf("expected to get error")
+		}
+		if !expErr && err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		targets := cn.getState().targets.list()
+		if len(targets) != 1 || targets[0].Addr != expAddr {
+			t.Fatalf("expected target %q; got %#v", expAddr, targets)
+		}
+	}
+	f(`
+static_configs:
+- targets: [alertmanager-2:9093]
+`, false, "http://alertmanager-2:9093")
+	// invalid config keeps the previous targets
+	f(`
+static_configs:
+- targets: [alertmanager-3:9093]
+  foo: bar
+`, true, "http://alertmanager-2:9093")
+	// failed discovery keeps the previous targets
+	f(`
+static_configs:
+- targets: [alertmanager-3:9093]
+consul_sd_configs:
+- server: localhost:8500
+  token: foo
+  bearer_token: bar
+`, true, "http://alertmanager-2:9093")
+
+	// unchanged target is carried over with the detected API version
+	am := cn.getState().targets.get()[0].am
+	am.mu.Lock()
+	am.alertURL = am.baseURL + alertManagerV1Path
+	am.mu.Unlock()
+	f(`
+static_configs:
+- targets: [alertmanager-2:9093]
+  labels:
+    env: prod
+`, false, "http://alertmanager-2:9093")
+	if got := cn.getState().targets.get()[0].am; got != am || got.alertURL != am.baseURL+alertManagerV1Path {
+		t.Fatalf("expected the target to be carried over on reload")
+	}
+	// target with changed settings is re-created
+	f(`
+timeout: 5s
+static_configs:
+- targets: [alertmanager-2:9093]
+`, false, "http://alertmanager-2:9093")
+	if got := cn.getState().targets.get()[0].am; got == am || got.timeout != 5*time.Second {
+		t.Fatalf("expected the target to be re-created with the new settings")
+	}
+}
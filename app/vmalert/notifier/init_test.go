@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+)
+
+func TestInitSecretFlags(t *testing.T) {
+	InitSecretFlags()
+	// headers are the only way to pass static API keys to webhooks,
+	// so they must never be exposed
+	for _, name := range []string{"notifier.headers", "notifier.signature.secret"} {
+		if !flagutil.IsSecretFlag(name) {
+			t.Fatalf("expected -%s to be registered as secret", name)
+		}
+	}
+}
+
+func TestGetDirectNotifiers(t *testing.T) {
+	*addrs = flagutil.Array{"http://localhost:9093"}
+	defer func() { *addrs = nil }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nts, err := Init(ctx, func(Alert) string { return "" })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := nts[0].(*retryNotifier); !ok {
+		t.Fatalf("expected notifier to be wrapped with retries; got %T", nts[0])
+	}
+	direct := GetDirectNotifiers()
+	if len(direct) != 1 {
+		t.Fatalf("expected 1 direct notifier; got %d", len(direct))
+	}
+	sn, ok := direct[0].(*statsNotifier)
+	if !ok {
+		t.Fatalf("expected direct notifier to record stats; got %T", direct[0])
+	}
+	if _, ok := sn.Notifier.(*AlertManager); !ok {
+		t.Fatalf("expected direct notifier to send alerts without queue and retries; got %T", sn.Notifier)
+	}
+}
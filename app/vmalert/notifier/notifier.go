@@ -10,4 +10,7 @@ type Notifier interface {
 	Send(ctx context.Context, alerts []Alert) error
 	// Addr returns address where alerts are sent.
 	Addr() string
+	// Name returns the optional name of the notifier,
+	// which may be referred by groups. May be empty.
+	Name() string
 }
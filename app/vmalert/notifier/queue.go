@@ -0,0 +1,186 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// errQueueOverflow is returned for the oldest queued batch
+// dropped because of the full send queue
+var errQueueOverflow = errors.New("the batch was dropped because of the full send queue; see -notifier.sendQueueSize")
+
+// queueNotifier limits the number of concurrent sends via Notifier.
+// Batches exceeding the limit wait in the queue of up to maxSize batches.
+// Send waits until the batch is sent, so the caller gets the send result.
+// The wait, including the sending, is limited by timeout if it is set.
+type queueNotifier struct {
+	Notifier
+
+	maxSize int
+	timeout time.Duration
+	wakeCh  chan struct{}
+
+	mu    sync.Mutex
+	queue []*sendJob
+
+	superseded *metrics.Counter
+	overflowed *metrics.Counter
+}
+
+type sendJob struct {
+	ctx    context.Context
+	key    string
+	alerts []Alert
+	// done receives the send result
+	done chan error
+}
+
+// newQueueNotifier returns queueNotifier which sends alerts via nt
+// with up to concurrency workers. Workers stop when ctx is cancelled.
+func newQueueNotifier(ctx context.Context, nt Notifier, concurrency, maxSize int, timeout time.Duration) *queueNotifier {
+	qn := &queueNotifier{
+		Notifier:   nt,
+		maxSize:    maxSize,
+		timeout:    timeout,
+		wakeCh:     make(chan struct{}, concurrency),
+		superseded: metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_send_queue_dropped_batches_total{addr=%q,reason="superseded"}`, nt.Addr())),
+		overflowed: metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_send_queue_dropped_batches_total{addr=%q,reason="overflow"}`, nt.Addr())),
+	}
+	metrics.GetOrCreateGauge(fmt.Sprintf(`vmalert_alerts_send_queue_size{addr=%q}`, nt.Addr()), func() float64 {
+		return float64(qn.size())
+	})
+	for i := 0; i < concurrency; i++ {
+		go qn.run(ctx)
+	}
+	return qn
+}
+
+// Send queues alerts and waits until they are sent.
+// If the queue is full, the oldest queued batch of the same rules
+// is replaced with alerts if they contain all the alerts of the queued batch.
+// Otherwise, the oldest queued batch is dropped with errQueueOverflow.
+// Send of the replaced batch returns no error, since its alerts
+// are sent with the newer batch.
+func (qn *queueNotifier) Send(ctx context.Context, alerts []Alert) error {
+	if qn.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, qn.timeout)
+		defer cancel()
+	}
+	// batches limited to different targets mustn't supersede each other
+	key := batchKey(alerts) + "@" + strings.Join(targetsFromContext(ctx), ",")
+	job := &sendJob{
+		ctx:    ctx,
+		key:    key,
+		alerts: alerts,
+		done:   make(chan error, 1),
+	}
+	qn.push(job)
+	select {
+	case qn.wakeCh <- struct{}{}:
+	default:
+		// the rest of workers are already woken up
+	}
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (qn *queueNotifier) push(job *sendJob) {
+	qn.mu.Lock()
+	defer qn.mu.Unlock()
+	if len(qn.queue) < qn.maxSize {
+		qn.queue = append(qn.queue, job)
+		return
+	}
+	for i, j := range qn.queue {
+		if j.key == job.key && containsAlerts(job.alerts, j.alerts) {
+			// the newer batch contains the latest state of all the alerts of the queued batch.
+			// Batches of the same rules may contain different alerts, since unchanged
+			// firing alerts are throttled by -rule.resendDelay and resolved alerts
+			// are sent a limited number of times, so they can't be replaced otherwise
+			j.done <- nil
+			qn.superseded.Inc()
+			qn.queue[i] = job
+			return
+		}
+	}
+	qn.queue[0].done <- errQueueOverflow
+	qn.overflowed.Inc()
+	qn.queue = append(qn.queue[1:], job)
+}
+
+func (qn *queueNotifier) pop() *sendJob {
+	qn.mu.Lock()
+	defer qn.mu.Unlock()
+	if len(qn.queue) == 0 {
+		return nil
+	}
+	job := qn.queue[0]
+	qn.queue[0] = nil
+	qn.queue = qn.queue[1:]
+	return job
+}
+
+func (qn *queueNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-qn.wakeCh:
+		}
+		for job := qn.pop(); job != nil; job = qn.pop() {
+			if err := job.ctx.Err(); err != nil {
+				// the caller doesn't wait for the result anymore
+				job.done <- err
+				continue
+			}
+			job.done <- qn.Notifier.Send(job.ctx, job.alerts)
+		}
+	}
+}
+
+// size returns the number of queued batches
+func (qn *queueNotifier) size() int {
+	qn.mu.Lock()
+	defer qn.mu.Unlock()
+	return len(qn.queue)
+}
+
+// containsAlerts returns true if alerts contain all the alerts from subset by ID
+func containsAlerts(alerts, subset []Alert) bool {
+	m := make(map[alertKey]struct{}, len(alerts))
+	for _, a := range alerts {
+		m[alertKey{groupID: a.GroupID, id: a.ID}] = struct{}{}
+	}
+	for _, a := range subset {
+		if _, ok := m[alertKey{groupID: a.GroupID, id: a.ID}]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// batchKey returns the key identifying rules with alerts in the batch
+func batchKey(alerts []Alert) string {
+	m := make(map[string]struct{})
+	for _, a := range alerts {
+		m[fmt.Sprintf("%d/%s", a.GroupID, a.Name)] = struct{}{}
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
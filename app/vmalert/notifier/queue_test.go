@@ -0,0 +1,174 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingNotifier blocks sending until release is closed
+type blockingNotifier struct {
+	release chan struct{}
+
+	mu          sync.Mutex
+	inflight    int
+	maxInflight int
+	sent        []string
+}
+
+func (bn *blockingNotifier) Send(_ context.Context, alerts []Alert) error {
+	bn.mu.Lock()
+	bn.inflight++
+	if bn.inflight > bn.maxInflight {
+		bn.maxInflight = bn.inflight
+	}
+	bn.mu.Unlock()
+
+	<-bn.release
+
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	bn.inflight--
+	for _, a := range alerts {
+		bn.sent = append(bn.sent, a.Name)
+	}
+	return nil
+}
+
+func (bn *blockingNotifier) Addr() string { return "blocking" }
+func (bn *blockingNotifier) Name() string { return "" }
+
+func (bn *blockingNotifier) getInflight() int {
+	bn.mu.Lock()
+	defer bn.mu.Unlock()
+	return bn.inflight
+}
+
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout while waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueueNotifier_Concurrency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bn := &blockingNotifier{release: make(chan struct{})}
+	qn := newQueueNotifier(ctx, bn, 2, 10, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := qn.Send(ctx, []Alert{{GroupID: uint64(i), Name: "alert"}}); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}(i)
+	}
+	waitFor(t, "queued batches", func() bool { return bn.getInflight() == 2 && qn.size() == 3 })
+	close(bn.release)
+	wg.Wait()
+	if bn.maxInflight != 2 {
+		t.Fatalf("expected max 2 concurrent sends; got %d", bn.maxInflight)
+	}
+	if len(bn.sent) != 5 {
+		t.Fatalf("expected 5 sent alerts; got %d", len(bn.sent))
+	}
+}
+
+func TestQueueNotifier_Full(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bn := &blockingNotifier{release: make(chan struct{})}
+	qn := newQueueNotifier(ctx, bn, 1, 2, 0)
+	errs := make(map[string]chan error)
+	sendAlert := func(name string, a Alert) {
+		ch := make(chan error, 1)
+		errs[name] = ch
+		go func() { ch <- qn.Send(ctx, []Alert{a}) }()
+	}
+	send := func(name, rule string) {
+		sendAlert(name, Alert{GroupID: 1, Name: rule})
+	}
+
+	// the only worker is busy with the first batch
+	send("a", "r0")
+	waitFor(t, "the first batch to be sent", func() bool { return bn.getInflight() == 1 })
+	send("b", "r1")
+	waitFor(t, "the second batch to be queued", func() bool { return qn.size() == 1 })
+	send("c", "r2")
+	waitFor(t, "the third batch to be queued", func() bool { return qn.size() == 2 })
+
+	// the queue is full, so the batch with the same alerts is replaced
+	supersededBefore := qn.superseded.Get()
+	send("d", "r1")
+	// the replaced batch isn't a send error, since its alerts are sent with the newer batch
+	if err := <-errs["b"]; err != nil {
+		t.Fatalf("unexpected error for the superseded batch: %s", err)
+	}
+	if n := qn.superseded.Get() - supersededBefore; n != 1 {
+		t.Fatalf("expected 1 superseded batch; got %d", n)
+	}
+
+	// the oldest batch is dropped for the batch of another rule
+	overflowedBefore := qn.overflowed.Get()
+	send("e", "r3")
+	if err := <-errs["d"]; err != errQueueOverflow {
+		t.Fatalf("expected to get %q; got %v", errQueueOverflow, err)
+	}
+	if n := qn.overflowed.Get() - overflowedBefore; n != 1 {
+		t.Fatalf("expected 1 dropped batch; got %d", n)
+	}
+
+	// the batch of the same rule missing some of the queued alerts
+	// doesn't replace the queued batch, so the oldest batch is dropped
+	sendAlert("f", Alert{GroupID: 1, Name: "r3", ID: 1})
+	if err := <-errs["c"]; err != errQueueOverflow {
+		t.Fatalf("expected to get %q; got %v", errQueueOverflow, err)
+	}
+
+	close(bn.release)
+	for _, name := range []string{"a", "e", "f"} {
+		if err := <-errs[name]; err != nil {
+			t.Fatalf("unexpected error for batch %q: %s", name, err)
+		}
+	}
+	exp := []string{"r0", "r3", "r3"}
+	if len(bn.sent) != len(exp) {
+		t.Fatalf("expected to send %v; got %v", exp, bn.sent)
+	}
+	for i, name := range bn.sent {
+		if name != exp[i] {
+			t.Fatalf("expected to send %v; got %v", exp, bn.sent)
+		}
+	}
+}
+
+func TestQueueNotifier_Timeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bn := &blockingNotifier{release: make(chan struct{})}
+	defer close(bn.release)
+	qn := newQueueNotifier(ctx, bn, 1, 10, 50*time.Millisecond)
+	go func() { _ = qn.Send(context.Background(), []Alert{{GroupID: 1, Name: "r0"}}) }()
+	waitFor(t, "the first batch to be sent", func() bool { return bn.getInflight() == 1 })
+
+	// the wait in the queue is limited by the timeout
+	// even if the caller's context has no deadline
+	start := time.Now()
+	if err := qn.Send(context.Background(), []Alert{{GroupID: 1, Name: "r1"}}); err != context.DeadlineExceeded {
+		t.Fatalf("expected to get %q; got %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("expected Send to return after the timeout; it took %s", d)
+	}
+}
@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"context"
+	"sort"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var alertsDroppedByRelabeling = metrics.NewCounter(`vmalert_alerts_relabel_dropped_total`)
+
+// relabelAlerts returns alerts with labels relabeled according to pcs.
+// Alerts without labels after relabeling are dropped.
+// The passed alerts aren't modified, since their labels
+// are shared with the alerting rules.
+func relabelAlerts(alerts []Alert, pcs *promrelabel.ParsedConfigs) []Alert {
+	if pcs.Len() == 0 {
+		return alerts
+	}
+	res := make([]Alert, 0, len(alerts))
+	var labels []prompbmarshal.Label
+	for _, a := range alerts {
+		labels = labels[:0]
+		for k, v := range a.Labels {
+			labels = append(labels, prompbmarshal.Label{Name: k, Value: v})
+		}
+		// sort labels, so relabeling results don't depend on map iteration order
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		labels = pcs.Apply(labels, 0, false)
+		if len(labels) == 0 {
+			alertsDroppedByRelabeling.Inc()
+			continue
+		}
+		a.Labels = make(map[string]string, len(labels))
+		for _, l := range labels {
+			a.Labels[l.Name] = l.Value
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// relabelNotifier applies -notifier.relabelConfig
+// to alerts before sending them via Notifier
+type relabelNotifier struct {
+	Notifier
+	pcs *promrelabel.ParsedConfigs
+}
+
+// Send sends relabeled alerts via Notifier.
+// Nothing is sent if all the alerts are dropped.
+func (rn *relabelNotifier) Send(ctx context.Context, alerts []Alert) error {
+	alerts = relabelAlerts(alerts, rn.pcs)
+	if len(alerts) == 0 {
+		return nil
+	}
+	return rn.Notifier.Send(ctx, alerts)
+}
@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+)
+
+func TestRelabelAlerts(t *testing.T) {
+	pcs, err := promrelabel.ParseRelabelConfigsData([]byte(`
+- action: labeldrop
+  regex: "__replica__|instance_id"
+- source_labels: [severity]
+  regex: page
+  target_label: severity
+  replacement: critical
+- source_labels: [env]
+  regex: dev
+  action: drop
+`), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	alerts := []Alert{
+		{ID: 1, Labels: map[string]string{"alertname": "a", "severity": "page", "__replica__": "1", "instance_id": "x"}},
+		{ID: 2, Labels: map[string]string{"alertname": "b", "env": "dev"}},
+		{ID: 3, Labels: map[string]string{"alertname": "c", "severity": "warning"}},
+	}
+	got := relabelAlerts(alerts, pcs)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 alerts after relabeling; got %d", len(got))
+	}
+	if exp := map[string]string{"alertname": "a", "severity": "critical"}; !reflect.DeepEqual(got[0].Labels, exp) {
+		t.Fatalf("unexpected labels: %v; want %v", got[0].Labels, exp)
+	}
+	if exp := map[string]string{"alertname": "c", "severity": "warning"}; !reflect.DeepEqual(got[1].Labels, exp) {
+		t.Fatalf("unexpected labels: %v; want %v", got[1].Labels, exp)
+	}
+	// labels of the original alerts are shared with rules, so they mustn't change
+	if alerts[0].Labels["severity"] != "page" || alerts[0].Labels["__replica__"] != "1" {
+		t.Fatalf("original alert labels mustn't be modified; got %v", alerts[0].Labels)
+	}
+
+	// empty relabel configs return alerts as is
+	if got := relabelAlerts(alerts, nil); len(got) != len(alerts) {
+		t.Fatalf("expected %d alerts; got %d", len(alerts), len(got))
+	}
+}
+
+func TestRelabelNotifier(t *testing.T) {
+	pcs, err := promrelabel.ParseRelabelConfigsData([]byte(`
+- source_labels: [env]
+  regex: dev
+  action: drop
+`), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fn := &flakyNotifier{failing: true}
+	rn := &relabelNotifier{Notifier: fn, pcs: pcs}
+	// nothing is sent if all the alerts are dropped
+	if err := rn.Send(context.Background(), []Alert{{Labels: map[string]string{"env": "dev"}}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fn.setFailing(false)
+	if err := rn.Send(context.Background(), []Alert{{Labels: map[string]string{"env": "prod"}}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := len(fn.getSent()); n != 1 {
+		t.Fatalf("expected 1 sent alert; got %d", n)
+	}
+}
@@ -0,0 +1,240 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+const (
+	// retryMinBackoff is the delay before the first retry
+	// of failed notifications
+	retryMinBackoff = time.Second
+	// retryMaxBackoff is the max delay between retries
+	retryMaxBackoff = time.Minute
+)
+
+// retryNotifier buffers alerts which Notifier failed to send
+// and retries sending them in background with exponential backoff.
+// Only the latest notification is kept for every alert,
+// so superseded notifications don't grow the queue.
+// If Notifier returns targetsError, alerts are retried
+// only for the targets which failed.
+type retryNotifier struct {
+	Notifier
+
+	maxAge     time.Duration
+	maxSize    int
+	minBackoff time.Duration
+
+	mu    sync.Mutex
+	queue map[alertKey]*queuedAlert
+	// seq is incremented for every queued notification
+	seq    uint64
+	wakeCh chan struct{}
+
+	retries *metrics.Counter
+	dropped *metrics.Counter
+}
+
+type alertKey struct {
+	groupID uint64
+	id      uint64
+}
+
+type queuedAlert struct {
+	alert    Alert
+	queuedAt time.Time
+	seq      uint64
+	// targets contains addresses of the failed targets
+	// or nil if the alert must be retried for all the targets
+	targets []string
+}
+
+// newRetryNotifier returns retryNotifier which retries failed notifications
+// for up to maxAge and keeps up to maxSize alerts in queue.
+// Retries stop when ctx is cancelled.
+func newRetryNotifier(ctx context.Context, nt Notifier, maxAge time.Duration, maxSize int) *retryNotifier {
+	rn := &retryNotifier{
+		Notifier:   nt,
+		maxAge:     maxAge,
+		maxSize:    maxSize,
+		minBackoff: retryMinBackoff,
+		queue:      make(map[alertKey]*queuedAlert),
+		wakeCh:     make(chan struct{}, 1),
+		retries:    metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_send_retries_total{addr=%q}`, nt.Addr())),
+		dropped:    metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_dropped_total{addr=%q}`, nt.Addr())),
+	}
+	go rn.run(ctx)
+	return rn
+}
+
+// Send sends alerts via Notifier. Alerts are queued for retries on failure.
+// The error is returned anyway, so the caller knows the alerts weren't delivered yet.
+func (rn *retryNotifier) Send(ctx context.Context, alerts []Alert) error {
+	err := rn.Notifier.Send(ctx, alerts)
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if err == nil {
+		// delivered notifications supersede the queued ones
+		for _, a := range alerts {
+			delete(rn.queue, alertKey{groupID: a.GroupID, id: a.ID})
+		}
+		return nil
+	}
+	now := time.Now()
+	targets := failedTargets(err)
+	for _, a := range alerts {
+		k := alertKey{groupID: a.GroupID, id: a.ID}
+		if _, ok := rn.queue[k]; !ok && len(rn.queue) >= rn.maxSize {
+			rn.dropped.Inc()
+			continue
+		}
+		rn.seq++
+		rn.queue[k] = &queuedAlert{alert: a, queuedAt: now, seq: rn.seq, targets: targets}
+	}
+	select {
+	case rn.wakeCh <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+func (rn *retryNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-rn.wakeCh:
+		}
+		backoff := rn.minBackoff
+		for {
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			pending := rn.pending(time.Now())
+			if len(pending) == 0 {
+				break
+			}
+			rn.retries.Inc()
+			var errs []string
+			failed := 0
+			for _, batch := range groupByTargets(pending) {
+				sendCtx := ctx
+				if batch[0].targets != nil {
+					sendCtx = withTargets(ctx, batch[0].targets)
+				}
+				alerts := make([]Alert, 0, len(batch))
+				for _, qa := range batch {
+					alerts = append(alerts, qa.alert)
+				}
+				err := rn.Notifier.Send(sendCtx, alerts)
+				rn.remove(batch, err)
+				if err != nil {
+					failed += len(alerts)
+					errs = append(errs, err.Error())
+				}
+			}
+			if failed > 0 {
+				if backoff *= 2; backoff > retryMaxBackoff {
+					backoff = retryMaxBackoff
+				}
+				logger.Warnf("failed to retry sending %d alerts to %q: %s; next retry in %s", failed, rn.Addr(), strings.Join(errs, "; "), backoff)
+				continue
+			}
+			backoff = rn.minBackoff
+		}
+	}
+}
+
+// failedTargets returns addresses of the failed targets from err
+// or nil if all the targets failed
+func failedTargets(err error) []string {
+	var te *targetsError
+	if errors.As(err, &te) {
+		return te.failed
+	}
+	return nil
+}
+
+// groupByTargets groups notifications by targets they must be retried for
+func groupByTargets(pending []queuedAlert) [][]queuedAlert {
+	var res [][]queuedAlert
+	idx := make(map[string]int)
+	for _, qa := range pending {
+		k := "*"
+		if qa.targets != nil {
+			k = strings.Join(qa.targets, ",")
+		}
+		i, ok := idx[k]
+		if !ok {
+			i = len(res)
+			idx[k] = i
+			res = append(res, nil)
+		}
+		res[i] = append(res[i], qa)
+	}
+	return res
+}
+
+// pending returns the queued notifications and drops
+// the notifications which weren't delivered within maxAge
+func (rn *retryNotifier) pending(now time.Time) []queuedAlert {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	res := make([]queuedAlert, 0, len(rn.queue))
+	for k, qa := range rn.queue {
+		if now.Sub(qa.queuedAt) > rn.maxAge {
+			rn.dropped.Inc()
+			delete(rn.queue, k)
+			continue
+		}
+		res = append(res, *qa)
+	}
+	return res
+}
+
+// remove removes delivered notifications from the queue
+// unless they were superseded while sending.
+// If err is targetsError, the notifications are kept
+// for the targets which failed again.
+func (rn *retryNotifier) remove(sent []queuedAlert, err error) {
+	var targets []string
+	if err != nil {
+		targets = failedTargets(err)
+		if targets == nil {
+			return
+		}
+	}
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	for _, s := range sent {
+		k := alertKey{groupID: s.alert.GroupID, id: s.alert.ID}
+		qa, ok := rn.queue[k]
+		if !ok || qa.seq != s.seq {
+			continue
+		}
+		if err != nil {
+			qa.targets = targets
+			continue
+		}
+		delete(rn.queue, k)
+	}
+}
+
+// size returns the number of queued notifications
+func (rn *retryNotifier) size() int {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return len(rn.queue)
+}
@@ -0,0 +1,197 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyNotifier fails to send alerts while failing is set
+type flakyNotifier struct {
+	mu      sync.Mutex
+	failing bool
+	sent    []Alert
+}
+
+func (fn *flakyNotifier) Send(_ context.Context, alerts []Alert) error {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	if fn.failing {
+		return errors.New("connection refused")
+	}
+	fn.sent = append(fn.sent, alerts...)
+	return nil
+}
+
+func (fn *flakyNotifier) Addr() string { return "flaky" }
+func (fn *flakyNotifier) Name() string { return "" }
+
+func (fn *flakyNotifier) setFailing(v bool) {
+	fn.mu.Lock()
+	fn.failing = v
+	fn.mu.Unlock()
+}
+
+func (fn *flakyNotifier) getSent() []Alert {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	return append([]Alert{}, fn.sent...)
+}
+
+func TestRetryNotifier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fn := &flakyNotifier{failing: true}
+	rn := newRetryNotifier(ctx, fn, time.Hour, 2)
+	rn.minBackoff = 10 * time.Millisecond
+
+	firing := Alert{GroupID: 1, ID: 1, State: StateFiring}
+	resolved := Alert{GroupID: 1, ID: 1, State: StateInactive}
+	other := Alert{GroupID: 1, ID: 2, State: StateFiring}
+	droppedBefore := rn.dropped.Get()
+	if err := rn.Send(ctx, []Alert{firing, other}); err == nil {
+		t.Fatalf("expected to get error from failing notifier")
+	}
+	// the resolved notification supersedes the firing one
+	if err := rn.Send(ctx, []Alert{resolved}); err == nil {
+		t.Fatalf("expected to get error from failing notifier")
+	}
+	// the queue is full
+	if err := rn.Send(ctx, []Alert{{GroupID: 1, ID: 3}}); err == nil {
+		t.Fatalf("expected to get error from failing notifier")
+	}
+	if n := rn.size(); n != 2 {
+		t.Fatalf("expected 2 queued alerts; got %d", n)
+	}
+	if n := rn.dropped.Get() - droppedBefore; n != 1 {
+		t.Fatalf("expected 1 dropped alert; got %d", n)
+	}
+
+	fn.setFailing(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for rn.size() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued alerts weren't retried")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sent := fn.getSent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 retried alerts; got %d", len(sent))
+	}
+	for _, a := range sent {
+		if a.ID == firing.ID && a.State != StateInactive {
+			t.Fatalf("expected the superseded firing notification to be replaced by the resolved one")
+		}
+	}
+}
+
+func TestRetryNotifierMaxAge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fn := &flakyNotifier{failing: true}
+	rn := newRetryNotifier(ctx, fn, time.Minute, 10)
+	// prevent retries in background
+	rn.minBackoff = time.Hour
+	_ = rn.Send(ctx, []Alert{{GroupID: 1, ID: 1}})
+	if n := len(rn.pending(time.Now())); n != 1 {
+		t.Fatalf("expected 1 pending alert; got %d", n)
+	}
+	droppedBefore := rn.dropped.Get()
+	if n := len(rn.pending(time.Now().Add(2 * time.Minute))); n != 0 {
+		t.Fatalf("expected expired alerts to be dropped; got %d pending alerts", n)
+	}
+	if n := rn.dropped.Get() - droppedBefore; n != 1 {
+		t.Fatalf("expected 1 dropped alert; got %d", n)
+	}
+
+	// successful delivery removes queued notifications
+	_ = rn.Send(ctx, []Alert{{GroupID: 1, ID: 2}})
+	fn.setFailing(false)
+	if err := rn.Send(ctx, []Alert{{GroupID: 1, ID: 2}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := rn.size(); n != 0 {
+		t.Fatalf("expected empty queue; got %d alerts", n)
+	}
+}
+
+// multiTargetNotifier sends alerts to targets "a" and "b"
+// and fails for the targets from failing
+type multiTargetNotifier struct {
+	mu      sync.Mutex
+	failing map[string]bool
+	sent    map[string]int
+}
+
+func (mn *multiTargetNotifier) Send(ctx context.Context, alerts []Alert) error {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	addrs := targetsFromContext(ctx)
+	if addrs == nil {
+		addrs = []string{"a", "b"}
+	}
+	var failed []string
+	for _, addr := range addrs {
+		if mn.failing[addr] {
+			failed = append(failed, addr)
+			continue
+		}
+		mn.sent[addr] += len(alerts)
+	}
+	if failed != nil {
+		return &targetsError{failed: failed, err: errors.New("connection refused")}
+	}
+	return nil
+}
+
+func (mn *multiTargetNotifier) Addr() string { return "multi" }
+func (mn *multiTargetNotifier) Name() string { return "" }
+
+func (mn *multiTargetNotifier) setFailing(addr string, v bool) {
+	mn.mu.Lock()
+	mn.failing[addr] = v
+	mn.mu.Unlock()
+}
+
+func (mn *multiTargetNotifier) getSent(addr string) int {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	return mn.sent[addr]
+}
+
+func TestRetryNotifier_FailedTargets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := &multiTargetNotifier{failing: map[string]bool{"b": true}, sent: make(map[string]int)}
+	rn := newRetryNotifier(ctx, mn, time.Hour, 10)
+	rn.minBackoff = 10 * time.Millisecond
+
+	if err := rn.Send(ctx, []Alert{{GroupID: 1, ID: 1}}); err == nil {
+		t.Fatalf("expected to get error from failing target")
+	}
+	if n := mn.getSent("a"); n != 1 {
+		t.Fatalf("expected 1 alert sent to target a; got %d", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	mn.setFailing("b", false)
+	deadline := time.Now().Add(5 * time.Second)
+	for rn.size() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued alerts weren't retried")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// the alert is retried only for the failed target
+	if n := mn.getSent("a"); n != 1 {
+		t.Fatalf("expected alert not to be re-sent to target a; got %d sent alerts", n)
+	}
+	if n := mn.getSent("b"); n != 1 {
+		t.Fatalf("expected 1 alert sent to target b; got %d", n)
+	}
+}
@@ -0,0 +1,114 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// defaultSignatureHeader is the default header for the request signature
+const defaultSignatureHeader = "X-Signature"
+
+// signer signs bodies of requests to notifiers with HMAC,
+// so the receiver may verify the sender and the integrity of alerts
+type signer struct {
+	header string
+	hashFn func() hash.Hash
+	secret []byte
+}
+
+// newSigner returns signer for the secret or the secret read from secretFile.
+// It returns nil if secret, secretFile, header and algorithm are empty.
+// Errors don't contain the secret.
+func newSigner(secret, secretFile, header, algorithm string) (*signer, error) {
+	if secret == "" && secretFile == "" {
+		if header != "" || algorithm != "" {
+			// requests would be sent unsigned otherwise
+			return nil, fmt.Errorf("signature header or algorithm is set without signature secret or secret file")
+		}
+		return nil, nil
+	}
+	if secret != "" && secretFile != "" {
+		return nil, fmt.Errorf("only one of signature secret or secret file can be set")
+	}
+	if secretFile != "" {
+		data, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read signature secret file: %w", err)
+		}
+		secret = strings.TrimSpace(string(data))
+		if secret == "" {
+			return nil, fmt.Errorf("signature secret file %q is empty", secretFile)
+		}
+	}
+	var hashFn func() hash.Hash
+	switch algorithm {
+	case "", "sha256":
+		hashFn = sha256.New
+	case "sha512":
+		hashFn = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %q; supported values: sha256, sha512", algorithm)
+	}
+	if header == "" {
+		header = defaultSignatureHeader
+	}
+	return &signer{
+		header: http.CanonicalHeaderKey(header),
+		hashFn: hashFn,
+		secret: []byte(secret),
+	}, nil
+}
+
+// sign sets the hex-encoded HMAC of body to the signature header of req
+func (s *signer) sign(req *http.Request, body []byte) {
+	mac := hmac.New(s.hashFn, s.secret)
+	_, _ = mac.Write(body)
+	req.Header.Set(s.header, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// SignatureConfig contains settings for signing requests
+// to notifier targets discovered via -notifier.config
+type SignatureConfig struct {
+	Secret     string `yaml:"secret,omitempty"`
+	SecretFile string `yaml:"secret_file,omitempty"`
+	// Header is the header for the signature. X-Signature by default
+	Header string `yaml:"header,omitempty"`
+	// Algorithm is the hash algorithm for HMAC: sha256 or sha512.
+	// sha256 is used by default
+	Algorithm string `yaml:"algorithm,omitempty"`
+}
+
+// MarshalYAML hides the secret, so it isn't exposed
+// if the config is marshaled for displaying
+func (sc SignatureConfig) MarshalYAML() (interface{}, error) {
+	type plain SignatureConfig
+	p := plain(sc)
+	if p.Secret != "" {
+		p.Secret = "<secret>"
+	}
+	return p, nil
+}
+
+// newSigner returns signer for sc. The relative
+// secret file path is resolved against baseDir
+func (sc *SignatureConfig) newSigner(baseDir string) (*signer, error) {
+	if sc == nil {
+		return nil, nil
+	}
+	if sc.Secret == "" && sc.SecretFile == "" {
+		return nil, fmt.Errorf("signature secret or secret_file must be set")
+	}
+	secretFile := sc.SecretFile
+	if secretFile != "" && !filepath.IsAbs(secretFile) {
+		secretFile = filepath.Join(baseDir, secretFile)
+	}
+	return newSigner(sc.Secret, secretFile, sc.Header, sc.Algorithm)
+}
@@ -0,0 +1,181 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// newSignatureServer returns server which verifies
+// the signature of request bodies in the given header
+func newSignatureServer(t *testing.T, header, secret string, hashFn func() hash.Hash) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read body: %s", err)
+			return
+		}
+		mac := hmac.New(hashFn, []byte(secret))
+		_, _ = mac.Write(body)
+		if exp, got := hex.EncodeToString(mac.Sum(nil)), r.Header.Get(header); got != exp {
+			t.Errorf("unexpected %s header %q; expected %q", header, got, exp)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+}
+
+func TestSigner_AlertManager(t *testing.T) {
+	srv := newSignatureServer(t, "X-Signature", "foo", sha256.New)
+	defer srv.Close()
+
+	am := NewAlertManager(srv.URL, "", "", func(Alert) string { return "" }, srv.Client())
+	var err error
+	am.signer, err = newSigner("foo", "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := am.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// targets created from am sign requests with the same secret
+	if err := am.withAddr(srv.URL).Send(context.Background(), []Alert{{Name: "alert1"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestSigner_Webhook(t *testing.T) {
+	srv := newSignatureServer(t, "X-Hook-Signature", "bar", sha512.New)
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, _ = f.WriteString("bar\n")
+	_ = f.Close()
+
+	wn := newTestWebhookNotifier(t, webhookScheme+srv.URL, `{"alerts":{{ len .Alerts }}}`, "")
+	wn.am.signer, err = newSigner("", f.Name(), "x-hook-signature", "sha512")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := wn.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestNewSigner_Failure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signature")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, []byte(" \n"), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	f := func(secret, secretFile, header, algorithm string) {
+		t.Helper()
+		_, err := newSigner(secret, secretFile, header, algorithm)
+		if err == nil {
+			t.Fatalf("expected to get error")
+		}
+		if secret != "" && strings.Contains(err.Error(), secret) {
+			t.Fatalf("error %q mustn't contain the secret", err)
+		}
+	}
+	f("foo", empty, "", "")
+	f("", empty, "", "")
+	f("", filepath.Join(dir, "missing"), "", "")
+	f("foo", "", "", "md5")
+	// header and algorithm require the secret
+	f("", "", "X-Signature", "")
+	f("", "", "", "sha512")
+
+	if s, err := newSigner("", "", "", ""); err != nil || s != nil {
+		t.Fatalf("expected no signer and no error for empty settings; got %v, %v", s, err)
+	}
+}
+
+func TestConfigNotifier_Signature(t *testing.T) {
+	srv := newSignatureServer(t, "X-Signature", "foo", sha256.New)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "signature")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("foo"), 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty"), nil, 0600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	writeConfig := func(data string) string {
+		t.Helper()
+		path := filepath.Join(dir, "config.yml")
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return path
+	}
+
+	// the relative secret_file is resolved against the config dir
+	path := writeConfig(fmt.Sprintf(`
+signature:
+  secret_file: secret
+static_configs:
+- targets: [%s]
+`, strings.TrimPrefix(srv.URL, "http://")))
+	cn, err := newConfigNotifier(path, func(alert Alert) string { return "" })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.refresh(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cn.Send(context.Background(), []Alert{{Name: "alert0"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, data := range []string{`
+signature:
+  secret_file: empty
+static_configs:
+- targets: [localhost:9093]
+`, `
+static_configs:
+- targets: [localhost:9093]
+  signature:
+    header: X-Signature
+`} {
+		if _, err := newConfigNotifier(writeConfig(data), func(alert Alert) string { return "" }); err == nil {
+			t.Fatalf("expected error for config %s", data)
+		}
+	}
+
+	out, err := yaml.Marshal(&Config{Signature: &SignatureConfig{Secret: "foo"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(string(out), "foo") {
+		t.Fatalf("marshaled config mustn't contain the secret:\n%s", out)
+	}
+}
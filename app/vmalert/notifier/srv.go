@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// srvScheme is the prefix of -notifier.url which targets are discovered via DNS SRV records
+const srvScheme = "srv+"
+
+// isSRVAddr returns true if addr must be resolved via DNS SRV records,
+// e.g. srv+http://_web._tcp.alertmanager.monitoring.svc
+func isSRVAddr(addr string) bool {
+	return strings.HasPrefix(addr, srvScheme)
+}
+
+// srvNotifier sends alerts to all targets discovered via DNS SRV records.
+// Targets are re-resolved periodically. The last discovered targets
+// are kept if resolution fails.
+type srvNotifier struct {
+	addr string
+	// name is the SRV record name to resolve
+	name string
+	// tmpl is used as template for the discovered targets
+	tmpl *AlertManager
+
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	targets *discoveredTargets
+}
+
+// newSRVNotifier returns srvNotifier for the given addr with srv+ prefix.
+// The discovered targets inherit the settings of tmpl.
+func newSRVNotifier(addr string, tmpl *AlertManager) (*srvNotifier, error) {
+	u, err := url.Parse(strings.TrimPrefix(addr, srvScheme))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", displayURL(addr), err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q in %q; supported schemes: srv+http, srv+https", srvScheme+u.Scheme, displayURL(addr))
+	}
+	if u.Port() != "" {
+		return nil, fmt.Errorf("port mustn't be set in %q, since it is obtained from SRV records", displayURL(addr))
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("missing SRV record name in %q", displayURL(addr))
+	}
+	return &srvNotifier{
+		addr:      displayURL(addr),
+		name:      u.Hostname(),
+		tmpl:      tmpl,
+		lookupSRV: net.DefaultResolver.LookupSRV,
+		targets:   newDiscoveredTargets(tmpl),
+	}, nil
+}
+
+// Addr returns -notifier.url with srv+ prefix.
+func (sn *srvNotifier) Addr() string { return sn.addr }
+
+// Name returns the optional name set via -notifier.name flag.
+func (sn *srvNotifier) Name() string { return sn.tmpl.Name() }
+
+// Send sends alerts to all discovered targets concurrently.
+func (sn *srvNotifier) Send(ctx context.Context, alerts []Alert) error {
+	return sn.targets.send(ctx, alerts, sn.addr)
+}
+
+// refresh resolves SRV records and updates the targets.
+func (sn *srvNotifier) refresh(ctx context.Context) error {
+	_, records, err := sn.lookupSRV(ctx, "", "", sn.name)
+	if err != nil {
+		return fmt.Errorf("cannot resolve SRV records for %q: %w", sn.addr, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no SRV records found for %q", sn.addr)
+	}
+	u, err := url.Parse(sn.tmpl.baseURL)
+	if err != nil {
+		return fmt.Errorf("cannot parse %q: %w", sn.tmpl.baseURL, err)
+	}
+	targets := make(map[string]*discoveredTarget, len(records))
+	for _, r := range records {
+		u.Host = net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port)))
+		targets[u.String()] = &discoveredTarget{source: TargetSourceDNSSRV}
+	}
+	sn.targets.update(targets, sn.addr)
+	return nil
+}
+
+// run re-resolves SRV records every interval until ctx is cancelled
+func (sn *srvNotifier) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if err := sn.refresh(ctx); err != nil {
+			logger.Warnf("%s; keeping %d previously discovered targets", err, len(sn.targets.get()))
+		}
+	}
+}
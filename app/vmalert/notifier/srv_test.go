@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSRVNotifier(t *testing.T) {
+	var hits [2]int32
+	var records []*net.SRV
+	var urls []string
+	for i := range hits {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != alertManagerPath {
+				t.Errorf("unexpected path %q", r.URL.Path)
+			}
+			atomic.AddInt32(&hits[i], 1)
+		}))
+		defer srv.Close()
+		urls = append(urls, srv.URL)
+		u, _ := url.Parse(srv.URL)
+		port, _ := strconv.Atoi(u.Port())
+		records = append(records, &net.SRV{Target: u.Hostname() + ".", Port: uint16(port)})
+	}
+
+	tmpl := NewAlertManager("http://_web._tcp.alertmanager", "", "", func(alert Alert) string { return "" }, http.DefaultClient)
+	sn, err := newSRVNotifier("srv+http://_web._tcp.alertmanager", tmpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var mu sync.Mutex
+	var lookupErr error
+	current := records
+	sn.lookupSRV = func(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+		if name != "_web._tcp.alertmanager" {
+			t.Errorf("unexpected SRV name %q", name)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return "", current, lookupErr
+	}
+
+	ctx := context.Background()
+	if err := sn.Send(ctx, []Alert{{}}); err == nil {
+		t.Fatalf("expected to get error without discovered targets")
+	}
+	if err := sn.refresh(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := sn.Send(ctx, []Alert{{}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hits[0] != 1 || hits[1] != 1 {
+		t.Fatalf("expected alerts to be sent to all targets; got %v", hits)
+	}
+
+	// alerts are sent only to the targets set via withTargets
+	if err := sn.Send(withTargets(ctx, []string{urls[1]}), []Alert{{}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hits[0] != 1 || hits[1] != 2 {
+		t.Fatalf("expected alerts to be sent to the given target only; got %v", hits)
+	}
+
+	// one of targets is removed
+	mu.Lock()
+	current = records[:1]
+	mu.Unlock()
+	if err := sn.refresh(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := len(sn.targets.get()); n != 1 {
+		t.Fatalf("expected 1 target; got %d", n)
+	}
+
+	// failed resolution keeps previous targets
+	mu.Lock()
+	lookupErr = errors.New("no such host")
+	mu.Unlock()
+	if err := sn.refresh(ctx); err == nil {
+		t.Fatalf("expected to get resolution error")
+	}
+	if err := sn.Send(ctx, []Alert{{}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hits[0] != 2 || hits[1] != 2 {
+		t.Fatalf("expected alerts to be sent to the remaining target only; got %v", hits)
+	}
+}
+
+func TestNewSRVNotifier_Failure(t *testing.T) {
+	f := func(addr string) {
+		t.Helper()
+		if _, err := newSRVNotifier(addr, &AlertManager{}); err == nil {
+			t.Fatalf("expected to get error for %q", addr)
+		}
+	}
+	f("srv+ftp://_web._tcp.alertmanager")
+	f("srv+http://_web._tcp.alertmanager:9093")
+	f("srv+http:///api")
+}
@@ -0,0 +1,136 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// targetStats contains delivery stats of the notifier target
+type targetStats struct {
+	alertsSent       *metrics.Counter
+	alertsSendErrors *metrics.Counter
+	sendDuration     *metrics.Histogram
+
+	mu            sync.Mutex
+	lastSuccessAt time.Time
+	lastError     string
+	lastErrorAt   time.Time
+	// failingSince is the time of the first error
+	// since the last successful send
+	failingSince time.Time
+}
+
+var (
+	statsMu sync.Mutex
+	// stats contains stats of targets by address. Stats are kept
+	// while the target is present on reload or re-discovery,
+	// so the history of delivery isn't lost.
+	stats = make(map[string]*targetStats)
+)
+
+// getTargetStats returns stats for the target with the given addr
+func getTargetStats(addr string) *targetStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	ts, ok := stats[addr]
+	if ok {
+		return ts
+	}
+	ts = &targetStats{
+		alertsSent:       metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_sent_total{addr=%q}`, addr)),
+		alertsSendErrors: metrics.GetOrCreateCounter(fmt.Sprintf(`vmalert_alerts_send_errors_total{addr=%q}`, addr)),
+		sendDuration:     metrics.GetOrCreateHistogram(fmt.Sprintf(`vmalert_alerts_send_duration_seconds{addr=%q}`, addr)),
+	}
+	metrics.GetOrCreateGauge(fmt.Sprintf(`vmalert_alerts_last_successful_send_timestamp_seconds{addr=%q}`, addr), func() float64 {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if ts.lastSuccessAt.IsZero() {
+			return 0
+		}
+		return float64(ts.lastSuccessAt.Unix())
+	})
+	stats[addr] = ts
+	return ts
+}
+
+// unregisterTargetStats removes stats and metrics of the target with the given addr,
+// so metrics of targets which disappeared during re-discovery don't pile up.
+func unregisterTargetStats(addr string) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if _, ok := stats[addr]; !ok {
+		return
+	}
+	delete(stats, addr)
+	for _, name := range []string{
+		"vmalert_alerts_sent_total",
+		"vmalert_alerts_send_errors_total",
+		"vmalert_alerts_send_duration_seconds",
+		"vmalert_alerts_last_successful_send_timestamp_seconds",
+	} {
+		metrics.UnregisterMetric(fmt.Sprintf(`%s{addr=%q}`, name, addr))
+	}
+}
+
+// statsNotifier records delivery stats for every Send of the wrapped Notifier
+type statsNotifier struct {
+	Notifier
+}
+
+// withStats wraps nt with statsNotifier. Notifiers sending alerts
+// to discovered targets are returned as is, since stats are recorded
+// for every discovered target instead.
+func withStats(nt Notifier) Notifier {
+	switch nt.(type) {
+	case *srvNotifier, *configNotifier:
+		return nt
+	}
+	return &statsNotifier{Notifier: nt}
+}
+
+// Send sends alerts to the wrapped Notifier and records the result
+func (sn *statsNotifier) Send(ctx context.Context, alerts []Alert) error {
+	startTime := time.Now()
+	err := sn.Notifier.Send(ctx, alerts)
+	getTargetStats(sn.Addr()).update(len(alerts), startTime, err)
+	return err
+}
+
+// update records the result of sending n alerts started at startTime
+func (ts *targetStats) update(n int, startTime time.Time, err error) {
+	ts.alertsSent.Add(n)
+	ts.sendDuration.UpdateDuration(startTime)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if err != nil {
+		ts.alertsSendErrors.Inc()
+		ts.lastError = err.Error()
+		ts.lastErrorAt = time.Now()
+		if ts.failingSince.IsZero() {
+			ts.failingSince = ts.lastErrorAt
+		}
+		return
+	}
+	ts.lastSuccessAt = time.Now()
+	ts.failingSince = time.Time{}
+}
+
+// setHealth sets the delivery state of t from the stats of its address
+func (t *Target) setHealth() {
+	statsMu.Lock()
+	ts, ok := stats[t.Addr]
+	statsMu.Unlock()
+	if !ok {
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t.LastSuccessAt = ts.lastSuccessAt
+	t.LastError = ts.lastError
+	t.LastErrorAt = ts.lastErrorAt
+	t.FailingSince = ts.failingSince
+}
@@ -0,0 +1,106 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestTargetStats(t *testing.T) {
+	var failing int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	am := NewAlertManager(srv.URL, "", "", func(Alert) string { return "" }, srv.Client())
+	nt := withStats(am)
+	target := Target{Addr: am.Addr()}
+	target.setHealth()
+	if !target.LastSuccessAt.IsZero() || target.LastError != "" {
+		t.Fatalf("expected empty stats before the first send; got %+v", target)
+	}
+
+	alerts := []Alert{{Name: "alert0"}, {Name: "alert1"}}
+	for i := 0; i < 2; i++ {
+		if err := nt.Send(context.Background(), alerts); err == nil {
+			t.Fatalf("expected to get error")
+		}
+	}
+	target.setHealth()
+	if target.LastError == "" || target.FailingSince.IsZero() || !target.LastSuccessAt.IsZero() {
+		t.Fatalf("expected the target to be failing; got %+v", target)
+	}
+	if target.FailingSince.After(target.LastErrorAt) {
+		t.Fatalf("expected failingSince %v to be the first error time; last error at %v", target.FailingSince, target.LastErrorAt)
+	}
+
+	atomic.StoreInt32(&failing, 0)
+	if err := nt.Send(context.Background(), alerts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	target.setHealth()
+	if target.LastSuccessAt.IsZero() || !target.FailingSince.IsZero() {
+		t.Fatalf("expected the target to be healthy; got %+v", target)
+	}
+	if target.LastError == "" {
+		t.Fatalf("expected the last error to be kept after the successful send")
+	}
+
+	ts := getTargetStats(am.Addr())
+	if n := ts.alertsSent.Get(); n != 6 {
+		t.Fatalf("expected 6 sent alerts; got %d", n)
+	}
+	if n := ts.alertsSendErrors.Get(); n != 2 {
+		t.Fatalf("expected 2 send errors; got %d", n)
+	}
+}
+
+func TestTargetStats_Blackhole(t *testing.T) {
+	nt := withStats(&blackholeNotifier{})
+	before := getTargetStats(blackholeAddr).alertsSent.Get()
+	if err := nt.Send(context.Background(), []Alert{{Name: "alert0"}, {Name: "alert1"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := getTargetStats(blackholeAddr).alertsSent.Get() - before; n != 2 {
+		t.Fatalf("expected 2 discarded alerts to be counted; got %d", n)
+	}
+}
+
+func TestTargetStats_Unregister(t *testing.T) {
+	dt := newDiscoveredTargets(NewAlertManager("", "", "", func(Alert) string { return "" }, http.DefaultClient))
+	addr := "http://removed-target:9093"
+	dt.update(map[string]*discoveredTarget{addr: {source: TargetSourceDNSSRV}}, "test")
+	getTargetStats(addr).update(1, time.Now(), nil)
+	name := fmt.Sprintf(`vmalert_alerts_sent_total{addr=%q}`, addr)
+	if !hasMetric(name) {
+		t.Fatalf("expected %s to be registered", name)
+	}
+
+	dt.update(map[string]*discoveredTarget{}, "test")
+	if hasMetric(name) {
+		t.Fatalf("expected %s to be unregistered after the target removal", name)
+	}
+	statsMu.Lock()
+	_, ok := stats[addr]
+	statsMu.Unlock()
+	if ok {
+		t.Fatalf("expected stats of the removed target to be deleted")
+	}
+}
+
+func hasMetric(name string) bool {
+	var bb bytes.Buffer
+	metrics.WritePrometheus(&bb, false)
+	return strings.Contains(bb.String(), name)
+}
@@ -0,0 +1,257 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// Target is a notifier target.
+type Target struct {
+	// Addr is the address where alerts are sent
+	Addr string
+	// Name is the optional name set via -notifier.name flag
+	Name string
+	// Source is the source where the target was obtained from
+	Source string
+	// Labels contains the target labels after relabeling.
+	// Labels are set only for targets discovered via -notifier.config.
+	Labels map[string]string
+
+	// LastSuccessAt is the time of the last successful send.
+	// It is zero if alerts weren't sent to the target yet.
+	LastSuccessAt time.Time
+	// LastError is the error of the last failed send
+	LastError   string
+	LastErrorAt time.Time
+	// FailingSince is the time of the first failed send
+	// since LastSuccessAt. It is zero if the last send succeeded.
+	FailingSince time.Time
+}
+
+// Sources of notifier targets
+const (
+	TargetSourceStatic        = "static"
+	TargetSourceDNSSRV        = "dns_srv"
+	TargetSourceStaticConfigs = "static_configs"
+	TargetSourceConsul        = "consul_sd_configs"
+	TargetSourceBlackhole     = "blackhole"
+	TargetSourceWebhook       = "webhook"
+	targetSourceUnknown       = "unknown"
+)
+
+var (
+	initializedMu sync.Mutex
+	// initialized contains notifiers created by Init
+	// before wrapping them with stats, queue, relabeling and retries
+	initialized []Notifier
+)
+
+// GetTargets returns the targets of notifiers created by Init.
+func GetTargets() []Target {
+	initializedMu.Lock()
+	nts := append([]Notifier{}, initialized...)
+	initializedMu.Unlock()
+
+	var targets []Target
+	for _, nt := range nts {
+		switch v := nt.(type) {
+		case *AlertManager:
+			targets = append(targets, Target{Addr: v.Addr(), Name: v.Name(), Source: TargetSourceStatic})
+		case *srvNotifier:
+			targets = append(targets, v.targets.list()...)
+		case *configNotifier:
+			targets = append(targets, v.getState().targets.list()...)
+		case *webhookNotifier:
+			targets = append(targets, Target{Addr: v.Addr(), Name: v.Name(), Source: TargetSourceWebhook})
+		case *blackholeNotifier:
+			targets = append(targets, Target{Addr: v.Addr(), Source: TargetSourceBlackhole})
+		default:
+			targets = append(targets, Target{Addr: nt.Addr(), Name: nt.Name(), Source: targetSourceUnknown})
+		}
+	}
+	for i := range targets {
+		targets[i].setHealth()
+	}
+	return targets
+}
+
+// discoveredTargets contains AlertManagers for the discovered addresses.
+// The AlertManagers inherit settings of tmpl.
+type discoveredTargets struct {
+	tmpl *AlertManager
+
+	mu      sync.Mutex
+	targets map[string]*discoveredTarget
+}
+
+type discoveredTarget struct {
+	am *AlertManager
+	// tmpl overrides discoveredTargets.tmpl for the target if set
+	tmpl   *AlertManager
+	source string
+	labels map[string]string
+}
+
+func newDiscoveredTargets(tmpl *AlertManager) *discoveredTargets {
+	return &discoveredTargets{
+		tmpl:    tmpl,
+		targets: make(map[string]*discoveredTarget),
+	}
+}
+
+// get returns the discovered targets sorted by address
+func (dt *discoveredTargets) get() []*discoveredTarget {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	targets := make([]*discoveredTarget, 0, len(dt.targets))
+	for _, t := range dt.targets {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].am.Addr() < targets[j].am.Addr()
+	})
+	return targets
+}
+
+// has returns true if the target with the given addr is discovered
+func (dt *discoveredTargets) has(addr string) bool {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	_, ok := dt.targets[addr]
+	return ok
+}
+
+func (dt *discoveredTargets) list() []Target {
+	var res []Target
+	for _, t := range dt.get() {
+		res = append(res, Target{
+			Addr:   t.am.Addr(),
+			Name:   t.am.Name(),
+			Source: t.source,
+			Labels: t.labels,
+		})
+	}
+	return res
+}
+
+// update replaces the targets with the given ones.
+// Targets which are still present are preserved,
+// so they keep detected API version and auth state.
+// Targets with already set AlertManager keep it.
+func (dt *discoveredTargets) update(targets map[string]*discoveredTarget, from string) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	for addr := range dt.targets {
+		if _, ok := targets[addr]; !ok {
+			logger.Infof("removing target %q discovered for %q", addr, from)
+			delete(dt.targets, addr)
+			unregisterTargetStats(addr)
+		}
+	}
+	for addr, t := range targets {
+		if prev, ok := dt.targets[addr]; ok {
+			// labels and source may change without changing the address
+			t.am = prev.am
+		} else if t.am == nil {
+			logger.Infof("adding target %q discovered for %q", addr, from)
+			tmpl := t.tmpl
+			if tmpl == nil {
+				tmpl = dt.tmpl
+			}
+			t.am = tmpl.withAddr(addr)
+		}
+		dt.targets[addr] = t
+	}
+}
+
+// targetsError is returned by notifiers with discovered targets
+// if alerts weren't sent to some of the targets.
+// It contains addresses of the failed targets, so the alerts
+// are retried only for them.
+type targetsError struct {
+	failed []string
+	err    error
+}
+
+func (e *targetsError) Error() string { return e.err.Error() }
+
+func (e *targetsError) Unwrap() error { return e.err }
+
+type targetsCtxKey struct{}
+
+// withTargets returns ctx which limits sending alerts
+// to the discovered targets with the given addresses
+func withTargets(ctx context.Context, addrs []string) context.Context {
+	return context.WithValue(ctx, targetsCtxKey{}, addrs)
+}
+
+// targetsFromContext returns addresses set via withTargets
+// or nil if alerts must be sent to all the targets
+func targetsFromContext(ctx context.Context) []string {
+	addrs, _ := ctx.Value(targetsCtxKey{}).([]string)
+	return addrs
+}
+
+// send sends alerts to all the targets concurrently.
+// If ctx is limited via withTargets, alerts are sent only to the given targets.
+// targetsError is returned if alerts weren't sent to some of the targets.
+func (dt *discoveredTargets) send(ctx context.Context, alerts []Alert, from string) error {
+	targets := dt.get()
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets discovered for %q", from)
+	}
+	if addrs := targetsFromContext(ctx); addrs != nil {
+		targets = filterTargets(targets, addrs)
+		if len(targets) == 0 {
+			// the targets disappeared, so there is nothing to send
+			return nil
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(targets))
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, am *AlertManager) {
+			defer wg.Done()
+			errs[i] = withStats(am).Send(ctx, alerts)
+		}(i, t.am)
+	}
+	wg.Wait()
+
+	var errStrs, failed []string
+	for i, err := range errs {
+		if err != nil {
+			errStrs = append(errStrs, err.Error())
+			failed = append(failed, targets[i].am.Addr())
+		}
+	}
+	if len(errStrs) > 0 {
+		return &targetsError{
+			failed: failed,
+			err: fmt.Errorf("failed to send alerts to %d out of %d targets discovered for %q: %s",
+				len(errStrs), len(targets), from, strings.Join(errStrs, "; ")),
+		}
+	}
+	return nil
+}
+
+// filterTargets returns targets with the given addresses
+func filterTargets(targets []*discoveredTarget, addrs []string) []*discoveredTarget {
+	m := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		m[addr] = struct{}{}
+	}
+	var res []*discoveredTarget
+	for _, t := range targets {
+		if _, ok := m[t.am.Addr()]; ok {
+			res = append(res, t)
+		}
+	}
+	return res
+}
@@ -14,6 +14,7 @@
 package notifier
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -226,6 +227,18 @@ func InitTemplateFunc(externalURL *url.URL) {
 			return strings.Replace(q, "\r", `\r`, -1)
 		},
 
+		// jsonEscape converts the string to a JSON-encoded string
+		// including the surrounding quotes, so it can be safely
+		// placed inside JSON documents.
+		// For example, {"expr":{{ $expr | jsonEscape }}}
+		"jsonEscape": func(s string) (string, error) {
+			b, err := json.Marshal(s)
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		},
+
 		// quotesEscape escapes quote char
 		"quotesEscape": func(q string) string {
 			return strings.Replace(q, `"`, `\"`, -1)
@@ -0,0 +1,167 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	textTpl "text/template"
+	"time"
+)
+
+// webhookScheme is the prefix of -notifier.url which alerts
+// are sent to via webhookNotifier instead of Alertmanager API
+const webhookScheme = "webhook+"
+
+// isWebhookAddr returns true if addr must be notified via webhook,
+// e.g. webhook+https://incidents.example.com/api/alerts
+func isWebhookAddr(addr string) bool {
+	return strings.HasPrefix(addr, webhookScheme)
+}
+
+// WebhookData is passed to the webhook template
+type WebhookData struct {
+	Alerts []WebhookAlert
+}
+
+// WebhookAlert represents Alert in the webhook template
+type WebhookAlert struct {
+	GroupID     uint64
+	ID          uint64
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	// State is one of firing, pending or inactive.
+	// Resolved alerts have inactive state.
+	State    string
+	Value    float64
+	Expr     string
+	StartsAt time.Time
+	// EndsAt is zero for firing alerts without
+	// the resolve timeout set
+	EndsAt       time.Time
+	GeneratorURL string
+}
+
+// webhookNotifier sends alerts via HTTP POST request
+// with the body rendered from the user-defined template
+type webhookNotifier struct {
+	addr string
+	// am is used for sending requests with TLS and auth
+	// settings configured via -notifier.* flags
+	am          *AlertManager
+	url         string
+	tmpl        *textTpl.Template
+	contentType string
+}
+
+// newWebhookNotifier returns webhookNotifier for the given addr with webhook+ prefix.
+// The template is read from tmplFile and is validated on creation.
+func newWebhookNotifier(addr string, am *AlertManager, tmplFile, contentType string) (*webhookNotifier, error) {
+	if tmplFile == "" {
+		return nil, fmt.Errorf("-notifier.webhook.templateFile must be set for %q", displayURL(addr))
+	}
+	data, err := ioutil.ReadFile(tmplFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read webhook template: %w", err)
+	}
+	tmpl, err := textTpl.New(tmplFile).Option("missingkey=zero").Funcs(webhookFuncs()).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse webhook template %q: %w", tmplFile, err)
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return &webhookNotifier{
+		addr:        displayURL(addr),
+		am:          am,
+		url:         am.baseURL,
+		tmpl:        tmpl,
+		contentType: contentType,
+	}, nil
+}
+
+// webhookFuncs returns template functions available in webhook templates
+func webhookFuncs() textTpl.FuncMap {
+	fm := make(textTpl.FuncMap, len(tmplFunc)+1)
+	for k, fn := range tmplFunc {
+		fm[k] = fn
+	}
+	// toJson encodes the given value to JSON,
+	// e.g. {"labels":{{ .Labels | toJson }}}
+	fm["toJson"] = func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return fm
+}
+
+// Addr returns -notifier.url with webhook+ prefix.
+func (wn *webhookNotifier) Addr() string { return wn.addr }
+
+// Name returns the optional name set via -notifier.name flag.
+func (wn *webhookNotifier) Name() string { return wn.am.Name() }
+
+// Send renders alerts via template and sends them to webhook.
+// Alerts aren't sent if template fails to render.
+func (wn *webhookNotifier) Send(ctx context.Context, alerts []Alert) error {
+	ctx, cancel := wn.am.withTimeout(ctx)
+	defer cancel()
+	data := WebhookData{Alerts: make([]WebhookAlert, 0, len(alerts))}
+	for _, a := range alerts {
+		data.Alerts = append(data.Alerts, WebhookAlert{
+			GroupID:      a.GroupID,
+			ID:           a.ID,
+			Name:         a.Name,
+			Labels:       a.Labels,
+			Annotations:  a.Annotations,
+			State:        a.State.String(),
+			Value:        a.Value,
+			Expr:         a.Expr,
+			StartsAt:     a.Start,
+			EndsAt:       a.End,
+			GeneratorURL: wn.am.argFunc(a),
+		})
+	}
+	var b bytes.Buffer
+	if err := wn.tmpl.Execute(&b, data); err != nil {
+		return fmt.Errorf("cannot render webhook template for %q: %w", wn.addr, err)
+	}
+
+	resp, err := wn.send(ctx, b.Bytes())
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && wn.am.refreshToken() {
+		_ = resp.Body.Close()
+		resp, err = wn.send(ctx, b.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response from %q: %w", wn.addr, err)
+		}
+		return fmt.Errorf("invalid SC %d from %q; response body: %s", resp.StatusCode, wn.addr, string(body))
+	}
+	return nil
+}
+
+func (wn *webhookNotifier) send(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := wn.am.newRequest(ctx, http.MethodPost, wn.url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", wn.contentType)
+	return wn.am.client.Do(req)
+}
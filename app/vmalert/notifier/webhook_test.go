@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+)
+
+func newTestWebhookNotifier(t *testing.T, addr, tmpl, headers string) *webhookNotifier {
+	t.Helper()
+	f, err := ioutil.TempFile("", "webhook")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { _ = os.Remove(f.Name()) })
+	if _, err := f.WriteString(tmpl); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_ = f.Close()
+
+	am := NewAlertManager(strings.TrimPrefix(addr, webhookScheme), "foo", "bar", func(a Alert) string {
+		return "http://vmalert/" + a.Name
+	}, http.DefaultClient)
+	am.headers, err = utils.ParseHeaders(headers)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wn, err := newWebhookNotifier(addr, am, f.Name(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return wn
+}
+
+func TestWebhookNotifier_Send(t *testing.T) {
+	type payload struct {
+		Count  int `json:"count"`
+		Alerts []struct {
+			Name         string            `json:"name"`
+			State        string            `json:"state"`
+			Labels       map[string]string `json:"labels"`
+			StartsAt     int64             `json:"startsAt"`
+			GeneratorURL string            `json:"url"`
+		} `json:"alerts"`
+	}
+	var got payload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hook" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected Content-Type %q", ct)
+		}
+		if h := r.Header.Get("X-Team"); h != "infra" {
+			t.Errorf("unexpected X-Team header %q", h)
+		}
+		user, pass, _ := r.BasicAuth()
+		if user != "foo" || pass != "bar" {
+			t.Errorf("wrong creds %q:%q; expected %q:%q", user, pass, "foo", "bar")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("cannot decode body: %s", err)
+		}
+	}))
+	defer srv.Close()
+
+	tmpl := `{"count":{{ len .Alerts }},"alerts":[
+{{- range $i, $a := .Alerts }}{{ if $i }},{{ end }}
+{"name":{{ $a.Name | toJson }},"state":"{{ $a.State }}","labels":{{ $a.Labels | toJson }},"startsAt":{{ $a.StartsAt.Unix }},"url":{{ $a.GeneratorURL | toJson }}}
+{{- end }}]}`
+	wn := newTestWebhookNotifier(t, webhookScheme+srv.URL+"/hook", tmpl, "X-Team: infra")
+	if !strings.HasPrefix(wn.Addr(), webhookScheme) {
+		t.Fatalf("expected %q prefix in %q", webhookScheme, wn.Addr())
+	}
+	start := time.Unix(1000, 0)
+	alerts := []Alert{
+		{Name: "alert0", State: StateFiring, Labels: map[string]string{"job": "foo"}, Start: start},
+		{Name: `alert"1`, State: StateInactive, Start: start},
+	}
+	if err := wn.Send(context.Background(), alerts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Count != 2 || len(got.Alerts) != 2 {
+		t.Fatalf("expected 2 alerts; got %+v", got)
+	}
+	a := got.Alerts[0]
+	if a.Name != "alert0" || a.State != "firing" || a.Labels["job"] != "foo" || a.StartsAt != 1000 || a.GeneratorURL != "http://vmalert/alert0" {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+	if a := got.Alerts[1]; a.Name != `alert"1` || a.State != "inactive" {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+}
+
+func TestWebhookNotifier_SendError(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	// rendering fails only for alerts without labels
+	wn := newTestWebhookNotifier(t, webhookScheme+srv.URL, `{{ range .Alerts }}{{ if .Labels }}ok{{ else }}{{ .Name.Foo }}{{ end }}{{ end }}`, "")
+	if err := wn.Send(context.Background(), []Alert{{Name: "alert0"}}); err == nil {
+		t.Fatalf("expected to get render error")
+	}
+	if calls != 0 {
+		t.Fatalf("expected no requests on render error; got %d", calls)
+	}
+	if err := wn.Send(context.Background(), []Alert{{Name: "alert0", Labels: map[string]string{"job": "foo"}}}); err == nil {
+		t.Fatalf("expected to get error on 400 response")
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 request; got %d", calls)
+	}
+}
+
+func TestNewWebhookNotifier_Failure(t *testing.T) {
+	am := NewAlertManager("http://localhost", "", "", nil, nil)
+	if _, err := newWebhookNotifier(webhookScheme+"http://localhost", am, "", ""); err == nil {
+		t.Fatalf("expected error on missing template file")
+	}
+	if _, err := newWebhookNotifier(webhookScheme+"http://localhost", am, "non-existing", ""); err == nil {
+		t.Fatalf("expected error on non-existing template file")
+	}
+	f, err := ioutil.TempFile("", "webhook")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, _ = f.WriteString(`{{ .Alerts `)
+	_ = f.Close()
+	if _, err := newWebhookNotifier(webhookScheme+"http://localhost", am, f.Name(), ""); err == nil {
+		t.Fatalf("expected error on invalid template")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+)
+
+// queryStatsWindow is the period for which
+// the most expensive queries are tracked
+const queryStatsWindow = time.Hour
+
+// queryStatsBuckets is the number of buckets queryStatsWindow is split to.
+// Stats are rotated per bucket, so the tracked period varies
+// from queryStatsWindow-queryStatsWindow/queryStatsBuckets to queryStatsWindow.
+const queryStatsBuckets = 6
+
+// defaultQueryStatsTopN is the default number of queries
+// returned by /api/v1/datasource/stats
+const defaultQueryStatsTopN = 20
+
+// queryStatsTracker aggregates stats of datasource queries
+// per group and expression within queryStatsWindow
+type queryStatsTracker struct {
+	mu      sync.Mutex
+	buckets [queryStatsBuckets]queryStatsBucket
+}
+
+type queryStatsBucket struct {
+	start time.Time
+	stats map[queryStatsKey]*APIQueryStats
+}
+
+type queryStatsKey struct {
+	group string
+	file  string
+	query string
+}
+
+// queryStats contains stats of all the datasource queries
+var queryStats = &queryStatsTracker{}
+
+func (qst *queryStatsTracker) record(group, file string, qs datasource.QueryStats, now time.Time) {
+	bucketDuration := queryStatsWindow / queryStatsBuckets
+	start := now.Truncate(bucketDuration)
+	b := &qst.buckets[(start.UnixNano()/int64(bucketDuration))%queryStatsBuckets]
+	k := queryStatsKey{group: group, file: file, query: qs.Query}
+
+	qst.mu.Lock()
+	defer qst.mu.Unlock()
+	if !b.start.Equal(start) {
+		// the bucket contains stats from the previous window
+		b.start = start
+		b.stats = make(map[queryStatsKey]*APIQueryStats)
+	}
+	s := b.stats[k]
+	if s == nil {
+		s = &APIQueryStats{Group: group, File: file, Query: qs.Query}
+		b.stats[k] = s
+	}
+	s.add(qs)
+}
+
+// top returns n queries with the highest total duration within queryStatsWindow
+func (qst *queryStatsTracker) top(n int, now time.Time) []APIQueryStats {
+	merged := make(map[queryStatsKey]*APIQueryStats)
+	qst.mu.Lock()
+	for i := range qst.buckets {
+		b := &qst.buckets[i]
+		if now.Sub(b.start) >= queryStatsWindow {
+			continue
+		}
+		for k, s := range b.stats {
+			m := merged[k]
+			if m == nil {
+				m = &APIQueryStats{Group: s.Group, File: s.File, Query: s.Query}
+				merged[k] = m
+			}
+			m.merge(s)
+		}
+	}
+	qst.mu.Unlock()
+
+	res := make([]APIQueryStats, 0, len(merged))
+	for _, s := range merged {
+		res = append(res, *s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].TotalDurationSeconds != res[j].TotalDurationSeconds {
+			return res[i].TotalDurationSeconds > res[j].TotalDurationSeconds
+		}
+		return res[i].Query < res[j].Query
+	})
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+func (s *APIQueryStats) add(qs datasource.QueryStats) {
+	d := qs.Duration.Seconds()
+	s.Count++
+	s.TotalDurationSeconds += d
+	if d > s.MaxDurationSeconds {
+		s.MaxDurationSeconds = d
+	}
+	s.TotalResponseBytes += qs.ResponseBytes
+	s.TotalSeries += int64(qs.Series)
+}
+
+func (s *APIQueryStats) merge(src *APIQueryStats) {
+	s.Count += src.Count
+	s.TotalDurationSeconds += src.TotalDurationSeconds
+	if src.MaxDurationSeconds > s.MaxDurationSeconds {
+		s.MaxDurationSeconds = src.MaxDurationSeconds
+	}
+	s.TotalResponseBytes += src.TotalResponseBytes
+	s.TotalSeries += src.TotalSeries
+}
+
+// onQuery returns the function for recording
+// stats of datasource queries made by group rules
+func (g *Group) onQuery() func(datasource.QueryStats) {
+	name, file, m := g.Name, g.File, g.metrics
+	return func(qs datasource.QueryStats) {
+		if m != nil {
+			m.queryDuration.Update(qs.Duration.Seconds())
+			m.queryResponseBytes.Add(int(qs.ResponseBytes))
+			m.querySeries.Add(qs.Series)
+		}
+		queryStats.record(name, file, qs, time.Now())
+	}
+}
+
+type listQueryStatsResponse struct {
+	Data struct {
+		Queries []APIQueryStats `json:"queries"`
+	} `json:"data"`
+	Status string `json:"status"`
+}
+
+// listQueryStats returns JSON with the most expensive datasource queries.
+// The number of returned queries is set via `topN` query arg.
+func listQueryStats(r *http.Request) ([]byte, error) {
+	n := defaultQueryStatsTopN
+	if v := r.FormValue("topN"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, errResponse(fmt.Errorf("topN must be a positive integer; got %q", v), http.StatusBadRequest)
+		}
+	}
+	lr := listQueryStatsResponse{Status: "success"}
+	lr.Data.Queries = queryStats.top(n, time.Now())
+	b, err := json.Marshal(lr)
+	if err != nil {
+		return nil, errResponse(fmt.Errorf("error encoding queries stats: %w", err), http.StatusInternalServerError)
+	}
+	return b, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+)
+
+func TestQueryStatsTracker(t *testing.T) {
+	qst := &queryStatsTracker{}
+	now := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	qst.record("group", "file", datasource.QueryStats{Query: "up", Duration: time.Second, ResponseBytes: 100, Series: 2}, now)
+	qst.record("group", "file", datasource.QueryStats{Query: "up", Duration: 3 * time.Second, ResponseBytes: 100, Series: 2}, now.Add(20*time.Minute))
+	qst.record("group", "file", datasource.QueryStats{Query: "vector(1)", Duration: 2 * time.Second, ResponseBytes: 50, Series: 1}, now.Add(30*time.Minute))
+	qst.record("other", "file", datasource.QueryStats{Query: "up", Duration: time.Millisecond, ResponseBytes: 10, Series: 1}, now.Add(30*time.Minute))
+
+	top := qst.top(0, now.Add(40*time.Minute))
+	if len(top) != 3 {
+		t.Fatalf("expected to get 3 queries; got %d: %+v", len(top), top)
+	}
+	exp := APIQueryStats{
+		Group:                "group",
+		File:                 "file",
+		Query:                "up",
+		Count:                2,
+		TotalDurationSeconds: 4,
+		MaxDurationSeconds:   3,
+		TotalResponseBytes:   200,
+		TotalSeries:          4,
+	}
+	if top[0] != exp {
+		t.Fatalf("expected to get %+v; got %+v", exp, top[0])
+	}
+	if top[1].Query != "vector(1)" || top[2].Group != "other" {
+		t.Fatalf("unexpected order of queries: %+v", top)
+	}
+	if top := qst.top(1, now.Add(40*time.Minute)); len(top) != 1 {
+		t.Fatalf("expected to get 1 query; got %d", len(top))
+	}
+
+	// stats older than the window are ignored
+	top = qst.top(0, now.Add(time.Hour+10*time.Minute))
+	if len(top) != 3 || top[0].Query != "up" || top[0].Count != 1 {
+		t.Fatalf("unexpected stats after the window has passed: %+v", top)
+	}
+	// buckets are reused for the new window
+	qst.record("group", "file", datasource.QueryStats{Query: "up", Duration: time.Second}, now.Add(time.Hour))
+	top = qst.top(0, now.Add(time.Hour))
+	// the reused bucket mustn't contain stats from the previous window
+	if top[0].Query != "up" || top[0].Count != 2 || top[0].TotalResponseBytes != 100 {
+		t.Fatalf("unexpected stats after the bucket was reused: %+v", top)
+	}
+}
+
+func TestListQueryStats(t *testing.T) {
+	if _, err := listQueryStats(httptest.NewRequest("GET", "/api/v1/datasource/stats?topN=foo", nil)); err == nil {
+		t.Fatalf("expected to get error for invalid topN")
+	}
+	if _, err := listQueryStats(httptest.NewRequest("GET", "/api/v1/datasource/stats?topN=0", nil)); err == nil {
+		t.Fatalf("expected to get error for zero topN")
+	}
+	if _, err := listQueryStats(httptest.NewRequest("GET", "/api/v1/datasource/stats?topN=5", nil)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/metrics"
 )
@@ -24,6 +25,16 @@ type RecordingRule struct {
 	Expr    string
 	Labels  map[string]string
 	GroupID uint64
+	// Timeout limits the duration of the rule's query
+	Timeout time.Duration
+	// NoData defines the behavior on empty query results
+	// and is one of config.NoData* values
+	NoData string
+	// HealthErrorsThreshold is the number of consecutive failed
+	// evaluations after which the rule is marked unhealthy
+	HealthErrorsThreshold int
+	// Debug enables logging for the rule
+	Debug bool
 
 	q datasource.Querier
 
@@ -38,6 +49,13 @@ type RecordingRule struct {
 	// stores the number of samples returned during
 	// the last evaluation
 	lastExecSamples int
+	// stores the duration of the last evaluation
+	lastExecDuration time.Duration
+	// stores the number of consecutive failed evaluations
+	// resets on every successful Exec
+	lastExecFailures int
+	// stores the history of the last evaluations
+	state *ruleState
 
 	metrics *recordingRuleMetrics
 }
@@ -60,18 +78,19 @@ func (rr *RecordingRule) ID() uint64 {
 
 func newRecordingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rule) *RecordingRule {
 	rr := &RecordingRule{
-		Type:    cfg.Type,
-		RuleID:  cfg.ID,
-		Name:    cfg.Record,
-		Expr:    cfg.Expr,
-		Labels:  cfg.Labels,
-		GroupID: group.ID(),
-		metrics: &recordingRuleMetrics{},
-		q: qb.BuildWithParams(datasource.QuerierParams{
-			DataSourceType:     &cfg.Type,
-			EvaluationInterval: group.Interval,
-			ExtraLabels:        group.ExtraFilterLabels,
-		}),
+		Type:                  cfg.Type,
+		RuleID:                ruleID(group.ID(), cfg),
+		Name:                  cfg.Record,
+		Expr:                  cfg.Expr,
+		Labels:                cfg.Labels,
+		GroupID:               group.ID(),
+		Timeout:               getRuleTimeout(cfg),
+		NoData:                cfg.NoData,
+		HealthErrorsThreshold: getHealthErrorsThreshold(cfg),
+		Debug:                 cfg.Debug,
+		state:                 newRuleStateFromConfig(cfg),
+		metrics:               &recordingRuleMetrics{},
+		q:                     qb.BuildWithParams(ruleQuerierParams(group, cfg)),
 	}
 
 	labels := fmt.Sprintf(`recording=%q, group=%q, id="%d"`, rr.Name, group.Name, rr.ID())
@@ -79,7 +98,7 @@ func newRecordingRule(qb datasource.QuerierBuilder, group *Group, cfg config.Rul
 		func() float64 {
 			rr.mu.RLock()
 			defer rr.mu.RUnlock()
-			if rr.lastExecError == nil {
+			if rr.health() == ruleHealthOK {
 				return 0
 			}
 			return 1
@@ -122,17 +141,51 @@ func (rr *RecordingRule) ExecRange(ctx context.Context, start, end time.Time) ([
 }
 
 // Exec executes RecordingRule expression via the given Querier.
-func (rr *RecordingRule) Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, error) {
-	qMetrics, err := rr.q.Query(ctx, rr.Expr)
+func (rr *RecordingRule) Exec(ctx context.Context, ts time.Time, limit int) ([]prompbmarshal.TimeSeries, error) {
+	start := time.Now()
+	var qt datasource.QueryTrace
+	qMetrics, err := queryWithTimeout(datasource.WithQueryTrace(ctx, &qt), rr.q, rr.Expr, ts, rr.Timeout)
 	rr.mu.Lock()
 	defer rr.mu.Unlock()
+	defer func() {
+		if rr.lastExecError != nil {
+			rr.lastExecFailures++
+		} else {
+			rr.lastExecFailures = 0
+		}
+		rr.lastExecDuration = time.Since(start)
+		rr.state.add(ruleStateEntry{
+			time:     ts,
+			duration: rr.lastExecDuration,
+			samples:  rr.lastExecSamples,
+			err:      rr.lastExecError,
+			traceID:  datasource.TraceIDFromContext(ctx),
+			trace:    qt.Data,
+		})
+	}()
 
-	rr.lastExecTime = time.Now()
+	rr.lastExecTime = ts
 	rr.lastExecError = err
 	rr.lastExecSamples = len(qMetrics)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query %q: %w", rr.Expr, err)
 	}
+	if rr.Debug {
+		logger.Infof("DEBUG recording rule %q (%d) at %v: query returned %d series",
+			rr.Name, rr.RuleID, rr.lastExecTime.Format(time.RFC3339), len(qMetrics))
+		for _, m := range qMetrics {
+			logger.Infof("DEBUG recording rule %q (%d): series %s returned value %v",
+				rr.Name, rr.RuleID, labelsToString(m.Labels), m.Values)
+		}
+	}
+	if limit > 0 && len(qMetrics) > limit {
+		rr.lastExecError = fmt.Errorf("%w of %d with %d series", errLimitExceeded, limit, len(qMetrics))
+		return nil, rr.lastExecError
+	}
+	if len(qMetrics) == 0 && rr.NoData == config.NoDataError {
+		rr.lastExecError = errNoData
+		return nil, rr.lastExecError
+	}
 
 	duplicates := make(map[string]struct{}, len(qMetrics))
 	var tss []prompbmarshal.TimeSeries
@@ -189,10 +242,31 @@ func (rr *RecordingRule) UpdateWith(r Rule) error {
 	}
 	rr.Expr = nr.Expr
 	rr.Labels = nr.Labels
+	rr.Timeout = nr.Timeout
+	rr.NoData = nr.NoData
+	rr.HealthErrorsThreshold = nr.HealthErrorsThreshold
+	rr.Debug = nr.Debug
+	if rr.state.size() != nr.state.size() {
+		rr.mu.Lock()
+		rr.state = rr.state.resize(nr.state.size())
+		rr.mu.Unlock()
+	}
 	rr.q = nr.q
 	return nil
 }
 
+// Healthy implements Rule interface
+func (rr *RecordingRule) Healthy() bool {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	return rr.health() == ruleHealthOK
+}
+
+// health must be called under rr.mu lock
+func (rr *RecordingRule) health() string {
+	return ruleHealth(rr.lastExecError, rr.lastExecFailures, rr.HealthErrorsThreshold)
+}
+
 // RuleAPI returns Rule representation in form
 // of APIRecordingRule
 func (rr *RecordingRule) RuleAPI() APIRecordingRule {
@@ -202,14 +276,33 @@ func (rr *RecordingRule) RuleAPI() APIRecordingRule {
 	}
 	return APIRecordingRule{
 		// encode as strings to avoid rounding
-		ID:          fmt.Sprintf("%d", rr.ID()),
-		GroupID:     fmt.Sprintf("%d", rr.GroupID),
-		Name:        rr.Name,
-		Type:        rr.Type.String(),
-		Expression:  rr.Expr,
-		LastError:   lastErr,
-		LastSamples: rr.lastExecSamples,
-		LastExec:    rr.lastExecTime,
-		Labels:      rr.Labels,
+		ID:           fmt.Sprintf("%d", rr.ID()),
+		GroupID:      fmt.Sprintf("%d", rr.GroupID),
+		Name:         rr.Name,
+		Type:         rr.Type.String(),
+		Expression:   rr.Expr,
+		LastError:    lastErr,
+		LastFailures: rr.lastExecFailures,
+		Health:       rr.health(),
+		LastSamples:  rr.lastExecSamples,
+		LastExec:     rr.lastExecTime,
+		LastDuration: rr.lastExecDuration.Seconds(),
+		Timeout:      durationToString(rr.Timeout),
+		Labels:       rr.Labels,
+	}
+}
+
+// RuleDetailsAPI generates APIRuleDetails object from recording rule
+func (rr *RecordingRule) RuleDetailsAPI() APIRuleDetails {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	return APIRuleDetails{
+		ID:         fmt.Sprintf("%d", rr.ID()),
+		GroupID:    fmt.Sprintf("%d", rr.GroupID),
+		Name:       rr.Name,
+		Type:       rr.Type.String(),
+		Expression: rr.Expr,
+		MaxUpdates: rr.state.size(),
+		Updates:    rr.state.updatesAPI(),
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 )
@@ -77,7 +78,7 @@ func TestRecoridngRule_Exec(t *testing.T) {
 			fq := &fakeQuerier{}
 			fq.add(tc.metrics...)
 			tc.rule.q = fq
-			tss, err := tc.rule.Exec(context.TODO())
+			tss, err := tc.rule.Exec(context.TODO(), time.Now(), 0)
 			if err != nil {
 				t.Fatalf("unexpected Exec err: %s", err)
 			}
@@ -178,7 +179,7 @@ func TestRecoridngRule_ExecNegative(t *testing.T) {
 	expErr := "connection reset by peer"
 	fq.setErr(errors.New(expErr))
 	rr.q = fq
-	_, err := rr.Exec(context.TODO())
+	_, err := rr.Exec(context.TODO(), time.Now(), 0)
 	if err == nil {
 		t.Fatalf("expected to get err; got nil")
 	}
@@ -193,11 +194,58 @@ func TestRecoridngRule_ExecNegative(t *testing.T) {
 	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "foo"))
 	fq.add(metricWithValueAndLabels(t, 2, "__name__", "foo", "job", "bar"))
 
-	_, err = rr.Exec(context.TODO())
+	_, err = rr.Exec(context.TODO(), time.Now(), 0)
 	if err == nil {
 		t.Fatalf("expected to get err; got nil")
 	}
 	if !strings.Contains(err.Error(), errDuplicate.Error()) {
 		t.Fatalf("expected to get err %q; got %q insterad", errDuplicate, err)
 	}
+	if got := rr.RuleAPI().LastFailures; got != 2 {
+		t.Fatalf("expected to have 2 consecutive failures; got %d", got)
+	}
+
+	fq.reset()
+	if _, err := rr.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if api := rr.RuleAPI(); api.LastError != "" || api.LastFailures != 0 {
+		t.Fatalf("expected error to be cleared after successful evaluation; got %q and %d failures",
+			api.LastError, api.LastFailures)
+	}
+}
+
+func TestRecoridngRule_Limit(t *testing.T) {
+	rr := &RecordingRule{Name: "job:foo"}
+	fq := &fakeQuerier{}
+	fq.add(metricWithValueAndLabels(t, 1, "__name__", "foo", "job", "foo"))
+	fq.add(metricWithValueAndLabels(t, 2, "__name__", "foo", "job", "bar"))
+	rr.q = fq
+
+	tss, err := rr.Exec(context.TODO(), time.Now(), 2)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if len(tss) != 2 {
+		t.Fatalf("expected to get 2 time series; got %d", len(tss))
+	}
+
+	tss, err = rr.Exec(context.TODO(), time.Now(), 1)
+	if !errors.Is(err, errLimitExceeded) {
+		t.Fatalf("expected to have %s error; got %s", errLimitExceeded, err)
+	}
+	if len(tss) != 0 {
+		t.Fatalf("expected to get no time series; got %d", len(tss))
+	}
+}
+
+func TestRecoridngRule_NoData(t *testing.T) {
+	rr := &RecordingRule{Name: "job:foo", q: &fakeQuerier{}}
+	if _, err := rr.Exec(context.TODO(), time.Now(), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	rr.NoData = config.NoDataError
+	if _, err := rr.Exec(context.TODO(), time.Now(), 0); !errors.Is(err, errNoData) {
+		t.Fatalf("expected to get %s error; got %v", errNoData, err)
+	}
 }
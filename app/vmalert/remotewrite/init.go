@@ -4,6 +4,8 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/url"
+	"path"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
@@ -34,17 +36,36 @@ var (
 // Init creates Client object from given flags.
 // Returns nil if addr flag wasn't set.
 func Init(ctx context.Context) (*Client, error) {
+	return InitWithTenant(ctx, "")
+}
+
+// InitWithTenant creates Client object from given flags
+// which writes data to `/insert/<tenant>/prometheus` path
+// of the vminsert configured via -remoteWrite.url.
+// Tenant is ignored if empty.
+// Returns nil if addr flag wasn't set.
+func InitWithTenant(ctx context.Context, tenant string) (*Client, error) {
 	if *addr == "" {
 		return nil, nil
 	}
+	rwAddr := *addr
+	if tenant != "" {
+		u, err := url.Parse(rwAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -remoteWrite.url: %w", err)
+		}
+		// vminsert accepts tenant data at `/insert/<tenant>/prometheus` path
+		u.Path = path.Join("/", u.Path, "insert", tenant, "prometheus")
+		rwAddr = u.String()
+	}
 
 	t, err := utils.Transport(*addr, *tlsCertFile, *tlsKeyFile, *tlsCAFile, *tlsServerName, *tlsInsecureSkipVerify)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transport: %w", err)
+		return nil, fmt.Errorf("failed to create transport for -remoteWrite.tls* flags: %w", err)
 	}
 
 	return NewClient(ctx, Config{
-		Addr:              *addr,
+		Addr:              rwAddr,
 		Concurrency:       *concurrency,
 		MaxQueueSize:      *maxQueueSize,
 		MaxBatchSize:      *maxBatchSize,
@@ -2,9 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"fmt"
+	"hash/fnv"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 )
 
 // Rule represents alerting or recording rule
@@ -14,8 +20,10 @@ type Rule interface {
 	// ID returns unique ID that may be used for
 	// identifying this Rule among others.
 	ID() uint64
-	// Exec executes the rule with given context
-	Exec(ctx context.Context) ([]prompbmarshal.TimeSeries, error)
+	// Exec executes the rule with given context at the given timestamp.
+	// If limit > 0 Exec returns an error if
+	// the number of produced series exceeds the limit.
+	Exec(ctx context.Context, ts time.Time, limit int) ([]prompbmarshal.TimeSeries, error)
 	// ExecRange executes the rule on the given time range
 	ExecRange(ctx context.Context, start, end time.Time) ([]prompbmarshal.TimeSeries, error)
 	// UpdateWith performs modification of current Rule
@@ -24,6 +32,188 @@ type Rule interface {
 	// Close performs the shutdown procedures for rule
 	// such as metrics unregister
 	Close()
+	// Healthy returns false if the rule failed
+	// HealthErrorsThreshold evaluations in a row
+	Healthy() bool
+}
+
+const (
+	ruleHealthOK  = "ok"
+	ruleHealthErr = "err"
+)
+
+// ruleHealth returns health of the rule with the given last error
+// and the number of consecutive failed evaluations.
+// The rule is unhealthy only if it failed threshold times in a row.
+func ruleHealth(lastErr error, failures, threshold int) string {
+	if lastErr == nil || failures < threshold {
+		return ruleHealthOK
+	}
+	return ruleHealthErr
+}
+
+// getHealthErrorsThreshold returns the health errors
+// threshold for the given rule config
+func getHealthErrorsThreshold(cfg config.Rule) int {
+	if cfg.HealthErrorsThreshold > 0 {
+		return cfg.HealthErrorsThreshold
+	}
+	return *healthErrorsThreshold
 }
 
 var errDuplicate = errors.New("result contains metrics with the same labelset after applying rule labels")
+
+var errLimitExceeded = errors.New("exec exceeded limit")
+
+var errNoData = errors.New("query returned no data")
+
+// ruleID returns the ID of the rule with the given config
+// within the group with the given ID. The ID doesn't depend
+// on the rule's position in the group, so it remains the same
+// on config reloads until the rule's group, name, type,
+// expression or labels are changed.
+func ruleID(groupID uint64, cfg config.Rule) uint64 {
+	h := fnv.New64a()
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], groupID)
+	binary.BigEndian.PutUint64(b[8:], cfg.ID)
+	h.Write(b[:])
+	return h.Sum64()
+}
+
+// ruleQuerierParams returns datasource params for the given rule
+func ruleQuerierParams(group *Group, cfg config.Rule) datasource.QuerierParams {
+	p := group.querierParams()
+	p.DataSourceType = &cfg.Type
+	p.Debug = cfg.Debug
+	return p
+}
+
+// getRuleTimeout returns the query timeout for the given rule config
+func getRuleTimeout(cfg config.Rule) time.Duration {
+	if d := cfg.Timeout.Duration(); d > 0 {
+		return d
+	}
+	return *queryTimeout
+}
+
+// queryWithTimeout executes the query at ts via q and cancels it
+// if it takes longer than timeout. Zero timeout means no limit.
+func queryWithTimeout(ctx context.Context, q datasource.Querier, query string, ts time.Time, timeout time.Duration) ([]datasource.Metric, error) {
+	if timeout <= 0 {
+		return q.Query(ctx, query, ts)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	metrics, err := q.Query(ctx, query, ts)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("query timed out after %s: %w", timeout, err)
+	}
+	return metrics, err
+}
+
+// ruleStateEntry contains the result of a single rule evaluation
+type ruleStateEntry struct {
+	// stores the moment of time when rule evaluation started
+	time time.Time
+	// stores the duration of the evaluation
+	duration time.Duration
+	// stores the number of samples returned during the evaluation
+	samples int
+	// stores an error that happened during the evaluation
+	err error
+	// stores the trace id sent to the datasource via traceparent header
+	traceID string
+	// stores the query trace returned by the datasource
+	trace string
+}
+
+// ruleState is a ring buffer with the last rule evaluations.
+// It isn't thread-safe and must be guarded by the rule's mutex.
+// Nil ruleState doesn't store entries.
+type ruleState struct {
+	entries []ruleStateEntry
+	// index of the next entry to write
+	cur int
+}
+
+// newRuleState returns ruleState which keeps up to size entries.
+// If size is 0 then entries aren't stored.
+func newRuleState(size int) *ruleState {
+	if size < 1 {
+		return &ruleState{}
+	}
+	return &ruleState{entries: make([]ruleStateEntry, size)}
+}
+
+// size returns the max number of stored entries
+func (s *ruleState) size() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.entries)
+}
+
+// add stores the given entry and overwrites
+// the oldest one if the buffer is full
+func (s *ruleState) add(e ruleStateEntry) {
+	if s.size() == 0 {
+		return
+	}
+	s.entries[s.cur] = e
+	s.cur = (s.cur + 1) % len(s.entries)
+}
+
+// getAll returns stored entries ordered from the newest to the oldest
+func (s *ruleState) getAll() []ruleStateEntry {
+	n := s.size()
+	var entries []ruleStateEntry
+	for i := 1; i <= n; i++ {
+		e := s.entries[(s.cur-i+n)%n]
+		if e.time.IsZero() {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// resize returns ruleState with the given size
+// which contains the newest entries of s
+func (s *ruleState) resize(size int) *ruleState {
+	ns := newRuleState(size)
+	entries := s.getAll()
+	for i := len(entries) - 1; i >= 0; i-- {
+		ns.add(entries[i])
+	}
+	return ns
+}
+
+// updatesAPI returns state entries for WEB view
+func (s *ruleState) updatesAPI() []APIRuleUpdate {
+	var updates []APIRuleUpdate
+	for _, e := range s.getAll() {
+		u := APIRuleUpdate{
+			Time:     e.time,
+			Duration: e.duration.String(),
+			Samples:  e.samples,
+			TraceID:  e.traceID,
+			Trace:    e.trace,
+		}
+		if e.err != nil {
+			u.Error = e.err.Error()
+		}
+		updates = append(updates, u)
+	}
+	return updates
+}
+
+// newRuleStateFromConfig returns ruleState of size configured
+// via update_entries_limit or -rule.updateEntriesLimit
+func newRuleStateFromConfig(cfg config.Rule) *ruleState {
+	size := *ruleUpdateEntriesLimit
+	if cfg.UpdateEntriesLimit != nil {
+		size = *cfg.UpdateEntriesLimit
+	}
+	return newRuleState(size)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var (
+	ruleGroupFilter = flag.String("rule.groupFilter", "", "Optional regexp for names of groups to load from -rule files. "+
+		"The regexp must match the whole group name. Groups not matching the regexp are skipped on start and on config reloads. "+
+		"By default, all the groups are loaded")
+	ruleNameFilter = flag.String("rule.nameFilter", "", "Optional regexp for names of rules to load from -rule files. "+
+		"The regexp must match the whole alert or record name. Rules not matching the regexp are skipped on start and on config reloads, "+
+		"as well as groups without matching rules. By default, all the rules are loaded")
+)
+
+// rulesFilter is built from -rule.groupFilter and -rule.nameFilter flags.
+// It is nil if filters aren't set.
+var rulesFilter *groupsFilter
+
+// groupsFilter filters groups and rules by their names
+type groupsFilter struct {
+	group *regexp.Regexp
+	name  *regexp.Regexp
+}
+
+// newGroupsFilter returns filter for the given regexps.
+// It returns nil filter if both regexps are empty.
+func newGroupsFilter(groupRe, nameRe string) (*groupsFilter, error) {
+	if groupRe == "" && nameRe == "" {
+		return nil, nil
+	}
+	gf := &groupsFilter{}
+	var err error
+	if gf.group, err = compileAnchored(groupRe); err != nil {
+		return nil, fmt.Errorf("cannot parse -rule.groupFilter: %w", err)
+	}
+	if gf.name, err = compileAnchored(nameRe); err != nil {
+		return nil, fmt.Errorf("cannot parse -rule.nameFilter: %w", err)
+	}
+	return gf, nil
+}
+
+func compileAnchored(re string) (*regexp.Regexp, error) {
+	if re == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + re + ")$")
+}
+
+// apply returns groups and rules matching the filter
+// and the number of skipped groups and rules.
+// Groups without matching rules are skipped.
+func (gf *groupsFilter) apply(groups []config.Group) ([]config.Group, int, int) {
+	if gf == nil {
+		return groups, 0, 0
+	}
+	var res []config.Group
+	var skippedGroups, skippedRules int
+	for _, g := range groups {
+		if gf.group != nil && !gf.group.MatchString(g.Name) {
+			skippedGroups++
+			skippedRules += len(g.Rules)
+			continue
+		}
+		if gf.name != nil {
+			var rules []config.Rule
+			for _, r := range g.Rules {
+				if gf.name.MatchString(r.Name()) {
+					rules = append(rules, r)
+				}
+			}
+			skippedRules += len(g.Rules) - len(rules)
+			if len(rules) == 0 {
+				skippedGroups++
+				continue
+			}
+			g.Rules = rules
+		}
+		res = append(res, g)
+	}
+	return res, skippedGroups, skippedRules
+}
+
+// filterGroups applies rulesFilter to the given groups
+// and logs the number of skipped groups and rules.
+func filterGroups(groups []config.Group) []config.Group {
+	res, skippedGroups, skippedRules := rulesFilter.apply(groups)
+	logSkippedGroups(len(res), skippedGroups, skippedRules)
+	return res
+}
+
+func logSkippedGroups(loaded, skippedGroups, skippedRules int) {
+	if rulesFilter == nil {
+		return
+	}
+	logger.Infof("skipped %d groups and %d rules not matching -rule.groupFilter=%q and -rule.nameFilter=%q; loaded %d groups",
+		skippedGroups, skippedRules, *ruleGroupFilter, *ruleNameFilter, loaded)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+)
+
+func TestGroupsFilter(t *testing.T) {
+	groups := []config.Group{
+		{Name: "team-a", Rules: []config.Rule{{Alert: "HighLatency"}, {Record: "job:latency:avg"}}},
+		{Name: "team-b", Rules: []config.Rule{{Alert: "HighErrorRate"}}},
+		{Name: "team-a-extra", Rules: []config.Rule{{Alert: "HighLatency"}}},
+	}
+	f := func(groupRe, nameRe string, expGroups map[string][]string, expSkippedGroups, expSkippedRules int) {
+		t.Helper()
+		gf, err := newGroupsFilter(groupRe, nameRe)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		res, skippedGroups, skippedRules := gf.apply(groups)
+		got := make(map[string][]string)
+		for _, g := range res {
+			for _, r := range g.Rules {
+				got[g.Name] = append(got[g.Name], r.Name())
+			}
+		}
+		if !reflect.DeepEqual(got, expGroups) {
+			t.Fatalf("expected to get %v; got %v", expGroups, got)
+		}
+		if skippedGroups != expSkippedGroups || skippedRules != expSkippedRules {
+			t.Fatalf("expected to skip %d groups and %d rules; got %d and %d",
+				expSkippedGroups, expSkippedRules, skippedGroups, skippedRules)
+		}
+	}
+	f("", "", map[string][]string{
+		"team-a":       {"HighLatency", "job:latency:avg"},
+		"team-b":       {"HighErrorRate"},
+		"team-a-extra": {"HighLatency"},
+	}, 0, 0)
+	// the regexp must match the whole name
+	f("team-a", "", map[string][]string{
+		"team-a": {"HighLatency", "job:latency:avg"},
+	}, 2, 2)
+	f("team-a.*", "High.*", map[string][]string{
+		"team-a":       {"HighLatency"},
+		"team-a-extra": {"HighLatency"},
+	}, 1, 2)
+	// groups without matching rules are skipped
+	f("", "HighErrorRate", map[string][]string{
+		"team-b": {"HighErrorRate"},
+	}, 2, 3)
+
+	if _, err := newGroupsFilter("(", ""); err == nil {
+		t.Fatalf("expected to get error for invalid regexp")
+	}
+	if _, err := newGroupsFilter("", "["); err == nil {
+		t.Fatalf("expected to get error for invalid regexp")
+	}
+}
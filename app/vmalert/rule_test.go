@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+)
+
+func TestRuleState(t *testing.T) {
+	if s := newRuleState(0); s.size() != 0 {
+		t.Fatalf("expected disabled state to have size 0; got %d", s.size())
+	}
+
+	s := newRuleState(3)
+	if entries := s.getAll(); len(entries) != 0 {
+		t.Fatalf("expected to get no entries; got %d", len(entries))
+	}
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		s.add(ruleStateEntry{
+			time:    now.Add(time.Duration(i) * time.Second),
+			samples: i,
+			err:     fmt.Errorf("err %d", i),
+		})
+	}
+	entries := s.getAll()
+	if len(entries) != 3 {
+		t.Fatalf("expected to get %d entries; got %d", 3, len(entries))
+	}
+	for i, e := range entries {
+		// entries are expected to be ordered from the newest
+		if exp := 4 - i; e.samples != exp {
+			t.Fatalf("expected entry %d to have %d samples; got %d", i, exp, e.samples)
+		}
+	}
+
+	s = s.resize(2)
+	entries = s.getAll()
+	if len(entries) != 2 {
+		t.Fatalf("expected to get %d entries after resize; got %d", 2, len(entries))
+	}
+	if entries[0].samples != 4 || entries[1].samples != 3 {
+		t.Fatalf("expected to keep the newest entries after resize; got %v", entries)
+	}
+
+	s = s.resize(0)
+	s.add(ruleStateEntry{time: now})
+	if entries := s.getAll(); len(entries) != 0 {
+		t.Fatalf("expected to get no entries for disabled state; got %d", len(entries))
+	}
+}
+
+// slowQuerier blocks queries until the context is done
+type slowQuerier struct {
+	fakeQuerier
+}
+
+func (sq *slowQuerier) Query(ctx context.Context, _ string, _ time.Time) ([]datasource.Metric, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRuleQueryTimeout(t *testing.T) {
+	ar := newTestAlertingRule("slow", 0)
+	ar.q = &slowQuerier{}
+	ar.Timeout = 10 * time.Millisecond
+	_, err := ar.Exec(context.Background(), time.Now(), 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to get deadline exceeded error; got %v", err)
+	}
+	if ar.lastExecError == nil || ar.lastExecFailures != 1 {
+		t.Fatalf("expected rule to be marked as failed; got err %v and %d failures", ar.lastExecError, ar.lastExecFailures)
+	}
+	if api := ar.RuleAPI(); api.Timeout != "10ms" || api.LastDuration < ar.Timeout.Seconds() {
+		t.Fatalf("unexpected timeout %q or last duration %v", api.Timeout, api.LastDuration)
+	}
+
+	rr := &RecordingRule{Name: "slow", q: &slowQuerier{}, Timeout: 10 * time.Millisecond}
+	if _, err := rr.Exec(context.Background(), time.Now(), 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to get deadline exceeded error; got %v", err)
+	}
+}
+
+func TestRuleID(t *testing.T) {
+	r1 := config.Rule{Alert: "foo", Expr: "up == 0"}
+	r2 := config.Rule{Record: "bar", Expr: "sum(up)"}
+	r1.ID, r2.ID = config.HashRule(r1), config.HashRule(r2)
+	g1 := config.Group{Name: "group", File: "rules.yaml", Rules: []config.Rule{r1, r2}}
+	g2 := config.Group{Name: "group", File: "rules.yaml", Rules: []config.Rule{r2, r1}}
+
+	ids := func(cfg config.Group) map[string]uint64 {
+		g := newGroup(cfg, &fakeQuerier{}, time.Minute, nil)
+		m := make(map[string]uint64)
+		for _, r := range g.Rules {
+			m[fmt.Sprintf("%s", r)] = r.ID()
+		}
+		return m
+	}
+	ids1 := ids(g1)
+	// reordering of rules must not change IDs
+	if ids2 := ids(g2); !reflect.DeepEqual(ids1, ids2) {
+		t.Fatalf("expected IDs to remain the same after rules reordering; got %v and %v", ids1, ids2)
+	}
+	// the same rule in another group must have another ID
+	g3 := config.Group{Name: "another group", File: "rules.yaml", Rules: []config.Rule{r1}}
+	if ids3 := ids(g3); ids3["foo"] == ids1["foo"] {
+		t.Fatalf("expected rules in different groups to have different IDs")
+	}
+	// the change of expression must change the ID
+	r1.Expr = "up != 1"
+	r1.ID = config.HashRule(r1)
+	g1.Rules = []config.Rule{r1, r2}
+	if ids4 := ids(g1); ids4["foo"] == ids1["foo"] || ids4["bar"] != ids1["bar"] {
+		t.Fatalf("expected only the changed rule to get a new ID; got %v and %v", ids1, ids4)
+	}
+}
+
+func TestRuleHealthErrorsThreshold(t *testing.T) {
+	fq := &fakeQuerier{}
+	fq.setErr(errors.New("connection refused"))
+	ar := newTestAlertingRule("unstable", 0)
+	ar.q = fq
+	ar.HealthErrorsThreshold = 3
+	e := &executor{}
+
+	for i := 1; i <= 3; i++ {
+		err := e.exec(context.Background(), ar, time.Now(), 0, 0)
+		if err == nil {
+			t.Fatalf("expected to get error")
+		}
+		var te *transientError
+		isTransient := errors.As(err, &te)
+		expHealthy := i < ar.HealthErrorsThreshold
+		if ar.Healthy() != expHealthy || isTransient != expHealthy {
+			t.Fatalf("evaluation %d: expected healthy=%v; got healthy=%v and transient error=%v",
+				i, expHealthy, ar.Healthy(), isTransient)
+		}
+		exp := ruleHealthOK
+		if !expHealthy {
+			exp = ruleHealthErr
+		}
+		if got := ar.RuleAPI().Health; got != exp {
+			t.Fatalf("evaluation %d: expected health %q; got %q", i, exp, got)
+		}
+	}
+
+	fq.setErr(nil)
+	if err := e.exec(context.Background(), ar, time.Now(), 0, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ar.Healthy() || ar.RuleAPI().Health != ruleHealthOK {
+		t.Fatalf("expected rule to become healthy after successful evaluation")
+	}
+
+	rr := &RecordingRule{Name: "unstable", q: fq, HealthErrorsThreshold: 1}
+	fq.setErr(errors.New("connection refused"))
+	if _, err := rr.Exec(context.Background(), time.Now(), 0); err == nil {
+		t.Fatalf("expected to get error")
+	}
+	if rr.Healthy() || rr.RuleAPI().Health != ruleHealthErr {
+		t.Fatalf("expected rule to be unhealthy after the first failure with threshold 1")
+	}
+}
+
+func TestGetHealthErrorsThreshold(t *testing.T) {
+	if got := getHealthErrorsThreshold(config.Rule{}); got != *healthErrorsThreshold {
+		t.Fatalf("expected to get default threshold %d; got %d", *healthErrorsThreshold, got)
+	}
+	if got := getHealthErrorsThreshold(config.Rule{HealthErrorsThreshold: 5}); got != 5 {
+		t.Fatalf("expected to get threshold 5; got %d", got)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/unittest"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+)
+
+var unitTestFiles = flagutil.NewArray("unittest", "Path to the unit test files in the format compatible with `promtool test rules`. "+
+	"When set, vmalert evaluates rules from the files referred via `rule_files` against the input series defined in the test files, "+
+	"prints the results and exits with non-zero code if any test fails. Other flags such as -datasource.url aren't required in this mode. "+
+	"See https://docs.victoriametrics.com/vmalert.html#unit-testing-for-rules")
+
+// unitTest runs unit tests from the given files.
+// It returns false if any of the tests failed.
+func unitTest(files []string) bool {
+	eu, err := url.Parse(*externalURL)
+	if err != nil || *externalURL == "" {
+		eu, _ = url.Parse("http://localhost:8880")
+	}
+	notifier.InitTemplateFunc(eu)
+
+	passed := true
+	for _, f := range files {
+		fmt.Printf("\nUnit Testing: %s\n", f)
+		errs := ruleUnitTest(f)
+		if len(errs) > 0 {
+			passed = false
+			fmt.Printf("  FAILED:\n")
+			for _, err := range errs {
+				fmt.Printf("%s\n", err)
+			}
+			continue
+		}
+		fmt.Printf("  SUCCESS\n")
+	}
+	return passed
+}
+
+func ruleUnitTest(path string) []error {
+	f, err := unittest.ParseFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("    failed to parse %q: %w", path, err)}
+	}
+	groupsCfg, err := config.Parse(f.RuleFiles, true, true)
+	if err != nil {
+		return []error{fmt.Errorf("    failed to parse rule_files: %w", err)}
+	}
+	groupsCfg, err = orderGroups(groupsCfg, f.GroupEvalOrder)
+	if err != nil {
+		return []error{fmt.Errorf("    %w", err)}
+	}
+	var errs []error
+	for _, tg := range f.Tests {
+		for _, err := range runTestGroup(tg, groupsCfg, f.EvaluationInterval.Duration()) {
+			if tg.Name != "" {
+				err = fmt.Errorf("    name: %s,\n%w", tg.Name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// orderGroups puts groups from order in front of the rest groups.
+func orderGroups(groups []config.Group, order []string) ([]config.Group, error) {
+	if len(order) == 0 {
+		return groups, nil
+	}
+	byName := make(map[string]config.Group, len(groups))
+	for _, g := range groups {
+		byName[g.Name] = g
+	}
+	ordered := make([]config.Group, 0, len(groups))
+	seen := make(map[string]struct{}, len(order))
+	for _, name := range order {
+		g, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("group %q from group_eval_order isn't present in rule_files", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("group %q is duplicated in group_eval_order", name)
+		}
+		seen[name] = struct{}{}
+		ordered = append(ordered, g)
+	}
+	for _, g := range groups {
+		if _, ok := seen[g.Name]; !ok {
+			ordered = append(ordered, g)
+		}
+	}
+	return ordered, nil
+}
+
+// testStartTime is the time of the first sample
+// of input series and of the first evaluation.
+// It isn't Unix epoch as in promtool, since queries look back
+// from the evaluation time, while the storage can't search
+// for samples before Unix epoch.
+var testStartTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func runTestGroup(tg unittest.TestGroup, groupsCfg []config.Group, evalInterval time.Duration) []error {
+	s, err := unittest.OpenStorage()
+	if err != nil {
+		return []error{fmt.Errorf("    failed to open storage: %w", err)}
+	}
+	defer s.Close()
+
+	if err := s.WriteInputSeries(tg.InputSeries, testStartTime, tg.Interval.Duration()); err != nil {
+		return []error{fmt.Errorf("    failed to write input series: %w", err)}
+	}
+
+	var groups []*Group
+	for _, cfg := range groupsCfg {
+		groups = append(groups, newGroup(cfg, s, evalInterval, tg.ExternalLabels))
+	}
+	defer func() {
+		for _, g := range groups {
+			for _, r := range g.Rules {
+				r.Close()
+			}
+		}
+	}()
+
+	var maxEvalTime time.Duration
+	alertTests := append([]unittest.AlertTestCase{}, tg.AlertRuleTests...)
+	sort.SliceStable(alertTests, func(i, j int) bool {
+		return alertTests[i].EvalTime.Duration() < alertTests[j].EvalTime.Duration()
+	})
+	if n := len(alertTests); n > 0 {
+		maxEvalTime = alertTests[n-1].EvalTime.Duration()
+	}
+	for _, mt := range tg.MetricsqlExprTest {
+		if d := mt.EvalTime.Duration(); d > maxEvalTime {
+			maxEvalTime = d
+		}
+	}
+
+	var errs []error
+	ctx := context.Background()
+	var curr int
+	for ts := testStartTime; !ts.After(testStartTime.Add(maxEvalTime)); ts = ts.Add(evalInterval) {
+		for _, g := range groups {
+			for _, rule := range g.Rules {
+				tss, err := rule.Exec(ctx, ts, g.Limit)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("    rule %q at %v: %w", rule, ts.Sub(testStartTime), err))
+					continue
+				}
+				// persist results, so they are available for the next rules
+				if err := s.Write(tss); err != nil {
+					errs = append(errs, fmt.Errorf("    rule %q at %v: %w", rule, ts.Sub(testStartTime), err))
+				}
+			}
+		}
+		// check alert tests with eval_time within the current evaluation interval
+		for ; curr < len(alertTests); curr++ {
+			at := alertTests[curr]
+			if at.EvalTime.Duration() >= ts.Add(evalInterval).Sub(testStartTime) {
+				break
+			}
+			if err := checkAlerts(groups, at); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for _, mt := range tg.MetricsqlExprTest {
+		if err := checkMetricsqlExpr(s, mt, evalInterval); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// testAlert is the alert representation used for comparison
+// during unit tests
+type testAlert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+func (ta testAlert) String() string {
+	return fmt.Sprintf("%s %s", mapToString(ta.Labels), mapToString(ta.Annotations))
+}
+
+func checkAlerts(groups []*Group, at unittest.AlertTestCase) error {
+	var got []testAlert
+	for _, g := range groups {
+		for _, r := range g.Rules {
+			ar, ok := r.(*AlertingRule)
+			if !ok || ar.Name != at.Alertname {
+				continue
+			}
+			for _, a := range ar.alerts {
+				if a.State != notifier.StateFiring {
+					continue
+				}
+				labels := make(map[string]string, len(a.Labels)+1)
+				for k, v := range a.Labels {
+					labels[k] = v
+				}
+				// alertgroup label is ignored for compatibility with promtool
+				delete(labels, alertGroupNameLabel)
+				labels[alertNameLabel] = ar.Name
+				got = append(got, testAlert{Labels: labels, Annotations: a.Annotations})
+			}
+		}
+	}
+
+	var exp []testAlert
+	for _, ea := range at.ExpAlerts {
+		labels := make(map[string]string, len(ea.ExpLabels)+1)
+		for k, v := range ea.ExpLabels {
+			labels[k] = v
+		}
+		labels[alertNameLabel] = at.Alertname
+		exp = append(exp, testAlert{Labels: labels, Annotations: ea.ExpAnnotations})
+	}
+
+	sortTestAlerts(got)
+	sortTestAlerts(exp)
+	if len(got) == 0 && len(exp) == 0 {
+		return nil
+	}
+	if !reflect.DeepEqual(normalizeTestAlerts(got), normalizeTestAlerts(exp)) {
+		return fmt.Errorf("    alertname: %s, time: %v,\n        exp: %v,\n        got: %v",
+			at.Alertname, at.EvalTime.Duration(), exp, got)
+	}
+	return nil
+}
+
+func sortTestAlerts(alerts []testAlert) {
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].String() < alerts[j].String()
+	})
+}
+
+// normalizeTestAlerts replaces nil maps with empty ones,
+// so missing and empty annotations are treated equally
+func normalizeTestAlerts(alerts []testAlert) []testAlert {
+	res := make([]testAlert, len(alerts))
+	for i, a := range alerts {
+		if a.Annotations == nil {
+			a.Annotations = map[string]string{}
+		}
+		res[i] = a
+	}
+	return res
+}
+
+// testSample is the sample representation used
+// for comparison during unit tests
+type testSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+func (ts testSample) String() string {
+	return fmt.Sprintf("%s %v", mapToString(ts.Labels), ts.Value)
+}
+
+func checkMetricsqlExpr(s *unittest.Storage, mt unittest.MetricsqlTestCase, evalInterval time.Duration) error {
+	q := s.BuildWithParams(datasource.QuerierParams{EvaluationInterval: evalInterval})
+	metrics, err := q.Query(context.Background(), mt.Expr, testStartTime.Add(mt.EvalTime.Duration()))
+	if err != nil {
+		return fmt.Errorf("    expr: %q, time: %v, err: %w", mt.Expr, mt.EvalTime.Duration(), err)
+	}
+	var got []testSample
+	for _, m := range metrics {
+		labels := make(map[string]string, len(m.Labels))
+		for _, l := range m.Labels {
+			labels[l.Name] = l.Value
+		}
+		got = append(got, testSample{Labels: labels, Value: m.Values[0]})
+	}
+	var exp []testSample
+	for _, es := range mt.ExpSamples {
+		labels := make(map[string]string)
+		if es.Labels != "" {
+			ls, err := unittest.ParseSeries(es.Labels)
+			if err != nil {
+				return fmt.Errorf("    expr: %q, time: %v, err: %w", mt.Expr, mt.EvalTime.Duration(), err)
+			}
+			for _, l := range ls {
+				labels[l.Name] = l.Value
+			}
+		}
+		exp = append(exp, testSample{Labels: labels, Value: es.Value})
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].String() < got[j].String() })
+	sort.Slice(exp, func(i, j int) bool { return exp[i].String() < exp[j].String() })
+	if !equalTestSamples(got, exp) {
+		return fmt.Errorf("    expr: %q, time: %v,\n        exp: %v,\n        got: %v",
+			mt.Expr, mt.EvalTime.Duration(), exp, got)
+	}
+	return nil
+}
+
+func equalTestSamples(a, b []testSample) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !reflect.DeepEqual(a[i].Labels, b[i].Labels) {
+			return false
+		}
+		if !almostEqual(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// almostEqual returns true if a and b are equal
+// with respect to float64 calculation errors
+func almostEqual(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if a == b {
+		return true
+	}
+	const epsilon = 1e-9
+	return math.Abs(a-b) <= epsilon*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func mapToString(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", k, m[k])
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -0,0 +1,119 @@
+package unittest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+// sequenceValue is a single value of the input series.
+// Omitted values represent missing samples.
+type sequenceValue struct {
+	Value   float64
+	Omitted bool
+}
+
+// parseInputValue parses input series values in the expanding notation:
+//   - `a+bxn` becomes `a a+b a+(2*b) ... a+(n*b)`;
+//   - `a-bxn` becomes `a a-b a-(2*b) ... a-(n*b)`;
+//   - `axn` becomes `a a ... a` (n+1 times);
+//   - `_` represents a missing sample;
+//   - `_xn` becomes `_ _ ... _` (n times);
+//   - `stale` represents a stale sample.
+func parseInputValue(input string) ([]sequenceValue, error) {
+	var res []sequenceValue
+	for _, item := range strings.Fields(input) {
+		switch item {
+		case "_":
+			res = append(res, sequenceValue{Omitted: true})
+			continue
+		case "stale":
+			res = append(res, sequenceValue{Value: decimal.StaleNaN})
+			continue
+		}
+		n := strings.LastIndexByte(item, 'x')
+		if n < 0 {
+			v, err := strconv.ParseFloat(item, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse value %q: %w", item, err)
+			}
+			res = append(res, sequenceValue{Value: v})
+			continue
+		}
+		expr, times := item[:n], item[n+1:]
+		count, err := strconv.ParseInt(times, 10, 64)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("cannot parse repetitions count %q in %q", times, item)
+		}
+		if expr == "_" {
+			for i := int64(0); i < count; i++ {
+				res = append(res, sequenceValue{Omitted: true})
+			}
+			continue
+		}
+		start, step, err := parseStartStep(expr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q: %w", item, err)
+		}
+		for i := int64(0); i <= count; i++ {
+			res = append(res, sequenceValue{Value: start + step*float64(i)})
+		}
+	}
+	return res, nil
+}
+
+// parseStartStep parses `a+b`, `a-b` or `a` expressions.
+func parseStartStep(expr string) (float64, float64, error) {
+	// skip the leading sign and signs of exponents
+	for i := 1; i < len(expr); i++ {
+		c := expr[i]
+		if c != '+' && c != '-' {
+			continue
+		}
+		if prev := expr[i-1]; prev == 'e' || prev == 'E' {
+			continue
+		}
+		start, err := strconv.ParseFloat(expr[:i], 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("cannot parse start value %q: %w", expr[:i], err)
+		}
+		step, err := strconv.ParseFloat(expr[i+1:], 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("cannot parse step value %q: %w", expr[i+1:], err)
+		}
+		if c == '-' {
+			step = -step
+		}
+		return start, step, nil
+	}
+	start, err := strconv.ParseFloat(expr, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse value %q: %w", expr, err)
+	}
+	return start, 0, nil
+}
+
+// ParseSeries parses series selector with equality label
+// filters only, e.g. `up{job="foo"}`, into the list of labels.
+func ParseSeries(s string) ([]datasource.Label, error) {
+	expr, err := metricsql.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse series %q: %w", s, err)
+	}
+	me, ok := expr.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, fmt.Errorf("expecting series selector; got %q", s)
+	}
+	var labels []datasource.Label
+	for _, lf := range me.LabelFilters {
+		if lf.IsRegexp || lf.IsNegative {
+			return nil, fmt.Errorf("series %q may contain only `=` label filters", s)
+		}
+		labels = append(labels, datasource.Label{Name: lf.Label, Value: lf.Value})
+	}
+	return labels, nil
+}
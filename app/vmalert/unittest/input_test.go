@@ -0,0 +1,102 @@
+package unittest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+func TestParseInputValue(t *testing.T) {
+	f := func(input string, exp []sequenceValue) {
+		t.Helper()
+		got, err := parseInputValue(input)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", input, err)
+		}
+		if len(got) != len(exp) {
+			t.Fatalf("expected to get %d values for %q; got %d: %v", len(exp), input, len(got), got)
+		}
+		for i := range exp {
+			if exp[i].Omitted != got[i].Omitted {
+				t.Fatalf("expected value #%d of %q to be omitted=%v", i, input, exp[i].Omitted)
+			}
+			if decimal.IsStaleNaN(exp[i].Value) {
+				if !decimal.IsStaleNaN(got[i].Value) {
+					t.Fatalf("expected value #%d of %q to be stale; got %v", i, input, got[i].Value)
+				}
+				continue
+			}
+			if exp[i].Value != got[i].Value {
+				t.Fatalf("expected value #%d of %q to be %v; got %v", i, input, exp[i].Value, got[i].Value)
+			}
+		}
+	}
+	v := func(vs ...float64) []sequenceValue {
+		var res []sequenceValue
+		for _, v := range vs {
+			res = append(res, sequenceValue{Value: v})
+		}
+		return res
+	}
+	omitted := sequenceValue{Omitted: true}
+	stale := sequenceValue{Value: decimal.StaleNaN}
+
+	f("", nil)
+	f("1", v(1))
+	f("1 2 3", v(1, 2, 3))
+	f("-1.5 1e3", v(-1.5, 1000))
+	f("1x3", v(1, 1, 1, 1))
+	f("1+1x3", v(1, 2, 3, 4))
+	f("10-2x2", v(10, 8, 6))
+	f("-1+1x2", v(-1, 0, 1))
+	f("1e1+1e1x2", v(10, 20, 30))
+	f("_", []sequenceValue{omitted})
+	f("_x3", []sequenceValue{omitted, omitted, omitted})
+	f("stale", []sequenceValue{stale})
+	f("1 _ stale 2", []sequenceValue{{Value: 1}, omitted, stale, {Value: 2}})
+}
+
+func TestParseInputValueFailure(t *testing.T) {
+	f := func(input string) {
+		t.Helper()
+		if _, err := parseInputValue(input); err == nil {
+			t.Fatalf("expected to get an error for %q", input)
+		}
+	}
+	f("foo")
+	f("1xfoo")
+	f("1x-1")
+	f("1+foox2")
+	f("_+1x2")
+}
+
+func TestParseSeries(t *testing.T) {
+	f := func(s string, exp []datasource.Label, expErr bool) {
+		t.Helper()
+		got, err := ParseSeries(s)
+		if expErr {
+			if err == nil {
+				t.Fatalf("expected to get an error for %q", s)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("expected to get %v; got %v", exp, got)
+		}
+	}
+	f(`up`, []datasource.Label{{Name: "__name__", Value: "up"}}, false)
+	f(`up{job="foo",instance="bar"}`, []datasource.Label{
+		{Name: "__name__", Value: "up"},
+		{Name: "job", Value: "foo"},
+		{Name: "instance", Value: "bar"},
+	}, false)
+	f(`up{job=~"foo"}`, nil, true)
+	f(`up{job!="foo"}`, nil, true)
+	f(`sum(up)`, nil, true)
+	f(`up{`, nil, true)
+}
@@ -0,0 +1,196 @@
+package unittest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// queryTimeout is the max duration of a single query evaluation
+const queryTimeout = 30 * time.Second
+
+// defaultStep is used as step for instant queries
+// if evaluation interval isn't set
+const defaultStep = 5 * time.Minute
+
+// retentionMsecs must cover input series,
+// since they may start long ago
+const retentionMsecs = 100 * 365 * 24 * 3600 * 1000
+
+// Storage is a temporary VictoriaMetrics storage which is used
+// as a datasource for rules evaluation in unit test mode.
+// Queries are executed by the MetricsQL engine of VictoriaMetrics,
+// so the results are the same as for the single-node VictoriaMetrics.
+// Only one Storage can be opened at a time.
+type Storage struct {
+	dir string
+}
+
+// OpenStorage opens a Storage in a temporary directory.
+// The directory is removed on Close.
+func OpenStorage() (*Storage, error) {
+	dir, err := ioutil.TempDir("", "vmalert-unittest-")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create storage directory: %w", err)
+	}
+	strg, err := storage.OpenStorage(dir+"/data", retentionMsecs, 0, 0)
+	if err != nil {
+		fs.MustRemoveAll(dir)
+		return nil, fmt.Errorf("cannot open storage at %q: %w", dir, err)
+	}
+	// the query engine searches the storage via app/vmstorage
+	vmstorage.Storage = strg
+	netstorage.InitTmpBlocksDir(dir + "/tmp")
+	return &Storage{dir: dir}, nil
+}
+
+// Close stops the storage and removes its data.
+func (s *Storage) Close() {
+	vmstorage.Storage.MustClose()
+	vmstorage.Storage = nil
+	fs.MustRemoveAll(s.dir)
+}
+
+// WriteInputSeries writes the given series to the storage.
+// Values of every series start at start and have the given interval.
+func (s *Storage) WriteInputSeries(series []Series, start time.Time, interval time.Duration) error {
+	var mrs []storage.MetricRow
+	for _, is := range series {
+		labels, err := ParseSeries(is.Series)
+		if err != nil {
+			return err
+		}
+		values, err := parseInputValue(is.Values)
+		if err != nil {
+			return fmt.Errorf("series %q: %w", is.Series, err)
+		}
+		var pls []prompb.Label
+		for _, l := range labels {
+			pls = append(pls, prompb.Label{Name: []byte(l.Name), Value: []byte(l.Value)})
+		}
+		metricNameRaw := storage.MarshalMetricNameRaw(nil, pls)
+		for i, v := range values {
+			if v.Omitted {
+				continue
+			}
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     start.Add(interval*time.Duration(i)).UnixNano() / 1e6,
+				Value:         v.Value,
+			})
+		}
+	}
+	return s.addRows(mrs)
+}
+
+// Write writes the given time series to the storage.
+// It is used for persisting recording rules results.
+func (s *Storage) Write(tss []prompbmarshal.TimeSeries) error {
+	var mrs []storage.MetricRow
+	for _, ts := range tss {
+		var pls []prompb.Label
+		for _, l := range ts.Labels {
+			pls = append(pls, prompb.Label{Name: []byte(l.Name), Value: []byte(l.Value)})
+		}
+		metricNameRaw := storage.MarshalMetricNameRaw(nil, pls)
+		for _, sample := range ts.Samples {
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     sample.Timestamp,
+				Value:         sample.Value,
+			})
+		}
+	}
+	return s.addRows(mrs)
+}
+
+func (s *Storage) addRows(mrs []storage.MetricRow) error {
+	if len(mrs) == 0 {
+		return nil
+	}
+	if err := vmstorage.Storage.AddRows(mrs, 64); err != nil {
+		return fmt.Errorf("cannot write rows to the storage: %w", err)
+	}
+	// make written rows available for search
+	vmstorage.Storage.DebugFlush()
+	return nil
+}
+
+// BuildWithParams implements datasource.QuerierBuilder interface.
+func (s *Storage) BuildWithParams(params datasource.QuerierParams) datasource.Querier {
+	step := params.EvaluationInterval
+	if step <= 0 {
+		step = defaultStep
+	}
+	var etf []storage.TagFilter
+	for k, v := range params.ExtraLabels {
+		etf = append(etf, storage.TagFilter{Key: []byte(k), Value: []byte(v)})
+	}
+	return &querier{
+		isGraphite:         params.DataSourceType != nil && params.DataSourceType.String() == "graphite",
+		step:               step,
+		enforcedTagFilters: etf,
+	}
+}
+
+type querier struct {
+	isGraphite         bool
+	step               time.Duration
+	enforcedTagFilters []storage.TagFilter
+}
+
+// Query executes instant query at ts.
+func (q *querier) Query(_ context.Context, query string, ts time.Time) ([]datasource.Metric, error) {
+	t := ts.UnixNano() / 1e6
+	return q.exec(query, t, t, true)
+}
+
+// QueryRange executes range query on the given time range.
+func (q *querier) QueryRange(_ context.Context, query string, from, to time.Time) ([]datasource.Metric, error) {
+	return q.exec(query, from.UnixNano()/1e6, to.UnixNano()/1e6, false)
+}
+
+func (q *querier) exec(query string, start, end int64, isInstant bool) ([]datasource.Metric, error) {
+	if q.isGraphite {
+		return nil, fmt.Errorf("graphite queries aren't supported in unit test mode")
+	}
+	ec := &promql.EvalConfig{
+		Start:              start,
+		End:                end,
+		Step:               q.step.Milliseconds(),
+		Deadline:           searchutils.NewDeadline(time.Now(), queryTimeout, ""),
+		RoundDigits:        100,
+		EnforcedTagFilters: q.enforcedTagFilters,
+	}
+	result, err := promql.Exec(ec, query, isInstant)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute query %q: %w", query, err)
+	}
+	metrics := make([]datasource.Metric, 0, len(result))
+	for _, r := range result {
+		var m datasource.Metric
+		if len(r.MetricName.MetricGroup) > 0 {
+			m.AddLabel("__name__", string(r.MetricName.MetricGroup))
+		}
+		for _, tag := range r.MetricName.Tags {
+			m.AddLabel(string(tag.Key), string(tag.Value))
+		}
+		for i, v := range r.Values {
+			m.Values = append(m.Values, v)
+			m.Timestamps = append(m.Timestamps, r.Timestamps[i]/1e3)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,165 @@
+package unittest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/datasource"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+// testSeries are written with one minute interval
+var testSeries = []Series{
+	{Series: `requests_total{job="api", instance="a"}`, Values: "0+60x10"},
+	{Series: `requests_total{job="api", instance="b"}`, Values: "0+120x4 0+120x5"},
+	{Series: `requests_total{job="web", instance="c"}`, Values: "0+6x10"},
+	{Series: `up{job="api", instance="a"}`, Values: "1x10"},
+	{Series: `up{job="api", instance="b"}`, Values: "1x5 0x5"},
+	{Series: `up{job="web", instance="c"}`, Values: "1x3 stale"},
+	{Series: `instance_info{instance="a", version="1.0"}`, Values: "1x10"},
+	{Series: `duration_bucket{le="0.1"}`, Values: "0+10x10"},
+	{Series: `duration_bucket{le="1"}`, Values: "0+20x10"},
+	{Series: `duration_bucket{le="+Inf"}`, Values: "0+20x10"},
+}
+
+// testStart is the time of the first sample of testSeries.
+// Queries look back from the evaluation time,
+// so it must be far enough from Unix epoch.
+var testStart = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func openTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s, err := OpenStorage()
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	if err := s.WriteInputSeries(testSeries, testStart, time.Minute); err != nil {
+		s.Close()
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return s
+}
+
+func TestQuerier_Query(t *testing.T) {
+	s := openTestStorage(t)
+	defer s.Close()
+	q := s.BuildWithParams(datasource.QuerierParams{EvaluationInterval: time.Minute})
+	f := func(query string, ts time.Duration, exp map[string]float64) {
+		t.Helper()
+		metrics, err := q.Query(context.Background(), query, testStart.Add(ts))
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", query, err)
+		}
+		got := make(map[string]float64, len(metrics))
+		for _, m := range metrics {
+			labels := make(map[string]string, len(m.Labels))
+			for _, l := range m.Labels {
+				labels[l.Name] = l.Value
+			}
+			got[key(labels)] = m.Values[0]
+		}
+		if len(got) != len(exp) {
+			t.Fatalf("%q: expected %v; got %v", query, exp, got)
+		}
+		for k, v := range exp {
+			gv, ok := got[k]
+			if !ok || math.Abs(gv-v) > 1e-9 {
+				t.Fatalf("%q: expected %v; got %v", query, exp, got)
+			}
+		}
+	}
+	l := func(kvs ...string) string {
+		m := make(map[string]string)
+		for i := 0; i < len(kvs); i += 2 {
+			m[kvs[i]] = kvs[i+1]
+		}
+		return key(m)
+	}
+
+	f(`up{job="api"}`, 6*time.Minute, map[string]float64{
+		l("__name__", "up", "job", "api", "instance", "a"): 1,
+		l("__name__", "up", "job", "api", "instance", "b"): 0,
+	})
+	// stale series disappears immediately
+	f(`up{job="web"}`, 3*time.Minute, map[string]float64{l("__name__", "up", "job", "web", "instance", "c"): 1})
+	f(`up{job="web"}`, 4*time.Minute, map[string]float64{})
+	// counter reset at 5m for instance b is taken into account
+	f(`sum(increase(requests_total[5m])) by (job)`, 8*time.Minute, map[string]float64{
+		l("job", "api"): 780,
+		l("job", "web"): 30,
+	})
+	f(`absent(up{job="db"})`, time.Minute, map[string]float64{l("job", "db"): 1})
+	f(`up{job="api"} * on(instance) group_left(version) instance_info`, time.Minute, map[string]float64{
+		l("job", "api", "instance", "a", "version", "1.0"): 1,
+	})
+	f(`time() - 946684800`, 2*time.Minute, map[string]float64{l(): 120})
+
+	// extra labels are added to every selector
+	q = s.BuildWithParams(datasource.QuerierParams{EvaluationInterval: time.Minute, ExtraLabels: map[string]string{"job": "web"}})
+	f(`sum(up)`, time.Minute, map[string]float64{l(): 1})
+}
+
+func TestQuerier_QueryFailure(t *testing.T) {
+	s := openTestStorage(t)
+	defer s.Close()
+	q := s.BuildWithParams(datasource.QuerierParams{EvaluationInterval: time.Minute})
+	for _, query := range []string{
+		`sum(up) +`,
+		`unknown_function(up)`,
+	} {
+		if _, err := q.Query(context.Background(), query, testStart.Add(time.Minute)); err == nil {
+			t.Fatalf("expected error for %q", query)
+		}
+	}
+	gt := datasource.NewGraphiteType()
+	q = s.BuildWithParams(datasource.QuerierParams{DataSourceType: &gt})
+	if _, err := q.Query(context.Background(), "up", testStart.Add(time.Minute)); err == nil {
+		t.Fatalf("expected error for graphite query")
+	}
+}
+
+func TestStorage_Write(t *testing.T) {
+	s, err := OpenStorage()
+	if err != nil {
+		t.Fatalf("cannot open storage: %s", err)
+	}
+	defer s.Close()
+	q := s.BuildWithParams(datasource.QuerierParams{EvaluationInterval: time.Minute})
+	err = s.Write([]prompbmarshal.TimeSeries{{
+		Labels: []prompbmarshal.Label{{Name: "__name__", Value: "foo"}},
+		// samples written out of order are sorted
+		Samples: []prompbmarshal.Sample{
+			{Timestamp: testStart.Add(2*time.Minute).UnixNano() / 1e6, Value: 2},
+			{Timestamp: testStart.Add(time.Minute).UnixNano() / 1e6, Value: 1},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	metrics, err := q.QueryRange(context.Background(), "foo", testStart.Add(time.Minute), testStart.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(metrics) != 1 || len(metrics[0].Values) != 2 || metrics[0].Values[0] != 1 || metrics[0].Values[1] != 2 {
+		t.Fatalf("unexpected result %+v", metrics)
+	}
+}
+
+// key returns the unique key for the given labels
+func key(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%q=%q,", k, labels[k])
+	}
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+package unittest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/utils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+)
+
+// File represents the unit test file in the format
+// compatible with `promtool test rules`.
+// See https://prometheus.io/docs/prometheus/latest/configuration/unit_testing_rules/
+type File struct {
+	RuleFiles          []string           `yaml:"rule_files"`
+	EvaluationInterval utils.PromDuration `yaml:"evaluation_interval"`
+	GroupEvalOrder     []string           `yaml:"group_eval_order"`
+	Tests              []TestGroup        `yaml:"tests"`
+}
+
+// TestGroup is a group of input series and test cases
+// which are evaluated against them.
+type TestGroup struct {
+	Name              string              `yaml:"name"`
+	Interval          utils.PromDuration  `yaml:"interval"`
+	InputSeries       []Series            `yaml:"input_series"`
+	AlertRuleTests    []AlertTestCase     `yaml:"alert_rule_test"`
+	MetricsqlExprTest []MetricsqlTestCase `yaml:"promql_expr_test"`
+	ExternalLabels    map[string]string   `yaml:"external_labels"`
+}
+
+// Series is an input series in the expanding notation.
+// For example:
+//
+//	series: 'up{job="foo"}'
+//	values: '1+1x3 _ stale'
+type Series struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+// AlertTestCase contains alerts expected to be firing
+// for the given alertname at the given eval time.
+type AlertTestCase struct {
+	EvalTime  utils.PromDuration `yaml:"eval_time"`
+	Alertname string             `yaml:"alertname"`
+	ExpAlerts []ExpAlert         `yaml:"exp_alerts"`
+}
+
+// ExpAlert is an expected alert.
+// Label `alertname` is added to ExpLabels automatically.
+type ExpAlert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+// MetricsqlTestCase contains samples expected to be
+// returned by the expression at the given eval time.
+type MetricsqlTestCase struct {
+	Expr       string             `yaml:"expr"`
+	EvalTime   utils.PromDuration `yaml:"eval_time"`
+	ExpSamples []ExpSample        `yaml:"exp_samples"`
+}
+
+// ExpSample is an expected sample. Labels are set
+// in the series selector form, e.g. `up{job="foo"}`.
+type ExpSample struct {
+	Labels string  `yaml:"labels"`
+	Value  float64 `yaml:"value"`
+}
+
+// ParseFile parses unit test file from the given path.
+// Paths in RuleFiles are resolved relative to the file directory.
+func ParseFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %w", err)
+	}
+	data = envtemplate.Replace(data)
+	f := &File{}
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, fmt.Errorf("cannot parse file: %w", err)
+	}
+	if len(f.RuleFiles) == 0 {
+		return nil, fmt.Errorf("rule_files can't be empty")
+	}
+	dir := filepath.Dir(path)
+	for i, rf := range f.RuleFiles {
+		if !filepath.IsAbs(rf) {
+			f.RuleFiles[i] = filepath.Join(dir, rf)
+		}
+	}
+	if f.EvaluationInterval.Duration() == 0 {
+		f.EvaluationInterval = utils.NewPromDuration(time.Minute)
+	}
+	for i := range f.Tests {
+		tg := &f.Tests[i]
+		if tg.Interval.Duration() == 0 {
+			tg.Interval = f.EvaluationInterval
+		}
+	}
+	return f, nil
+}
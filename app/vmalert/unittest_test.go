@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+)
+
+func TestUnitTest(t *testing.T) {
+	f := func(files []string, exp bool) {
+		t.Helper()
+		if got := unitTest(files); got != exp {
+			t.Fatalf("expected unitTest(%q) to return %v; got %v", files, exp, got)
+		}
+	}
+	f([]string{"./unittest/testdata/test-good.yaml"}, true)
+	f([]string{"./unittest/testdata/test-bad.yaml"}, false)
+	f([]string{"./unittest/testdata/test-good.yaml", "./unittest/testdata/test-bad.yaml"}, false)
+	f([]string{"./unittest/testdata/non-existing.yaml"}, false)
+}
+
+func TestOrderGroups(t *testing.T) {
+	groups := []config.Group{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	f := func(order []string, exp []string, expErr bool) {
+		t.Helper()
+		got, err := orderGroups(groups, order)
+		if expErr {
+			if err == nil {
+				t.Fatalf("expected to get an error for order %q", order)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var names []string
+		for _, g := range got {
+			names = append(names, g.Name)
+		}
+		if !reflect.DeepEqual(names, exp) {
+			t.Fatalf("expected to get %q; got %q", exp, names)
+		}
+	}
+	f(nil, []string{"a", "b", "c"}, false)
+	f([]string{"c"}, []string{"c", "a", "b"}, false)
+	f([]string{"b", "a"}, []string{"b", "a", "c"}, false)
+	f([]string{"d"}, nil, true)
+	f([]string{"a", "a"}, nil, true)
+}
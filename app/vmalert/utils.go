@@ -30,3 +30,12 @@ func newTimeSeries(values []float64, timestamps []int64, labels map[string]strin
 	}
 	return ts
 }
+
+// durationToString returns string representation of d
+// or empty string if d is zero
+func durationToString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// BearerTokenFileRefreshInterval is the interval for re-reading
+// bearer token file, so rotated tokens are picked up
+const BearerTokenFileRefreshInterval = time.Minute
+
+// BearerToken holds the token for `Authorization: Bearer` header.
+// The token is either static or read from file.
+type BearerToken struct {
+	file string
+
+	mu       sync.Mutex
+	token    string
+	lastRead time.Time
+}
+
+// NewBearerToken returns BearerToken for the given token or file.
+// It returns nil if both are empty. The file is read immediately,
+// so missing or empty files are detected on start.
+func NewBearerToken(token, file string) (*BearerToken, error) {
+	if token != "" && file != "" {
+		return nil, fmt.Errorf("bearer token and bearer token file cannot be set simultaneously")
+	}
+	if token == "" && file == "" {
+		return nil, nil
+	}
+	bt := &BearerToken{token: token, file: file}
+	if file != "" {
+		if err := bt.readFile(); err != nil {
+			return nil, err
+		}
+	}
+	return bt, nil
+}
+
+func (bt *BearerToken) readFile() error {
+	data, err := ioutil.ReadFile(bt.file)
+	if err != nil {
+		return fmt.Errorf("cannot read bearer token file %q: %w", bt.file, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("bearer token file %q is empty", bt.file)
+	}
+	bt.token = token
+	bt.lastRead = time.Now()
+	return nil
+}
+
+// Get returns the token. The token file is re-read
+// every BearerTokenFileRefreshInterval. The previous token
+// remains in use if the file cannot be read.
+func (bt *BearerToken) Get() string {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	if bt.file != "" && time.Since(bt.lastRead) > BearerTokenFileRefreshInterval {
+		if err := bt.readFile(); err != nil {
+			// the token value isn't logged, since it is a secret
+			logger.Errorf("cannot refresh bearer token: %s; previous token is used", err)
+			// postpone the next attempt for the refresh interval
+			bt.lastRead = time.Now()
+		}
+	}
+	return bt.token
+}
+
+// Refresh re-reads the token file, so the token rotated before
+// the refresh interval is picked up. It returns true if the token changed.
+// Static tokens aren't changed.
+func (bt *BearerToken) Refresh() (bool, error) {
+	if bt.file == "" {
+		return false, nil
+	}
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	prev := bt.token
+	if err := bt.readFile(); err != nil {
+		return false, err
+	}
+	return bt.token != prev, nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestBearerToken(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	writeToken := func(token string) {
+		t.Helper()
+		if err := ioutil.WriteFile(f.Name(), []byte(token), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeToken("foo\n")
+
+	if _, err := NewBearerToken("foo", f.Name()); err == nil {
+		t.Fatalf("expected to get error when both token and file are set")
+	}
+	if _, err := NewBearerToken("", f.Name()+".missing"); err == nil {
+		t.Fatalf("expected to get error for missing file")
+	}
+	if bt, err := NewBearerToken("", ""); bt != nil || err != nil {
+		t.Fatalf("expected to get nil token and no error; got %v and %v", bt, err)
+	}
+
+	bt, err := NewBearerToken("", f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkToken := func(exp string) {
+		t.Helper()
+		if got := bt.Get(); got != exp {
+			t.Fatalf("expected to get token %q; got %q", exp, got)
+		}
+	}
+	checkToken("foo")
+
+	// rotated token is picked up after the refresh interval
+	writeToken("bar")
+	checkToken("foo")
+	bt.lastRead = bt.lastRead.Add(-2 * BearerTokenFileRefreshInterval)
+	checkToken("bar")
+
+	// rotated token is picked up on explicit refresh
+	writeToken("baz")
+	if changed, err := bt.Refresh(); err != nil || !changed {
+		t.Fatalf("expected token to be changed; got %v, %v", changed, err)
+	}
+	checkToken("baz")
+	if changed, err := bt.Refresh(); err != nil || changed {
+		t.Fatalf("expected token to remain the same; got %v, %v", changed, err)
+	}
+
+	// the previous token is kept if the file disappears
+	_ = os.Remove(f.Name())
+	bt.lastRead = bt.lastRead.Add(-2 * BearerTokenFileRefreshInterval)
+	checkToken("baz")
+
+	static, err := NewBearerToken("foo", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed, err := static.Refresh(); err != nil || changed {
+		t.Fatalf("expected static token to remain the same; got %v, %v", changed, err)
+	}
+}
@@ -17,6 +17,14 @@ func (eg *ErrGroup) Add(err error) {
 	eg.errs = append(eg.errs, err)
 }
 
+// Errors returns the list of accumulated errors.
+func (eg *ErrGroup) Errors() []error {
+	if eg == nil {
+		return nil
+	}
+	return eg.errs
+}
+
 // Err checks if group contains at least
 // one error.
 func (eg *ErrGroup) Err() error {
@@ -34,5 +34,8 @@ func TestErrGroup(t *testing.T) {
 		if eg.Error() != tc.exp {
 			t.Fatalf("expected to have: \n%q\ngot:\n%q", tc.exp, eg.Error())
 		}
+		if len(eg.Errors()) != len(tc.errs) {
+			t.Fatalf("expected to get %d errors; got %d", len(tc.errs), len(eg.Errors()))
+		}
 	}
 }
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseHeaders parses headers in the form `header1:value1^^header2:value2`
+func ParseHeaders(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return ParseHeadersList(strings.Split(s, "^^"))
+}
+
+// ParseHeadersList parses headers in the form `Name: value`.
+// Errors don't contain header values, since they may contain secrets.
+func ParseHeadersList(headers []string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(headers))
+	for i, h := range headers {
+		n := strings.IndexByte(h, ':')
+		if n < 0 {
+			return nil, fmt.Errorf("missing ':' in header #%d; expecting `Name: value` format", i+1)
+		}
+		name := strings.TrimSpace(h[:n])
+		if name == "" {
+			return nil, fmt.Errorf("missing name in header #%d; expecting `Name: value` format", i+1)
+		}
+		result[name] = strings.TrimSpace(h[n+1:])
+	}
+	return result, nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	f := func(s string, exp map[string]string) {
+		t.Helper()
+		got, err := ParseHeaders(s)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("expected to get %v; got %v", exp, got)
+		}
+	}
+	f("", nil)
+	f("foo:bar", map[string]string{"foo": "bar"})
+	f("foo: bar^^X-Scope-OrgID: team-a", map[string]string{"foo": "bar", "X-Scope-OrgID": "team-a"})
+	f("Authorization: Bearer a:b", map[string]string{"Authorization": "Bearer a:b"})
+}
+
+func TestParseHeadersFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		_, err := ParseHeaders(s)
+		if err == nil {
+			t.Fatalf("expected to get error for %q", s)
+		}
+		// header values may contain secrets
+		if strings.Contains(err.Error(), "secret") {
+			t.Fatalf("error %q mustn't contain header value", err)
+		}
+	}
+	f("secret")
+	f(": secret")
+	f("foo:bar^^secret")
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oauth2TokenTimeout limits requests for obtaining OAuth2 tokens,
+// so unavailable token URL doesn't block requests using the token
+var oauth2TokenTimeout = 10 * time.Second
+
+// OAuth2Token obtains access tokens via OAuth2 client credentials flow.
+// Tokens are cached and refreshed before the expiry.
+type OAuth2Token struct {
+	secretFile string
+
+	mu  sync.Mutex
+	cfg *clientcredentials.Config
+	ts  oauth2.TokenSource
+}
+
+// NewOAuth2Token returns OAuth2Token for the given params.
+// It returns nil if clientID and tokenURL are empty.
+func NewOAuth2Token(clientID, secret, secretFile, tokenURL string, scopes []string) (*OAuth2Token, error) {
+	if clientID == "" && tokenURL == "" {
+		return nil, nil
+	}
+	if clientID == "" {
+		return nil, fmt.Errorf("client ID cannot be empty")
+	}
+	if tokenURL == "" {
+		return nil, fmt.Errorf("token URL cannot be empty")
+	}
+	if secret != "" && secretFile != "" {
+		return nil, fmt.Errorf("client secret and client secret file cannot be set simultaneously")
+	}
+	ot := &OAuth2Token{
+		secretFile: secretFile,
+		cfg: &clientcredentials.Config{
+			ClientID:     clientID,
+			ClientSecret: secret,
+			TokenURL:     tokenURL,
+			Scopes:       scopes,
+		},
+	}
+	if err := ot.Reset(); err != nil {
+		return nil, err
+	}
+	return ot, nil
+}
+
+// Get returns the cached access token or obtains a new one
+func (ot *OAuth2Token) Get() (string, error) {
+	ot.mu.Lock()
+	ts := ot.ts
+	ot.mu.Unlock()
+	t, err := ts.Token()
+	if err != nil {
+		return "", fmt.Errorf("cannot obtain OAuth2 token from %q: %w", ot.cfg.TokenURL, err)
+	}
+	return t.AccessToken, nil
+}
+
+// Reset drops the cached token, so the next Get call obtains a new one.
+// The client secret file is re-read, so rotated secrets are picked up.
+func (ot *OAuth2Token) Reset() error {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	if ot.secretFile != "" {
+		data, err := ioutil.ReadFile(ot.secretFile)
+		if err != nil {
+			return fmt.Errorf("cannot read OAuth2 client secret file %q: %w", ot.secretFile, err)
+		}
+		ot.cfg.ClientSecret = strings.TrimSpace(string(data))
+	}
+	// the context is kept by the token source for obtaining tokens in the future,
+	// so the requests are limited by the client timeout instead of the context
+	c := &http.Client{Timeout: oauth2TokenTimeout}
+	ot.ts = ot.cfg.TokenSource(context.WithValue(context.Background(), oauth2.HTTPClient, c))
+	return nil
+}
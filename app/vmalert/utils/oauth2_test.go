@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOAuth2Token(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"foo","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+
+	ot, err := NewOAuth2Token("id", "secret", "", srv.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	token, err := ot.Get()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if token != "foo" {
+		t.Fatalf("expected token %q; got %q", "foo", token)
+	}
+}
+
+func TestOAuth2Token_Timeout(t *testing.T) {
+	defer func(d time.Duration) { oauth2TokenTimeout = d }(oauth2TokenTimeout)
+	oauth2TokenTimeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ot, err := NewOAuth2Token("id", "secret", "", srv.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	start := time.Now()
+	if _, err := ot.Get(); err == nil {
+		t.Fatalf("expected to get error for the unresponsive token URL")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("expected Get to return after the timeout; it took %s", d)
+	}
+}
@@ -24,13 +24,18 @@ func Transport(URL, certFile, keyFile, CAFile, serverName string, insecureSkipVe
 	return t, nil
 }
 
-// TLSConfig creates tls.Config object from provided arguments
+// TLSConfig creates tls.Config object from provided arguments.
+// Errors don't refer to option names, so callers
+// must wrap them with the names of their options.
 func TLSConfig(certFile, keyFile, CAFile, serverName string, insecureSkipVerify bool) (*tls.Config, error) {
 	var certs []tls.Certificate
+	if certFile == "" && keyFile != "" {
+		return nil, fmt.Errorf("TLS key file %q is set without TLS certificate file", keyFile)
+	}
 	if certFile != "" {
 		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 		if err != nil {
-			return nil, fmt.Errorf("cannot load TLS certificate from `cert_file`=%q, `key_file`=%q: %w", certFile, keyFile, err)
+			return nil, fmt.Errorf("cannot load TLS certificate from certificate file %q and key file %q: %w", certFile, keyFile, err)
 		}
 
 		certs = []tls.Certificate{cert}
@@ -40,12 +45,12 @@ func TLSConfig(certFile, keyFile, CAFile, serverName string, insecureSkipVerify
 	if CAFile != "" {
 		pem, err := ioutil.ReadFile(CAFile)
 		if err != nil {
-			return nil, fmt.Errorf("cannot read `ca_file` %q: %w", CAFile, err)
+			return nil, fmt.Errorf("cannot read TLS CA file %q: %w", CAFile, err)
 		}
 
 		rootCAs = x509.NewCertPool()
 		if !rootCAs.AppendCertsFromPEM(pem) {
-			return nil, fmt.Errorf("cannot parse data from `ca_file` %q", CAFile)
+			return nil, fmt.Errorf("cannot parse data from TLS CA file %q", CAFile)
 		}
 	}
 
@@ -27,6 +27,12 @@ func TestTLSConfig(t *testing.T) {
 		t.Errorf("expected keypair error, got nil")
 	}
 	certFile = ""
+	keyFile = "/path/to/key/file"
+	_, err = TLSConfig(certFile, keyFile, CAFile, serverName, insecureSkipVerify)
+	if err == nil {
+		t.Errorf("expected missing cert error, got nil")
+	}
+	keyFile = ""
 	CAFile = "/path/to/nonexisting/cert/file"
 	_, err = TLSConfig(certFile, keyFile, CAFile, serverName, insecureSkipVerify)
 	if err == nil {
@@ -8,13 +8,15 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
 )
 
 type requestHandler struct {
 	m *manager
+	// reloadCh is used for sending config reload requests.
+	// The result of reload is sent back via the passed channel.
+	reloadCh chan<- chan error
 }
 
 func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
@@ -25,11 +27,15 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		}
 		WriteWelcome(w, [][2]string{
 			{"/api/v1/groups", "list all loaded groups and rules"},
+			{"/api/v1/rules", "alias for /api/v1/groups"},
+			{"/api/v1/rules/config", "effective configuration of loaded groups and rules in YAML"},
 			{"/api/v1/alerts", "list all active alerts"},
 			{"/api/v1/groupID/alertID/status", "get alert status by ID"},
+			{"/api/v1/rule?group_id=groupID&rule_id=ruleID", "get rule's state updates by ID"},
+			{"/api/v1/datasource/stats?topN=20", "the most expensive datasource queries over the last hour"},
 			{"/metrics", "list of application metrics"},
 			{"/-/reload", "reload configuration"},
-		})
+		}, getConfigStatus(), startupDatasourceStatus)
 		return true
 	case "/alerts":
 		WriteListAlerts(w, rh.groupAlerts())
@@ -37,7 +43,7 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 	case "/groups":
 		WriteListGroups(w, rh.groups())
 		return true
-	case "/api/v1/groups":
+	case "/api/v1/groups", "/api/v1/rules":
 		data, err := rh.listGroups()
 		if err != nil {
 			httpserver.Errorf(w, r, "%s", err)
@@ -46,6 +52,33 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Write(data)
 		return true
+	case "/api/v1/rules/config":
+		data, err := rh.effectiveConfig()
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+		return true
+	case "/rule", "/api/v1/rule":
+		rule, err := rh.getRule(r)
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		if r.URL.Path == "/rule" {
+			WriteRuleDetails(w, rule)
+			return true
+		}
+		data, err := json.Marshal(rule)
+		if err != nil {
+			httpserver.Errorf(w, r, "failed to marshal rule: %s", err)
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(data)
+		return true
 	case "/api/v1/alerts":
 		data, err := rh.listAlerts()
 		if err != nil {
@@ -55,10 +88,31 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Write(data)
 		return true
+	case "/api/v1/notifiers":
+		data, err := listNotifiers()
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(data)
+		return true
+	case "/api/v1/datasource/stats":
+		data, err := listQueryStats(r)
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(data)
+		return true
 	case "/-/reload":
-		logger.Infof("api config reload was called, sending sighup")
-		procutil.SelfSIGHUP()
+		if err := rh.reload(r); err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
 		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "OK")
 		return true
 	default:
 		if !strings.HasSuffix(r.URL.Path, "/status") {
@@ -88,6 +142,25 @@ func (rh *requestHandler) handler(w http.ResponseWriter, r *http.Request) bool {
 	}
 }
 
+// reload sends config reload request and waits for its result.
+func (rh *requestHandler) reload(r *http.Request) error {
+	respCh := make(chan error, 1)
+	select {
+	case rh.reloadCh <- respCh:
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+	select {
+	case err := <-respCh:
+		if err != nil {
+			return errResponse(fmt.Errorf("failed to reload config: %w", err), http.StatusInternalServerError)
+		}
+		return nil
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
+
 type listGroupsResponse struct {
 	Data struct {
 		Groups []APIGroup `json:"groups"`
@@ -124,6 +197,56 @@ func (rh *requestHandler) listGroups() ([]byte, error) {
 	return b, nil
 }
 
+type listNotifiersResponse struct {
+	Data struct {
+		Targets []APINotifierTarget `json:"targets"`
+	} `json:"data"`
+	Status string `json:"status"`
+}
+
+// listNotifiers returns JSON with the current notifier targets
+// including the targets discovered via -notifier.config
+func listNotifiers() ([]byte, error) {
+	lr := listNotifiersResponse{Status: "success"}
+	lr.Data.Targets = make([]APINotifierTarget, 0)
+	for _, t := range notifier.GetTargets() {
+		lr.Data.Targets = append(lr.Data.Targets, APINotifierTarget{
+			Address: t.Addr,
+			Name:    t.Name,
+			Source:  t.Source,
+			Labels:  t.Labels,
+
+			LastSuccessAt: t.LastSuccessAt,
+			LastError:     t.LastError,
+			LastErrorAt:   t.LastErrorAt,
+			FailingSince:  t.FailingSince,
+		})
+	}
+	b, err := json.Marshal(lr)
+	if err != nil {
+		return nil, errResponse(fmt.Errorf("error encoding list of notifiers: %w", err), http.StatusInternalServerError)
+	}
+	return b, nil
+}
+
+func (rh *requestHandler) effectiveConfig() ([]byte, error) {
+	rh.m.groupsMu.RLock()
+	groups := make([]*Group, 0, len(rh.m.groups))
+	for _, g := range rh.m.groups {
+		groups = append(groups, g)
+	}
+	rh.m.groupsMu.RUnlock()
+
+	b, err := marshalEffectiveConfig(groups)
+	if err != nil {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        err,
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	return b, nil
+}
+
 type listAlertsResponse struct {
 	Data struct {
 		Alerts []*APIAlert `json:"alerts"`
@@ -211,6 +334,22 @@ func (rh *requestHandler) alertByPath(path string) (*APIAlert, error) {
 	return resp, nil
 }
 
+func (rh *requestHandler) getRule(r *http.Request) (APIRuleDetails, error) {
+	groupID, err := strconv.ParseUint(r.FormValue("group_id"), 10, 0)
+	if err != nil {
+		return APIRuleDetails{}, badRequest(fmt.Errorf(`cannot parse group_id: %w`, err))
+	}
+	ruleID, err := strconv.ParseUint(r.FormValue("rule_id"), 10, 0)
+	if err != nil {
+		return APIRuleDetails{}, badRequest(fmt.Errorf(`cannot parse rule_id: %w`, err))
+	}
+	rule, err := rh.m.RuleAPI(groupID, ruleID)
+	if err != nil {
+		return APIRuleDetails{}, errResponse(err, http.StatusNotFound)
+	}
+	return rule, nil
+}
+
 func uint64FromPath(path string) (uint64, error) {
 	s := strings.TrimRight(path, "/")
 	return strconv.ParseUint(s, 10, 0)
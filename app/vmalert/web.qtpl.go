@@ -34,7 +34,7 @@ var navItems = []tpl.NavItem{
 }
 
 //line app/vmalert/web.qtpl:20
-func StreamWelcome(qw422016 *qt422016.Writer, pathList [][2]string) {
+func StreamWelcome(qw422016 *qt422016.Writer, pathList [][2]string, cs configStatus, ds datasourceStatus) {
 //line app/vmalert/web.qtpl:20
 	qw422016.N().S(`
     `)
@@ -74,69 +74,130 @@ func StreamWelcome(qw422016 *qt422016.Writer, pathList [][2]string) {
 //line app/vmalert/web.qtpl:29
 	qw422016.N().S(`
     </p>
+    <p>
+        Config:<br>
+        Last reload: `)
+//line app/vmalert/web.qtpl:33
+	if cs.LastReloadSuccessful {
+//line app/vmalert/web.qtpl:33
+		qw422016.N().S(`successful`)
+//line app/vmalert/web.qtpl:33
+	} else {
+//line app/vmalert/web.qtpl:33
+		qw422016.N().S(`<span class="text-danger">failed</span>`)
+//line app/vmalert/web.qtpl:33
+	}
+//line app/vmalert/web.qtpl:33
+	qw422016.N().S(`<br/>
+        Last successful reload: `)
+//line app/vmalert/web.qtpl:34
+	qw422016.E().S(cs.LastSuccess.Format(time.RFC3339))
+//line app/vmalert/web.qtpl:34
+	qw422016.N().S(`<br/>
+        Hash: `)
+//line app/vmalert/web.qtpl:35
+	qw422016.E().S(cs.Hash)
+//line app/vmalert/web.qtpl:35
+	qw422016.N().S(`<br/>
+    </p>
+    `)
+//line app/vmalert/web.qtpl:37
+	if ds.Checked {
+//line app/vmalert/web.qtpl:37
+		qw422016.N().S(`
+    <p>
+        Datasource:<br>
+        Check on start: `)
+//line app/vmalert/web.qtpl:40
+		if ds.Err == "" {
+//line app/vmalert/web.qtpl:40
+			qw422016.N().S(`successful`)
+//line app/vmalert/web.qtpl:40
+		} else {
+//line app/vmalert/web.qtpl:40
+			qw422016.N().S(`<span class="text-danger">failed: `)
+//line app/vmalert/web.qtpl:40
+			qw422016.E().S(ds.Err)
+//line app/vmalert/web.qtpl:40
+			qw422016.N().S(`</span>`)
+//line app/vmalert/web.qtpl:40
+		}
+//line app/vmalert/web.qtpl:40
+		qw422016.N().S(`<br/>
+        Checked at: `)
+//line app/vmalert/web.qtpl:41
+		qw422016.E().S(ds.Time.Format(time.RFC3339))
+//line app/vmalert/web.qtpl:41
+		qw422016.N().S(`<br/>
+    </p>
+    `)
+//line app/vmalert/web.qtpl:43
+	}
+//line app/vmalert/web.qtpl:43
+	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:31
+//line app/vmalert/web.qtpl:44
 	tpl.StreamFooter(qw422016)
-//line app/vmalert/web.qtpl:31
+//line app/vmalert/web.qtpl:44
 	qw422016.N().S(`
 `)
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
 }
 
-//line app/vmalert/web.qtpl:32
-func WriteWelcome(qq422016 qtio422016.Writer, pathList [][2]string) {
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
+func WriteWelcome(qq422016 qtio422016.Writer, pathList [][2]string, cs configStatus, ds datasourceStatus) {
+//line app/vmalert/web.qtpl:45
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:32
-	StreamWelcome(qw422016, pathList)
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
+	StreamWelcome(qw422016, pathList, cs, ds)
+//line app/vmalert/web.qtpl:45
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
 }
 
-//line app/vmalert/web.qtpl:32
-func Welcome(pathList [][2]string) string {
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
+func Welcome(pathList [][2]string, cs configStatus, ds datasourceStatus) string {
+//line app/vmalert/web.qtpl:45
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:32
-	WriteWelcome(qb422016, pathList)
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
+	WriteWelcome(qb422016, pathList, cs, ds)
+//line app/vmalert/web.qtpl:45
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
 	return qs422016
-//line app/vmalert/web.qtpl:32
+//line app/vmalert/web.qtpl:45
 }
 
-//line app/vmalert/web.qtpl:34
+//line app/vmalert/web.qtpl:47
 func StreamListGroups(qw422016 *qt422016.Writer, groups []APIGroup) {
-//line app/vmalert/web.qtpl:34
+//line app/vmalert/web.qtpl:47
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:35
+//line app/vmalert/web.qtpl:48
 	tpl.StreamHeader(qw422016, "Groups", navItems)
-//line app/vmalert/web.qtpl:35
+//line app/vmalert/web.qtpl:48
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:36
+//line app/vmalert/web.qtpl:49
 	if len(groups) > 0 {
-//line app/vmalert/web.qtpl:36
+//line app/vmalert/web.qtpl:49
 		qw422016.N().S(`
         `)
-//line app/vmalert/web.qtpl:38
+//line app/vmalert/web.qtpl:51
 		rOk := make(map[string]int)
 		rNotOk := make(map[string]int)
 		for _, g := range groups {
 			for _, r := range g.AlertingRules {
-				if r.LastError != "" {
+				if r.Health == "err" {
 					rNotOk[g.Name]++
 				} else {
 					rOk[g.Name]++
 				}
 			}
 			for _, r := range g.RecordingRules {
-				if r.LastError != "" {
+				if r.Health == "err" {
 					rNotOk[g.Name]++
 				} else {
 					rOk[g.Name]++
@@ -144,84 +205,84 @@ func StreamListGroups(qw422016 *qt422016.Writer, groups []APIGroup) {
 			}
 		}
 
-//line app/vmalert/web.qtpl:56
+//line app/vmalert/web.qtpl:69
 		qw422016.N().S(`
          <a class="btn btn-primary" role="button" onclick="collapseAll()">Collapse All</a>
          <a class="btn btn-primary" role="button" onclick="expandAll()">Expand All</a>
         `)
-//line app/vmalert/web.qtpl:59
+//line app/vmalert/web.qtpl:72
 		for _, g := range groups {
-//line app/vmalert/web.qtpl:59
+//line app/vmalert/web.qtpl:72
 			qw422016.N().S(`
               <div class="group-heading`)
-//line app/vmalert/web.qtpl:60
+//line app/vmalert/web.qtpl:73
 			if rNotOk[g.Name] > 0 {
-//line app/vmalert/web.qtpl:60
+//line app/vmalert/web.qtpl:73
 				qw422016.N().S(` alert-danger`)
-//line app/vmalert/web.qtpl:60
+//line app/vmalert/web.qtpl:73
 			}
-//line app/vmalert/web.qtpl:60
+//line app/vmalert/web.qtpl:73
 			qw422016.N().S(`"  data-bs-target="rules-`)
-//line app/vmalert/web.qtpl:60
+//line app/vmalert/web.qtpl:73
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:60
+//line app/vmalert/web.qtpl:73
 			qw422016.N().S(`">
                 <span class="anchor" id="group-`)
-//line app/vmalert/web.qtpl:61
+//line app/vmalert/web.qtpl:74
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:61
+//line app/vmalert/web.qtpl:74
 			qw422016.N().S(`"></span>
                 <a href="#group-`)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			qw422016.E().S(g.Name)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			if g.Type != "prometheus" {
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 				qw422016.N().S(` (`)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 				qw422016.E().S(g.Type)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 				qw422016.N().S(`)`)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			}
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			qw422016.N().S(` (every `)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			qw422016.E().S(g.Interval)
-//line app/vmalert/web.qtpl:62
+//line app/vmalert/web.qtpl:75
 			qw422016.N().S(`)</a>
                  `)
-//line app/vmalert/web.qtpl:63
+//line app/vmalert/web.qtpl:76
 			if rNotOk[g.Name] > 0 {
-//line app/vmalert/web.qtpl:63
+//line app/vmalert/web.qtpl:76
 				qw422016.N().S(`<span class="badge bg-danger" title="Number of rules withs status Error">`)
-//line app/vmalert/web.qtpl:63
+//line app/vmalert/web.qtpl:76
 				qw422016.N().D(rNotOk[g.Name])
-//line app/vmalert/web.qtpl:63
+//line app/vmalert/web.qtpl:76
 				qw422016.N().S(`</span> `)
-//line app/vmalert/web.qtpl:63
+//line app/vmalert/web.qtpl:76
 			}
-//line app/vmalert/web.qtpl:63
+//line app/vmalert/web.qtpl:76
 			qw422016.N().S(`
                 <span class="badge bg-success" title="Number of rules withs status Ok">`)
-//line app/vmalert/web.qtpl:64
+//line app/vmalert/web.qtpl:77
 			qw422016.N().D(rOk[g.Name])
-//line app/vmalert/web.qtpl:64
+//line app/vmalert/web.qtpl:77
 			qw422016.N().S(`</span>
                 <p class="fs-6 fw-lighter">`)
-//line app/vmalert/web.qtpl:65
+//line app/vmalert/web.qtpl:78
 			qw422016.E().S(g.File)
-//line app/vmalert/web.qtpl:65
+//line app/vmalert/web.qtpl:78
 			qw422016.N().S(`</p>
             </div>
             <div class="collapse" id="rules-`)
-//line app/vmalert/web.qtpl:67
+//line app/vmalert/web.qtpl:80
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:67
+//line app/vmalert/web.qtpl:80
 			qw422016.N().S(`">
                 <table class="table table-striped table-hover table-sm">
                     <thead>
@@ -234,280 +295,360 @@ func StreamListGroups(qw422016 *qt422016.Writer, groups []APIGroup) {
                     </thead>
                     <tbody>
                     `)
-//line app/vmalert/web.qtpl:78
+//line app/vmalert/web.qtpl:91
 			for _, ar := range g.AlertingRules {
-//line app/vmalert/web.qtpl:78
+//line app/vmalert/web.qtpl:91
 				qw422016.N().S(`
                         <tr`)
-//line app/vmalert/web.qtpl:79
-				if ar.LastError != "" {
-//line app/vmalert/web.qtpl:79
+//line app/vmalert/web.qtpl:92
+				if ar.Health == "err" {
+//line app/vmalert/web.qtpl:92
 					qw422016.N().S(` class="alert-danger"`)
-//line app/vmalert/web.qtpl:79
+//line app/vmalert/web.qtpl:92
+				} else if ar.LastError != "" {
+//line app/vmalert/web.qtpl:92
+					qw422016.N().S(` class="alert-warning"`)
+//line app/vmalert/web.qtpl:92
 				}
-//line app/vmalert/web.qtpl:79
+//line app/vmalert/web.qtpl:92
 				qw422016.N().S(`>
                             <td>
-                                <b>alert:</b> `)
-//line app/vmalert/web.qtpl:81
+                                <b>alert:</b> <a href="/rule?group_id=`)
+//line app/vmalert/web.qtpl:94
+				qw422016.E().S(g.ID)
+//line app/vmalert/web.qtpl:94
+				qw422016.N().S(`&rule_id=`)
+//line app/vmalert/web.qtpl:94
+				qw422016.E().S(ar.ID)
+//line app/vmalert/web.qtpl:94
+				qw422016.N().S(`">`)
+//line app/vmalert/web.qtpl:94
 				qw422016.E().S(ar.Name)
-//line app/vmalert/web.qtpl:81
-				qw422016.N().S(` (for: `)
-//line app/vmalert/web.qtpl:81
+//line app/vmalert/web.qtpl:94
+				qw422016.N().S(`</a> (for: `)
+//line app/vmalert/web.qtpl:94
 				qw422016.E().V(ar.For)
-//line app/vmalert/web.qtpl:81
+//line app/vmalert/web.qtpl:94
+				if ar.Timeout != "" {
+//line app/vmalert/web.qtpl:94
+					qw422016.N().S(`, timeout: `)
+//line app/vmalert/web.qtpl:94
+					qw422016.E().S(ar.Timeout)
+//line app/vmalert/web.qtpl:94
+				}
+//line app/vmalert/web.qtpl:94
 				qw422016.N().S(`)<br>
                                 <code><pre>`)
-//line app/vmalert/web.qtpl:82
+//line app/vmalert/web.qtpl:95
 				qw422016.E().S(ar.Expression)
-//line app/vmalert/web.qtpl:82
+//line app/vmalert/web.qtpl:95
 				qw422016.N().S(`</pre></code><br>
                                 `)
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:96
 				if len(ar.Labels) > 0 {
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:96
 					qw422016.N().S(` <b>Labels:</b>`)
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:96
 				}
-//line app/vmalert/web.qtpl:83
+//line app/vmalert/web.qtpl:96
 				qw422016.N().S(`
                                 `)
-//line app/vmalert/web.qtpl:84
+//line app/vmalert/web.qtpl:97
 				for k, v := range ar.Labels {
-//line app/vmalert/web.qtpl:84
+//line app/vmalert/web.qtpl:97
 					qw422016.N().S(`
                                         <span class="ms-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:85
+//line app/vmalert/web.qtpl:98
 					qw422016.E().S(k)
-//line app/vmalert/web.qtpl:85
+//line app/vmalert/web.qtpl:98
 					qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:85
+//line app/vmalert/web.qtpl:98
 					qw422016.E().S(v)
-//line app/vmalert/web.qtpl:85
+//line app/vmalert/web.qtpl:98
 					qw422016.N().S(`</span>
                                 `)
-//line app/vmalert/web.qtpl:86
+//line app/vmalert/web.qtpl:99
 				}
-//line app/vmalert/web.qtpl:86
+//line app/vmalert/web.qtpl:99
 				qw422016.N().S(`
                             </td>
                             <td><div class="error-cell">`)
-//line app/vmalert/web.qtpl:88
+//line app/vmalert/web.qtpl:101
 				qw422016.E().S(ar.LastError)
-//line app/vmalert/web.qtpl:88
+//line app/vmalert/web.qtpl:101
+				if ar.LastFailures > 1 {
+//line app/vmalert/web.qtpl:101
+					qw422016.N().S(` (failed `)
+//line app/vmalert/web.qtpl:101
+					qw422016.N().D(ar.LastFailures)
+//line app/vmalert/web.qtpl:101
+					qw422016.N().S(` times in a row)`)
+//line app/vmalert/web.qtpl:101
+				}
+//line app/vmalert/web.qtpl:101
 				qw422016.N().S(`</div></td>
                             <td>`)
-//line app/vmalert/web.qtpl:89
+//line app/vmalert/web.qtpl:102
 				qw422016.N().D(ar.LastSamples)
-//line app/vmalert/web.qtpl:89
+//line app/vmalert/web.qtpl:102
 				qw422016.N().S(`</td>
                             <td>`)
-//line app/vmalert/web.qtpl:90
+//line app/vmalert/web.qtpl:103
 				qw422016.N().FPrec(time.Since(ar.LastExec).Seconds(), 3)
-//line app/vmalert/web.qtpl:90
-				qw422016.N().S(`s ago</td>
+//line app/vmalert/web.qtpl:103
+				qw422016.N().S(`s ago<br><small>took `)
+//line app/vmalert/web.qtpl:103
+				qw422016.N().FPrec(ar.LastDuration, 3)
+//line app/vmalert/web.qtpl:103
+				qw422016.N().S(`s</small></td>
                         </tr>
                     `)
-//line app/vmalert/web.qtpl:92
+//line app/vmalert/web.qtpl:105
 			}
-//line app/vmalert/web.qtpl:92
+//line app/vmalert/web.qtpl:105
 			qw422016.N().S(`
                     `)
-//line app/vmalert/web.qtpl:93
+//line app/vmalert/web.qtpl:106
 			for _, rr := range g.RecordingRules {
-//line app/vmalert/web.qtpl:93
+//line app/vmalert/web.qtpl:106
 				qw422016.N().S(`
-                        <tr>
+                        <tr`)
+//line app/vmalert/web.qtpl:107
+				if rr.Health == "err" {
+//line app/vmalert/web.qtpl:107
+					qw422016.N().S(` class="alert-danger"`)
+//line app/vmalert/web.qtpl:107
+				} else if rr.LastError != "" {
+//line app/vmalert/web.qtpl:107
+					qw422016.N().S(` class="alert-warning"`)
+//line app/vmalert/web.qtpl:107
+				}
+//line app/vmalert/web.qtpl:107
+				qw422016.N().S(`>
                             <td>
-                                <b>record:</b> `)
-//line app/vmalert/web.qtpl:96
+                                <b>record:</b> <a href="/rule?group_id=`)
+//line app/vmalert/web.qtpl:109
+				qw422016.E().S(g.ID)
+//line app/vmalert/web.qtpl:109
+				qw422016.N().S(`&rule_id=`)
+//line app/vmalert/web.qtpl:109
+				qw422016.E().S(rr.ID)
+//line app/vmalert/web.qtpl:109
+				qw422016.N().S(`">`)
+//line app/vmalert/web.qtpl:109
 				qw422016.E().S(rr.Name)
-//line app/vmalert/web.qtpl:96
+//line app/vmalert/web.qtpl:109
+				qw422016.N().S(`</a>`)
+//line app/vmalert/web.qtpl:109
+				if rr.Timeout != "" {
+//line app/vmalert/web.qtpl:109
+					qw422016.N().S(` (timeout: `)
+//line app/vmalert/web.qtpl:109
+					qw422016.E().S(rr.Timeout)
+//line app/vmalert/web.qtpl:109
+					qw422016.N().S(`)`)
+//line app/vmalert/web.qtpl:109
+				}
+//line app/vmalert/web.qtpl:109
 				qw422016.N().S(`<br>
                                 <code><pre>`)
-//line app/vmalert/web.qtpl:97
+//line app/vmalert/web.qtpl:110
 				qw422016.E().S(rr.Expression)
-//line app/vmalert/web.qtpl:97
+//line app/vmalert/web.qtpl:110
 				qw422016.N().S(`</pre></code>
                                 `)
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:111
 				if len(rr.Labels) > 0 {
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:111
 					qw422016.N().S(` <b>Labels:</b>`)
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:111
 				}
-//line app/vmalert/web.qtpl:98
+//line app/vmalert/web.qtpl:111
 				qw422016.N().S(`
                                 `)
-//line app/vmalert/web.qtpl:99
+//line app/vmalert/web.qtpl:112
 				for k, v := range rr.Labels {
-//line app/vmalert/web.qtpl:99
+//line app/vmalert/web.qtpl:112
 					qw422016.N().S(`
                                         <span class="ms-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:100
+//line app/vmalert/web.qtpl:113
 					qw422016.E().S(k)
-//line app/vmalert/web.qtpl:100
+//line app/vmalert/web.qtpl:113
 					qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:100
+//line app/vmalert/web.qtpl:113
 					qw422016.E().S(v)
-//line app/vmalert/web.qtpl:100
+//line app/vmalert/web.qtpl:113
 					qw422016.N().S(`</span>
                                 `)
-//line app/vmalert/web.qtpl:101
+//line app/vmalert/web.qtpl:114
 				}
-//line app/vmalert/web.qtpl:101
+//line app/vmalert/web.qtpl:114
 				qw422016.N().S(`
                             </td>
                             <td><div class="error-cell">`)
-//line app/vmalert/web.qtpl:103
+//line app/vmalert/web.qtpl:116
 				qw422016.E().S(rr.LastError)
-//line app/vmalert/web.qtpl:103
+//line app/vmalert/web.qtpl:116
+				if rr.LastFailures > 1 {
+//line app/vmalert/web.qtpl:116
+					qw422016.N().S(` (failed `)
+//line app/vmalert/web.qtpl:116
+					qw422016.N().D(rr.LastFailures)
+//line app/vmalert/web.qtpl:116
+					qw422016.N().S(` times in a row)`)
+//line app/vmalert/web.qtpl:116
+				}
+//line app/vmalert/web.qtpl:116
 				qw422016.N().S(`</div></td>
                             <td>`)
-//line app/vmalert/web.qtpl:104
+//line app/vmalert/web.qtpl:117
 				qw422016.N().D(rr.LastSamples)
-//line app/vmalert/web.qtpl:104
+//line app/vmalert/web.qtpl:117
 				qw422016.N().S(`</td>
                             <td>`)
-//line app/vmalert/web.qtpl:105
+//line app/vmalert/web.qtpl:118
 				qw422016.N().FPrec(time.Since(rr.LastExec).Seconds(), 3)
-//line app/vmalert/web.qtpl:105
-				qw422016.N().S(`s ago</td>
+//line app/vmalert/web.qtpl:118
+				qw422016.N().S(`s ago<br><small>took `)
+//line app/vmalert/web.qtpl:118
+				qw422016.N().FPrec(rr.LastDuration, 3)
+//line app/vmalert/web.qtpl:118
+				qw422016.N().S(`s</small></td>
                         </tr>
                     `)
-//line app/vmalert/web.qtpl:107
+//line app/vmalert/web.qtpl:120
 			}
-//line app/vmalert/web.qtpl:107
+//line app/vmalert/web.qtpl:120
 			qw422016.N().S(`
                  </tbody>
                 </table>
             </div>
         `)
-//line app/vmalert/web.qtpl:111
+//line app/vmalert/web.qtpl:124
 		}
-//line app/vmalert/web.qtpl:111
+//line app/vmalert/web.qtpl:124
 		qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:113
+//line app/vmalert/web.qtpl:126
 	} else {
-//line app/vmalert/web.qtpl:113
+//line app/vmalert/web.qtpl:126
 		qw422016.N().S(`
         <div>
             <p>No items...</p>
         </div>
     `)
-//line app/vmalert/web.qtpl:117
+//line app/vmalert/web.qtpl:130
 	}
-//line app/vmalert/web.qtpl:117
+//line app/vmalert/web.qtpl:130
 	qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:132
 	tpl.StreamFooter(qw422016)
-//line app/vmalert/web.qtpl:119
+//line app/vmalert/web.qtpl:132
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 }
 
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 func WriteListGroups(qq422016 qtio422016.Writer, groups []APIGroup) {
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	StreamListGroups(qw422016, groups)
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 }
 
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 func ListGroups(groups []APIGroup) string {
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	WriteListGroups(qb422016, groups)
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 	return qs422016
-//line app/vmalert/web.qtpl:121
+//line app/vmalert/web.qtpl:134
 }
 
-//line app/vmalert/web.qtpl:124
+//line app/vmalert/web.qtpl:137
 func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
-//line app/vmalert/web.qtpl:124
+//line app/vmalert/web.qtpl:137
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:125
+//line app/vmalert/web.qtpl:138
 	tpl.StreamHeader(qw422016, "Alerts", navItems)
-//line app/vmalert/web.qtpl:125
+//line app/vmalert/web.qtpl:138
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:126
+//line app/vmalert/web.qtpl:139
 	if len(groupAlerts) > 0 {
-//line app/vmalert/web.qtpl:126
+//line app/vmalert/web.qtpl:139
 		qw422016.N().S(`
          <a class="btn btn-primary" role="button" onclick="collapseAll()">Collapse All</a>
          <a class="btn btn-primary" role="button" onclick="expandAll()">Expand All</a>
          `)
-//line app/vmalert/web.qtpl:129
+//line app/vmalert/web.qtpl:142
 		for _, ga := range groupAlerts {
-//line app/vmalert/web.qtpl:129
+//line app/vmalert/web.qtpl:142
 			qw422016.N().S(`
             `)
-//line app/vmalert/web.qtpl:130
+//line app/vmalert/web.qtpl:143
 			g := ga.Group
 
-//line app/vmalert/web.qtpl:130
+//line app/vmalert/web.qtpl:143
 			qw422016.N().S(`
             <div class="group-heading alert-danger" data-bs-target="rules-`)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:144
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:131
+//line app/vmalert/web.qtpl:144
 			qw422016.N().S(`">
                 <span class="anchor" id="group-`)
-//line app/vmalert/web.qtpl:132
+//line app/vmalert/web.qtpl:145
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:132
+//line app/vmalert/web.qtpl:145
 			qw422016.N().S(`"></span>
                 <a href="#group-`)
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 			qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 			qw422016.E().S(g.Name)
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 			if g.Type != "prometheus" {
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 				qw422016.N().S(` (`)
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 				qw422016.E().S(g.Type)
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 				qw422016.N().S(`)`)
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 			}
-//line app/vmalert/web.qtpl:133
+//line app/vmalert/web.qtpl:146
 			qw422016.N().S(`</a>
                 <span class="badge bg-danger" title="Number of active alerts">`)
-//line app/vmalert/web.qtpl:134
+//line app/vmalert/web.qtpl:147
 			qw422016.N().D(len(ga.Alerts))
-//line app/vmalert/web.qtpl:134
+//line app/vmalert/web.qtpl:147
 			qw422016.N().S(`</span>
                 <br>
                 <p class="fs-6 fw-lighter">`)
-//line app/vmalert/web.qtpl:136
+//line app/vmalert/web.qtpl:149
 			qw422016.E().S(g.File)
-//line app/vmalert/web.qtpl:136
+//line app/vmalert/web.qtpl:149
 			qw422016.N().S(`</p>
             </div>
             `)
-//line app/vmalert/web.qtpl:139
+//line app/vmalert/web.qtpl:152
 			var keys []string
 			alertsByRule := make(map[string][]*APIAlert)
 			for _, alert := range ga.Alerts {
@@ -518,20 +659,20 @@ func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
 			}
 			sort.Strings(keys)
 
-//line app/vmalert/web.qtpl:148
+//line app/vmalert/web.qtpl:161
 			qw422016.N().S(`
             <div class="collapse" id="rules-`)
-//line app/vmalert/web.qtpl:149
+//line app/vmalert/web.qtpl:162
 			qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:149
+//line app/vmalert/web.qtpl:162
 			qw422016.N().S(`">
                 `)
-//line app/vmalert/web.qtpl:150
+//line app/vmalert/web.qtpl:163
 			for _, ruleID := range keys {
-//line app/vmalert/web.qtpl:150
+//line app/vmalert/web.qtpl:163
 				qw422016.N().S(`
                     `)
-//line app/vmalert/web.qtpl:152
+//line app/vmalert/web.qtpl:165
 				defaultAR := alertsByRule[ruleID][0]
 				var labelKeys []string
 				for k := range defaultAR.Labels {
@@ -539,22 +680,22 @@ func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
 				}
 				sort.Strings(labelKeys)
 
-//line app/vmalert/web.qtpl:158
+//line app/vmalert/web.qtpl:171
 				qw422016.N().S(`
                     <br>
                     <b>alert:</b> `)
-//line app/vmalert/web.qtpl:160
+//line app/vmalert/web.qtpl:173
 				qw422016.E().S(defaultAR.Name)
-//line app/vmalert/web.qtpl:160
+//line app/vmalert/web.qtpl:173
 				qw422016.N().S(` (`)
-//line app/vmalert/web.qtpl:160
+//line app/vmalert/web.qtpl:173
 				qw422016.N().D(len(alertsByRule[ruleID]))
-//line app/vmalert/web.qtpl:160
+//line app/vmalert/web.qtpl:173
 				qw422016.N().S(`)<br>
                     <b>expr:</b><code><pre>`)
-//line app/vmalert/web.qtpl:161
+//line app/vmalert/web.qtpl:174
 				qw422016.E().S(defaultAR.Expression)
-//line app/vmalert/web.qtpl:161
+//line app/vmalert/web.qtpl:174
 				qw422016.N().S(`</pre></code>
                     <table class="table table-striped table-hover table-sm">
                         <thead>
@@ -568,152 +709,152 @@ func StreamListAlerts(qw422016 *qt422016.Writer, groupAlerts []GroupAlerts) {
                         </thead>
                         <tbody>
                         `)
-//line app/vmalert/web.qtpl:173
+//line app/vmalert/web.qtpl:186
 				for _, ar := range alertsByRule[ruleID] {
-//line app/vmalert/web.qtpl:173
+//line app/vmalert/web.qtpl:186
 					qw422016.N().S(`
                             <tr>
                                 <td>
                                     `)
-//line app/vmalert/web.qtpl:176
+//line app/vmalert/web.qtpl:189
 					for _, k := range labelKeys {
-//line app/vmalert/web.qtpl:176
+//line app/vmalert/web.qtpl:189
 						qw422016.N().S(`
                                         <span class="ms-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:177
+//line app/vmalert/web.qtpl:190
 						qw422016.E().S(k)
-//line app/vmalert/web.qtpl:177
+//line app/vmalert/web.qtpl:190
 						qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:177
+//line app/vmalert/web.qtpl:190
 						qw422016.E().S(ar.Labels[k])
-//line app/vmalert/web.qtpl:177
+//line app/vmalert/web.qtpl:190
 						qw422016.N().S(`</span>
                                     `)
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:191
 					}
-//line app/vmalert/web.qtpl:178
+//line app/vmalert/web.qtpl:191
 					qw422016.N().S(`
                                 </td>
                                 <td><span class="badge `)
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 					if ar.State == "firing" {
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 						qw422016.N().S(`bg-danger`)
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 					} else {
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 						qw422016.N().S(` bg-warning text-dark`)
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 					}
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 					qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 					qw422016.E().S(ar.State)
-//line app/vmalert/web.qtpl:180
+//line app/vmalert/web.qtpl:193
 					qw422016.N().S(`</span></td>
                                 <td>`)
-//line app/vmalert/web.qtpl:181
+//line app/vmalert/web.qtpl:194
 					qw422016.E().S(ar.ActiveAt.Format("2006-01-02T15:04:05Z07:00"))
-//line app/vmalert/web.qtpl:181
+//line app/vmalert/web.qtpl:194
 					qw422016.N().S(`</td>
                                 <td>`)
-//line app/vmalert/web.qtpl:182
+//line app/vmalert/web.qtpl:195
 					qw422016.E().S(ar.Value)
-//line app/vmalert/web.qtpl:182
+//line app/vmalert/web.qtpl:195
 					qw422016.N().S(`</td>
                                 <td>
                                     <a href="/`)
-//line app/vmalert/web.qtpl:184
+//line app/vmalert/web.qtpl:197
 					qw422016.E().S(g.ID)
-//line app/vmalert/web.qtpl:184
+//line app/vmalert/web.qtpl:197
 					qw422016.N().S(`/`)
-//line app/vmalert/web.qtpl:184
+//line app/vmalert/web.qtpl:197
 					qw422016.E().S(ar.ID)
-//line app/vmalert/web.qtpl:184
+//line app/vmalert/web.qtpl:197
 					qw422016.N().S(`/status">Details</a>
                                 </td>
                             </tr>
                         `)
-//line app/vmalert/web.qtpl:187
+//line app/vmalert/web.qtpl:200
 				}
-//line app/vmalert/web.qtpl:187
+//line app/vmalert/web.qtpl:200
 				qw422016.N().S(`
                      </tbody>
                     </table>
                 `)
-//line app/vmalert/web.qtpl:190
+//line app/vmalert/web.qtpl:203
 			}
-//line app/vmalert/web.qtpl:190
+//line app/vmalert/web.qtpl:203
 			qw422016.N().S(`
             </div>
             <br>
         `)
-//line app/vmalert/web.qtpl:193
+//line app/vmalert/web.qtpl:206
 		}
-//line app/vmalert/web.qtpl:193
+//line app/vmalert/web.qtpl:206
 		qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:195
+//line app/vmalert/web.qtpl:208
 	} else {
-//line app/vmalert/web.qtpl:195
+//line app/vmalert/web.qtpl:208
 		qw422016.N().S(`
         <div>
             <p>No items...</p>
         </div>
     `)
-//line app/vmalert/web.qtpl:199
+//line app/vmalert/web.qtpl:212
 	}
-//line app/vmalert/web.qtpl:199
+//line app/vmalert/web.qtpl:212
 	qw422016.N().S(`
 
     `)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:214
 	tpl.StreamFooter(qw422016)
-//line app/vmalert/web.qtpl:201
+//line app/vmalert/web.qtpl:214
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 }
 
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 func WriteListAlerts(qq422016 qtio422016.Writer, groupAlerts []GroupAlerts) {
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	StreamListAlerts(qw422016, groupAlerts)
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 }
 
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 func ListAlerts(groupAlerts []GroupAlerts) string {
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	WriteListAlerts(qb422016, groupAlerts)
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 	return qs422016
-//line app/vmalert/web.qtpl:203
+//line app/vmalert/web.qtpl:216
 }
 
-//line app/vmalert/web.qtpl:205
+//line app/vmalert/web.qtpl:218
 func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
-//line app/vmalert/web.qtpl:205
+//line app/vmalert/web.qtpl:218
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:206
+//line app/vmalert/web.qtpl:219
 	tpl.StreamHeader(qw422016, "", navItems)
-//line app/vmalert/web.qtpl:206
+//line app/vmalert/web.qtpl:219
 	qw422016.N().S(`
     `)
-//line app/vmalert/web.qtpl:208
+//line app/vmalert/web.qtpl:221
 	var labelKeys []string
 	for k := range alert.Labels {
 		labelKeys = append(labelKeys, k)
@@ -726,28 +867,28 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
 	}
 	sort.Strings(annotationKeys)
 
-//line app/vmalert/web.qtpl:219
+//line app/vmalert/web.qtpl:232
 	qw422016.N().S(`
     <div class="display-6 pb-3 mb-3">`)
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	qw422016.E().S(alert.Name)
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	qw422016.N().S(`<span class="ms-2 badge `)
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	if alert.State == "firing" {
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 		qw422016.N().S(`bg-danger`)
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	} else {
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 		qw422016.N().S(` bg-warning text-dark`)
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	}
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	qw422016.E().S(alert.State)
-//line app/vmalert/web.qtpl:220
+//line app/vmalert/web.qtpl:233
 	qw422016.N().S(`</span></div>
     <div class="container border-bottom p-2">
       <div class="row">
@@ -756,9 +897,9 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
           `)
-//line app/vmalert/web.qtpl:227
+//line app/vmalert/web.qtpl:240
 	qw422016.E().S(alert.ActiveAt.Format("2006-01-02T15:04:05Z07:00"))
-//line app/vmalert/web.qtpl:227
+//line app/vmalert/web.qtpl:240
 	qw422016.N().S(`
         </div>
       </div>
@@ -770,9 +911,9 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
           <code><pre>`)
-//line app/vmalert/web.qtpl:237
+//line app/vmalert/web.qtpl:250
 	qw422016.E().S(alert.Expression)
-//line app/vmalert/web.qtpl:237
+//line app/vmalert/web.qtpl:250
 	qw422016.N().S(`</pre></code>
         </div>
       </div>
@@ -784,23 +925,23 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
            `)
-//line app/vmalert/web.qtpl:247
+//line app/vmalert/web.qtpl:260
 	for _, k := range labelKeys {
-//line app/vmalert/web.qtpl:247
+//line app/vmalert/web.qtpl:260
 		qw422016.N().S(`
                 <span class="m-1 badge bg-primary">`)
-//line app/vmalert/web.qtpl:248
+//line app/vmalert/web.qtpl:261
 		qw422016.E().S(k)
-//line app/vmalert/web.qtpl:248
+//line app/vmalert/web.qtpl:261
 		qw422016.N().S(`=`)
-//line app/vmalert/web.qtpl:248
+//line app/vmalert/web.qtpl:261
 		qw422016.E().S(alert.Labels[k])
-//line app/vmalert/web.qtpl:248
+//line app/vmalert/web.qtpl:261
 		qw422016.N().S(`</span>
           `)
-//line app/vmalert/web.qtpl:249
+//line app/vmalert/web.qtpl:262
 	}
-//line app/vmalert/web.qtpl:249
+//line app/vmalert/web.qtpl:262
 	qw422016.N().S(`
         </div>
       </div>
@@ -812,24 +953,24 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
            `)
-//line app/vmalert/web.qtpl:259
+//line app/vmalert/web.qtpl:272
 	for _, k := range annotationKeys {
-//line app/vmalert/web.qtpl:259
+//line app/vmalert/web.qtpl:272
 		qw422016.N().S(`
                 <b>`)
-//line app/vmalert/web.qtpl:260
+//line app/vmalert/web.qtpl:273
 		qw422016.E().S(k)
-//line app/vmalert/web.qtpl:260
+//line app/vmalert/web.qtpl:273
 		qw422016.N().S(`:</b><br>
                 <p>`)
-//line app/vmalert/web.qtpl:261
+//line app/vmalert/web.qtpl:274
 		qw422016.E().S(alert.Annotations[k])
-//line app/vmalert/web.qtpl:261
+//line app/vmalert/web.qtpl:274
 		qw422016.N().S(`</p>
           `)
-//line app/vmalert/web.qtpl:262
+//line app/vmalert/web.qtpl:275
 	}
-//line app/vmalert/web.qtpl:262
+//line app/vmalert/web.qtpl:275
 	qw422016.N().S(`
         </div>
       </div>
@@ -841,49 +982,226 @@ func StreamAlert(qw422016 *qt422016.Writer, alert *APIAlert) {
         </div>
         <div class="col">
            <a target="_blank" href="/groups#group-`)
-//line app/vmalert/web.qtpl:272
+//line app/vmalert/web.qtpl:285
 	qw422016.E().S(alert.GroupID)
-//line app/vmalert/web.qtpl:272
+//line app/vmalert/web.qtpl:285
 	qw422016.N().S(`">`)
-//line app/vmalert/web.qtpl:272
+//line app/vmalert/web.qtpl:285
 	qw422016.E().S(alert.GroupID)
-//line app/vmalert/web.qtpl:272
+//line app/vmalert/web.qtpl:285
 	qw422016.N().S(`</a>
         </div>
       </div>
     </div>
     `)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:289
 	tpl.StreamFooter(qw422016)
-//line app/vmalert/web.qtpl:276
+//line app/vmalert/web.qtpl:289
 	qw422016.N().S(`
 
 `)
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 }
 
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 func WriteAlert(qq422016 qtio422016.Writer, alert *APIAlert) {
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 	StreamAlert(qw422016, alert)
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 }
 
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 func Alert(alert *APIAlert) string {
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
 	WriteAlert(qb422016, alert)
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:291
+	qs422016 := string(qb422016.B)
+//line app/vmalert/web.qtpl:291
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmalert/web.qtpl:291
+	return qs422016
+//line app/vmalert/web.qtpl:291
+}
+
+//line app/vmalert/web.qtpl:293
+func StreamRuleDetails(qw422016 *qt422016.Writer, rule APIRuleDetails) {
+//line app/vmalert/web.qtpl:293
+	qw422016.N().S(`
+    `)
+//line app/vmalert/web.qtpl:294
+	tpl.StreamHeader(qw422016, "", navItems)
+//line app/vmalert/web.qtpl:294
+	qw422016.N().S(`
+    <div class="display-6 pb-3 mb-3">`)
+//line app/vmalert/web.qtpl:295
+	qw422016.E().S(rule.Name)
+//line app/vmalert/web.qtpl:295
+	if rule.Type != "prometheus" {
+//line app/vmalert/web.qtpl:295
+		qw422016.N().S(` (`)
+//line app/vmalert/web.qtpl:295
+		qw422016.E().S(rule.Type)
+//line app/vmalert/web.qtpl:295
+		qw422016.N().S(`)`)
+//line app/vmalert/web.qtpl:295
+	}
+//line app/vmalert/web.qtpl:295
+	qw422016.N().S(`</div>
+    <div class="container border-bottom p-2">
+      <div class="row">
+        <div class="col-2">
+          Expr
+        </div>
+        <div class="col">
+          <code><pre>`)
+//line app/vmalert/web.qtpl:302
+	qw422016.E().S(rule.Expression)
+//line app/vmalert/web.qtpl:302
+	qw422016.N().S(`</pre></code>
+        </div>
+      </div>
+    </div>
+    <div class="container border-bottom p-2">
+      <div class="row">
+        <div class="col-2">
+          Group
+        </div>
+        <div class="col">
+           <a target="_blank" href="/groups#group-`)
+//line app/vmalert/web.qtpl:312
+	qw422016.E().S(rule.GroupID)
+//line app/vmalert/web.qtpl:312
+	qw422016.N().S(`">`)
+//line app/vmalert/web.qtpl:312
+	qw422016.E().S(rule.GroupID)
+//line app/vmalert/web.qtpl:312
+	qw422016.N().S(`</a>
+        </div>
+      </div>
+    </div>
+    <br>
+    <div class="display-6 pb-3">Last `)
+//line app/vmalert/web.qtpl:317
+	qw422016.N().D(len(rule.Updates))
+//line app/vmalert/web.qtpl:317
+	qw422016.N().S(`/`)
+//line app/vmalert/web.qtpl:317
+	qw422016.N().D(rule.MaxUpdates)
+//line app/vmalert/web.qtpl:317
+	qw422016.N().S(` updates</div>
+    <table class="table table-striped table-hover table-sm">
+        <thead>
+            <tr>
+                <th scope="col" title="The time when rule evaluation started">Updated at</th>
+                <th scope="col" title="How many samples were returned">Samples</th>
+                <th scope="col" title="How long the evaluation took">Duration</th>
+                <th scope="col" title="Shows if rule's execution ended with error">Error</th>
+                <th scope="col" title="The trace id and the query trace if -datasource.queryTrace is set">Trace</th>
+            </tr>
+        </thead>
+        <tbody>
+        `)
+//line app/vmalert/web.qtpl:329
+	for _, u := range rule.Updates {
+//line app/vmalert/web.qtpl:329
+		qw422016.N().S(`
+            <tr`)
+//line app/vmalert/web.qtpl:330
+		if u.Error != "" {
+//line app/vmalert/web.qtpl:330
+			qw422016.N().S(` class="alert-danger"`)
+//line app/vmalert/web.qtpl:330
+		}
+//line app/vmalert/web.qtpl:330
+		qw422016.N().S(`>
+                <td>`)
+//line app/vmalert/web.qtpl:331
+		qw422016.E().S(u.Time.Format("2006-01-02T15:04:05Z07:00"))
+//line app/vmalert/web.qtpl:331
+		qw422016.N().S(`</td>
+                <td>`)
+//line app/vmalert/web.qtpl:332
+		qw422016.N().D(u.Samples)
+//line app/vmalert/web.qtpl:332
+		qw422016.N().S(`</td>
+                <td>`)
+//line app/vmalert/web.qtpl:333
+		qw422016.E().S(u.Duration)
+//line app/vmalert/web.qtpl:333
+		qw422016.N().S(`</td>
+                <td><div class="error-cell">`)
+//line app/vmalert/web.qtpl:334
+		qw422016.E().S(u.Error)
+//line app/vmalert/web.qtpl:334
+		qw422016.N().S(`</div></td>
+                <td>
+                    <small>`)
+//line app/vmalert/web.qtpl:336
+		qw422016.E().S(u.TraceID)
+//line app/vmalert/web.qtpl:336
+		qw422016.N().S(`</small>
+                    `)
+//line app/vmalert/web.qtpl:337
+		if u.Trace != "" {
+//line app/vmalert/web.qtpl:337
+			qw422016.N().S(`
+                    <details><summary>query trace</summary><pre>`)
+//line app/vmalert/web.qtpl:338
+			qw422016.E().S(u.Trace)
+//line app/vmalert/web.qtpl:338
+			qw422016.N().S(`</pre></details>
+                    `)
+//line app/vmalert/web.qtpl:339
+		}
+//line app/vmalert/web.qtpl:339
+		qw422016.N().S(`
+                </td>
+            </tr>
+        `)
+//line app/vmalert/web.qtpl:342
+	}
+//line app/vmalert/web.qtpl:342
+	qw422016.N().S(`
+        </tbody>
+    </table>
+    `)
+//line app/vmalert/web.qtpl:345
+	tpl.StreamFooter(qw422016)
+//line app/vmalert/web.qtpl:345
+	qw422016.N().S(`
+
+`)
+//line app/vmalert/web.qtpl:347
+}
+
+//line app/vmalert/web.qtpl:347
+func WriteRuleDetails(qq422016 qtio422016.Writer, rule APIRuleDetails) {
+//line app/vmalert/web.qtpl:347
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmalert/web.qtpl:347
+	StreamRuleDetails(qw422016, rule)
+//line app/vmalert/web.qtpl:347
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmalert/web.qtpl:347
+}
+
+//line app/vmalert/web.qtpl:347
+func RuleDetails(rule APIRuleDetails) string {
+//line app/vmalert/web.qtpl:347
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmalert/web.qtpl:347
+	WriteRuleDetails(qb422016, rule)
+//line app/vmalert/web.qtpl:347
 	qs422016 := string(qb422016.B)
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:347
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:347
 	return qs422016
-//line app/vmalert/web.qtpl:278
+//line app/vmalert/web.qtpl:347
 }
@@ -2,9 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/notifier"
@@ -23,7 +26,8 @@ func TestHandler(t *testing.T) {
 	}
 	m := &manager{groups: make(map[uint64]*Group)}
 	m.groups[0] = g
-	rh := &requestHandler{m: m}
+	reloadCh := make(chan chan error)
+	rh := &requestHandler{m: m, reloadCh: reloadCh}
 
 	getResp := func(url string, to interface{}, code int) {
 		t.Helper()
@@ -61,6 +65,27 @@ func TestHandler(t *testing.T) {
 			t.Errorf("expected 1 group got %d", length)
 		}
 	})
+	t.Run("/api/v1/notifiers", func(t *testing.T) {
+		lr := listNotifiersResponse{}
+		getResp(ts.URL+"/api/v1/notifiers", &lr, 200)
+		if lr.Status != "success" || lr.Data.Targets == nil {
+			t.Errorf("unexpected response: %#v", lr)
+		}
+	})
+	t.Run("/api/v1/rules/config", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/api/v1/rules/config")
+		if err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		if !strings.Contains(string(b), "alert: alert") {
+			t.Errorf("expected to get effective config with rule %q; got %s", ar.Name, b)
+		}
+	})
 	t.Run("/api/v1/0/0/status", func(t *testing.T) {
 		alert := &APIAlert{}
 		getResp(ts.URL+"/api/v1/0/0/status", alert, 200)
@@ -75,7 +100,26 @@ func TestHandler(t *testing.T) {
 	t.Run("/api/v1/1/0/status", func(t *testing.T) {
 		getResp(ts.URL+"/api/v1/1/0/status", nil, 404)
 	})
+	t.Run("/api/v1/rule", func(t *testing.T) {
+		rule := APIRuleDetails{}
+		getResp(ts.URL+"/api/v1/rule?group_id=0&rule_id=0", &rule, 200)
+		if rule.Name != ar.Name {
+			t.Errorf("expected rule name %q; got %q", ar.Name, rule.Name)
+		}
+		getResp(ts.URL+"/api/v1/rule?group_id=0&rule_id=1", nil, 404)
+		getResp(ts.URL+"/api/v1/rule?group_id=foo&rule_id=0", nil, 400)
+	})
 	t.Run("/", func(t *testing.T) {
 		getResp(ts.URL, nil, 200)
 	})
+	t.Run("/-/reload", func(t *testing.T) {
+		go func() {
+			respCh := <-reloadCh
+			respCh <- nil
+			respCh = <-reloadCh
+			respCh <- fmt.Errorf("cannot parse configuration file")
+		}()
+		getResp(ts.URL+"/-/reload", nil, 200)
+		getResp(ts.URL+"/-/reload", nil, 500)
+	})
 }
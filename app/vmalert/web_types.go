@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/url"
 	"time"
 )
 
@@ -19,6 +20,34 @@ type APIAlert struct {
 	ActiveAt    time.Time         `json:"activeAt"`
 }
 
+// APIQueryStats contains aggregated stats
+// of the datasource query made by group rules
+type APIQueryStats struct {
+	Group                string  `json:"group"`
+	File                 string  `json:"file"`
+	Query                string  `json:"query"`
+	Count                uint64  `json:"count"`
+	TotalDurationSeconds float64 `json:"total_duration_seconds"`
+	MaxDurationSeconds   float64 `json:"max_duration_seconds"`
+	TotalResponseBytes   int64   `json:"total_response_bytes"`
+	TotalSeries          int64   `json:"total_series"`
+}
+
+// APINotifierTarget represents notifier.Target
+// for WEB view
+type APINotifierTarget struct {
+	Address string            `json:"address"`
+	Name    string            `json:"name,omitempty"`
+	Source  string            `json:"source"`
+	Labels  map[string]string `json:"labels,omitempty"`
+
+	LastSuccessAt time.Time `json:"lastSuccessAt"`
+	LastError     string    `json:"lastError,omitempty"`
+	LastErrorAt   time.Time `json:"lastErrorAt"`
+	// FailingSince is zero if the last send succeeded
+	FailingSince time.Time `json:"failingSince"`
+}
+
 // APIGroup represents Group for WEB view
 type APIGroup struct {
 	Name              string             `json:"name"`
@@ -26,39 +55,90 @@ type APIGroup struct {
 	ID                string             `json:"id"`
 	File              string             `json:"file"`
 	Interval          string             `json:"interval"`
+	EvalOffset        string             `json:"eval_offset"`
+	EvalDelay         string             `json:"eval_delay"`
 	Concurrency       int                `json:"concurrency"`
+	Limit             int                `json:"limit,omitempty"`
 	ExtraFilterLabels map[string]string  `json:"extra_filter_labels"`
 	Labels            map[string]string  `json:"labels,omitempty"`
+	Params            url.Values         `json:"params,omitempty"`
+	Tenant            string             `json:"tenant,omitempty"`
+	Notifiers         []string           `json:"notifiers,omitempty"`
+	DatasourceURL     string             `json:"datasource_url,omitempty"`
 	AlertingRules     []APIAlertingRule  `json:"alerting_rules"`
 	RecordingRules    []APIRecordingRule `json:"recording_rules"`
 }
 
 // APIAlertingRule represents AlertingRule for WEB view
 type APIAlertingRule struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Type        string            `json:"type"`
-	GroupID     string            `json:"group_id"`
-	Expression  string            `json:"expression"`
-	For         string            `json:"for"`
-	LastError   string            `json:"last_error"`
-	LastSamples int               `json:"last_samples"`
-	LastExec    time.Time         `json:"last_exec"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	GroupID       string `json:"group_id"`
+	Expression    string `json:"expression"`
+	For           string `json:"for"`
+	KeepFiringFor string `json:"keep_firing_for"`
+	LastError     string `json:"last_error"`
+	// LastFailures is the number of consecutive failed evaluations
+	LastFailures int `json:"last_failures"`
+	// Health is "err" if the rule failed health_errors_threshold
+	// evaluations in a row and "ok" otherwise
+	Health      string    `json:"health"`
+	LastSamples int       `json:"last_samples"`
+	LastExec    time.Time `json:"last_exec"`
+	// LastDuration is the duration of the last evaluation in seconds
+	LastDuration float64 `json:"last_duration"`
+	// Timeout is the rule's query timeout.
+	// Is empty if queries aren't limited
+	Timeout     string            `json:"timeout,omitempty"`
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
 }
 
 // APIRecordingRule represents RecordingRule for WEB view
 type APIRecordingRule struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Type        string            `json:"type"`
-	GroupID     string            `json:"group_id"`
-	Expression  string            `json:"expression"`
-	LastError   string            `json:"last_error"`
-	LastSamples int               `json:"last_samples"`
-	LastExec    time.Time         `json:"last_exec"`
-	Labels      map[string]string `json:"labels"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	GroupID    string `json:"group_id"`
+	Expression string `json:"expression"`
+	LastError  string `json:"last_error"`
+	// LastFailures is the number of consecutive failed evaluations
+	LastFailures int `json:"last_failures"`
+	// Health is "err" if the rule failed health_errors_threshold
+	// evaluations in a row and "ok" otherwise
+	Health      string    `json:"health"`
+	LastSamples int       `json:"last_samples"`
+	LastExec    time.Time `json:"last_exec"`
+	// LastDuration is the duration of the last evaluation in seconds
+	LastDuration float64 `json:"last_duration"`
+	// Timeout is the rule's query timeout.
+	// Is empty if queries aren't limited
+	Timeout string            `json:"timeout,omitempty"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// APIRuleDetails represents rule with the history
+// of its evaluations for WEB view
+type APIRuleDetails struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	GroupID    string `json:"group_id"`
+	Expression string `json:"expression"`
+	// MaxUpdates is the max number of stored updates
+	MaxUpdates int             `json:"max_updates_entries"`
+	Updates    []APIRuleUpdate `json:"updates"`
+}
+
+// APIRuleUpdate represents a single rule evaluation for WEB view
+type APIRuleUpdate struct {
+	Time     time.Time `json:"time"`
+	Duration string    `json:"duration"`
+	Samples  int       `json:"samples"`
+	Error    string    `json:"error,omitempty"`
+	TraceID  string    `json:"trace_id,omitempty"`
+	Trace    string    `json:"trace,omitempty"`
 }
 
 // GroupAlerts represents a group of alerts for WEB view
@@ -2,6 +2,7 @@ package envtemplate
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 
@@ -23,3 +24,24 @@ func Replace(b []byte) []byte {
 	})
 	return []byte(s)
 }
+
+// ReplaceStrict replaces `%{ENV_VAR}` placeholders in b with the corresponding ENV_VAR values.
+//
+// Unlike Replace, it returns an error if b contains placeholders for missing env vars.
+func ReplaceStrict(b []byte) ([]byte, error) {
+	if !bytes.Contains(b, []byte("%{")) {
+		// Fast path - nothing to replace.
+		return b, nil
+	}
+	s, err := fasttemplate.ExecuteFuncStringWithErr(string(b), "%{", "}", func(w io.Writer, tag string) (int, error) {
+		v, ok := os.LookupEnv(tag)
+		if !ok {
+			return 0, fmt.Errorf("missing %q environment variable", tag)
+		}
+		return w.Write([]byte(v))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
@@ -1,6 +1,7 @@
 package envtemplate
 
 import (
+	"os"
 	"testing"
 )
 
@@ -17,3 +18,35 @@ func TestReplace(t *testing.T) {
 	f("%{foo}", "%{foo}")
 	f("foo %{bar} %{baz}", "foo %{bar} %{baz}")
 }
+
+func TestReplaceStrictSuccess(t *testing.T) {
+	if err := os.Setenv("ENVTEMPLATE_TEST_FOO", "bar"); err != nil {
+		t.Fatalf("cannot set env var: %s", err)
+	}
+	defer func() { _ = os.Unsetenv("ENVTEMPLATE_TEST_FOO") }()
+	f := func(s, resultExpected string) {
+		t.Helper()
+		result, err := ReplaceStrict([]byte(s))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+	f("", "")
+	f("foo", "foo")
+	f("%{ENVTEMPLATE_TEST_FOO}", "bar")
+	f("foo %{ENVTEMPLATE_TEST_FOO} baz", "foo bar baz")
+}
+
+func TestReplaceStrictFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := ReplaceStrict([]byte(s)); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+	f("%{ENVTEMPLATE_TEST_MISSING}")
+	f("foo %{ENVTEMPLATE_TEST_MISSING} bar")
+}